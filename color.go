@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"lambda-ci/deploy"
+	"os"
+)
+
+// Modes for --color.
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// actionColors are the ANSI color codes of the actions in the summary.
+var actionColors = map[string]string{
+	deploy.ActionUpdated: "32",
+	deploy.ActionSkipped: "33",
+	deploy.ActionFailed:  "31",
+}
+
+// useColor reports whether the summary is colored.
+// In auto mode colors are used if stdout is a terminal and NO_COLOR is not set.
+func useColor(mode string, noColor bool) (bool, error) {
+	if noColor {
+		return false, nil
+	}
+	switch mode {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case colorAuto:
+		if _, ok := os.LookupEnv("NO_COLOR"); ok {
+			return false, nil
+		}
+		info, err := os.Stdout.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0, nil
+	}
+	return false, fmt.Errorf("--color must be %s, %s or %s", colorAuto, colorAlways, colorNever)
+}
+
+// colorize pads the action to width and wraps it in the color of the action, if it has one.
+func colorize(action string, width int) string {
+	padded := fmt.Sprintf("%-*s", width, action)
+	code, ok := actionColors[action]
+	if !ok {
+		return padded
+	}
+	return fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, padded)
+}
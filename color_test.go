@@ -0,0 +1,92 @@
+package main
+
+import (
+	"lambda-ci/deploy"
+	"strings"
+	"testing"
+)
+
+func TestUseColor(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		noColor  bool
+		expected bool
+	}{
+		{"always", colorAlways, false, true},
+		{"never", colorNever, false, false},
+		{"no-color wins", colorAlways, true, false},
+		{"auto without terminal", colorAuto, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			color, err := useColor(test.mode, test.noColor)
+			if err != nil || color != test.expected {
+				t.Errorf("expected %v, got %v, %v", test.expected, color, err)
+			}
+		})
+	}
+}
+
+func TestUseColorRejectsUnknownMode(t *testing.T) {
+	if _, err := useColor("sometimes", false); err == nil || err.Error() != "--color must be auto, always or never" {
+		t.Errorf("expected the unknown mode to be rejected, got %v", err)
+	}
+}
+
+func TestColorize(t *testing.T) {
+	if colored := colorize(deploy.ActionUpdated, 8); colored != "\x1b[32mupdated \x1b[0m" {
+		t.Errorf("expected a green action, got %q", colored)
+	}
+	if plain := colorize(deploy.ActionDryRun, 8); plain != "dry-run " {
+		t.Errorf("expected actions without color to be padded only, got %q", plain)
+	}
+}
+
+func TestPrintSummaryWithoutColorHasNoANSICodes(t *testing.T) {
+	results := []deploy.Result{
+		{Name: "hello", Action: deploy.ActionUpdated},
+		{Name: "world", Action: deploy.ActionSkipped},
+		{Name: "broken", Action: deploy.ActionFailed},
+	}
+	var plain, colored strings.Builder
+
+	printSummary(&plain, results, false)
+	printSummary(&colored, results, true)
+
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Errorf("expected no ANSI codes, got %q", plain.String())
+	}
+	for _, code := range []string{"\x1b[32m", "\x1b[33m", "\x1b[31m"} {
+		if !strings.Contains(colored.String(), code) {
+			t.Errorf("expected the colored summary to contain %q, got %q", code, colored.String())
+		}
+	}
+}
+
+func TestColorFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		colored bool
+	}{
+		{"auto without terminal", nil, false},
+		{"always", []string{"--color", "always"}, true},
+		{"no-color", []string{"--color", "always", "--no-color"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\n")
+
+			output, _ := runMain(t, dir, append(test.args, "--run-timeout", "1ns")...)
+
+			if colored := strings.Contains(output, "\x1b[33mskipped \x1b[0m hello"); colored != test.colored {
+				t.Errorf("expected colored %v, got %q", test.colored, output)
+			}
+			if !test.colored && strings.Contains(output, "\x1b[") {
+				t.Errorf("expected no ANSI codes, got %q", output)
+			}
+		})
+	}
+}
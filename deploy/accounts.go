@@ -0,0 +1,42 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"regexp"
+	"strings"
+)
+
+// accountIdPattern matches an AWS account ID.
+var accountIdPattern = regexp.MustCompile(`^\d{12}$`)
+
+// getAllowedAccounts returns the accounts the function may be deployed to.
+// The AllowedAccounts of the config replace the ones of the options.
+func (d *deployer) getAllowedAccounts(conf *FunctionConfig) []string {
+	if len(conf.AllowedAccounts) > 0 {
+		return conf.AllowedAccounts
+	}
+	return d.opts.AllowedAccounts
+}
+
+// checkAccount resolves the account of the credentials through STS and returns an error
+// if it is not one of the allowed accounts. Nothing is checked if no accounts are allowed explicitly.
+func (d *deployer) checkAccount(ctx context.Context, conf *FunctionConfig) error {
+	allowed := d.getAllowedAccounts(conf)
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	identity, err := d.sts.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("error while resolving the AWS account: %w", err)
+	}
+	account := aws.StringValue(identity.Account)
+	if !contains(allowed, account) {
+		return fmt.Errorf("REFUSING TO DEPLOY lambda function %s: the credentials belong to account %s, allowed accounts are %s",
+			conf.Name, account, strings.Join(allowed, ", "))
+	}
+	return nil
+}
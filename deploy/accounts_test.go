@@ -0,0 +1,66 @@
+package deploy
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDeployRefusesDisallowedAccount(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		allowed []string
+	}{
+		{"options", "name: hello\nfileName: main.go\n", []string{"210987654321"}},
+		{"config overrides options", "name: hello\nfileName: main.go\nallowedAccounts: [\"210987654321\"]\n", []string{testAccount}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeLambda("hello")
+			conf := newTestFunction(t, test.config)
+			opts := testOptions(client)
+			opts.AllowedAccounts = test.allowed
+
+			_, err := Deploy(context.Background(), []*FunctionConfig{conf}, opts)
+
+			if err == nil || !strings.Contains(err.Error(), "REFUSING TO DEPLOY lambda function hello: the credentials belong to account "+testAccount+", allowed accounts are 210987654321") {
+				t.Errorf("expected the account to be refused, got %v", err)
+			}
+			if mutations := client.mutations(); len(mutations) != 0 {
+				t.Errorf("expected no changes, got %v", mutations)
+			}
+		})
+	}
+}
+
+func TestDeployToAllowedAccount(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nallowedAccounts: [\""+testAccount+"\"]\n")
+	opts := testOptions(client)
+	opts.AllowedAccounts = []string{"210987654321"}
+
+	if result := deployOne(t, conf, opts); result.Action != ActionUpdated {
+		t.Errorf("expected hello to be updated, got %s", result.Action)
+	}
+}
+
+func TestDeployRejectsMalformedAllowedAccount(t *testing.T) {
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	opts.AllowedAccounts = []string{"1234"}
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\n")}, opts)
+
+	if err == nil || err.Error() != `allowed account "1234" must be a 12 digit account ID` {
+		t.Errorf("expected the malformed account to be rejected, got %v", err)
+	}
+}
+
+func TestValidateRejectsMalformedConfigAccount(t *testing.T) {
+	conf := &FunctionConfig{Name: "hello", FileName: "main.go", AllowedAccounts: []string{"12345678901a"}}
+
+	if err := conf.validate(); err == nil || err.Error() != `allowedAccounts entry "12345678901a" must be a 12 digit account ID` {
+		t.Errorf("expected the malformed account to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package deploy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// getBackupPath returns the path the live code with the given base64 encoded hash is backed up to.
+func (d *deployer) getBackupPath(conf *FunctionConfig, codeSha256 string) (string, error) {
+	hash, err := base64.StdEncoding.DecodeString(codeSha256)
+	if err != nil {
+		return "", fmt.Errorf("invalid code hash %s: %w", codeSha256, err)
+	}
+	return filepath.Join(d.opts.BackupDir, fmt.Sprintf("%s-%s.zip", conf.getBuildName(), hex.EncodeToString(hash))), nil
+}
+
+// backupCode downloads the live code of the function into Options.BackupDir before it is replaced.
+// Image based functions have no code to download and are skipped, code that is already backed up isn't downloaded again.
+func (d *deployer) backupCode(ctx context.Context, conf *FunctionConfig) error {
+	function, err := d.lambda.GetFunctionWithContext(ctx, &lambda.GetFunctionInput{
+		FunctionName: &conf.Name,
+	})
+	if err != nil {
+		return err
+	}
+	if aws.StringValue(function.Configuration.PackageType) == lambda.PackageTypeImage {
+		logrus.Infof("skipped backup of lambda function %s, image based functions have no code to download", conf.Name)
+		return nil
+	}
+
+	path, err := d.getBackupPath(conf, aws.StringValue(function.Configuration.CodeSha256))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		logrus.Infof("live code of lambda function %s is already backed up at %s", conf.Name, path)
+		return nil
+	}
+	if err := os.MkdirAll(d.opts.BackupDir, 0755); err != nil {
+		return err
+	}
+	if err := d.download(ctx, aws.StringValue(function.Code.Location), path); err != nil {
+		return err
+	}
+	logrus.Infof("backed up live code of lambda function %s at %s", conf.Name, path)
+	return nil
+}
+
+// download writes the body of the given URL to path. The file only appears once the download is complete.
+func (d *deployer) download(ctx context.Context, url string, path string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	client := d.opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("download of the code failed with status %s", response.Status)
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := io.Copy(file, response.Body); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
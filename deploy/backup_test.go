@@ -0,0 +1,98 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// initialBackupName is the backup file name of the code of functions added to a fakeLambda.
+const initialBackupName = "hello-696e697469616c.zip"
+
+// newCodeServer serves body as the live code and counts the downloads.
+func newCodeServer(t *testing.T, status int, body string) (*httptest.Server, *int32) {
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &downloads
+}
+
+func TestDeployBacksUpLiveCode(t *testing.T) {
+	server, _ := newCodeServer(t, http.StatusOK, "live code")
+	client := newFakeLambda("hello")
+	client.codeLocation = server.URL + "/hello.zip"
+	opts := testOptions(client)
+	opts.BackupDir = filepath.Join(t.TempDir(), "backups")
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+	backup, err := ioutil.ReadFile(filepath.Join(opts.BackupDir, initialBackupName))
+	if err != nil || string(backup) != "live code" {
+		t.Errorf("expected the live code to be backed up, got %q, %v", backup, err)
+	}
+	files, _ := ioutil.ReadDir(opts.BackupDir)
+	if len(files) != 1 {
+		t.Errorf("expected only the backup in the directory, got %d files", len(files))
+	}
+}
+
+func TestDeploySkipsExistingBackup(t *testing.T) {
+	server, downloads := newCodeServer(t, http.StatusOK, "live code")
+	client := newFakeLambda("hello")
+	client.codeLocation = server.URL
+	opts := testOptions(client)
+	opts.BackupDir = t.TempDir()
+	writeFiles(t, opts.BackupDir, initialBackupName, "earlier backup")
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+	if atomic.LoadInt32(downloads) != 0 {
+		t.Errorf("expected no download, got %d", atomic.LoadInt32(downloads))
+	}
+}
+
+func TestDeploySkipsBackupOfImageFunctions(t *testing.T) {
+	server, downloads := newCodeServer(t, http.StatusOK, "live code")
+	client := newFakeLambda("hello")
+	client.codeLocation = server.URL
+	client.functions["hello"].PackageType = aws.String(lambda.PackageTypeImage)
+	opts := testOptions(client)
+	opts.BackupDir = t.TempDir()
+
+	deployOne(t, newTestFunction(t, "name: hello\nimageUri: 123456789012.dkr.ecr.eu-central-1.amazonaws.com/hello:v2\npackageType: Image\n"), opts)
+
+	if files, _ := ioutil.ReadDir(opts.BackupDir); atomic.LoadInt32(downloads) != 0 || len(files) != 0 {
+		t.Errorf("expected no backup, got %d downloads and %d files", atomic.LoadInt32(downloads), len(files))
+	}
+}
+
+func TestDeployFailsIfBackupFails(t *testing.T) {
+	server, _ := newCodeServer(t, http.StatusForbidden, "expired")
+	client := newFakeLambda("hello")
+	client.codeLocation = server.URL
+	opts := testOptions(client)
+	opts.BackupDir = t.TempDir()
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\n")}, opts)
+
+	if err == nil || !strings.Contains(err.Error(), "download of the code failed with status 403 Forbidden") {
+		t.Errorf("expected the failed download to fail the deploy, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected the code not to be replaced, got %d updates", count)
+	}
+	if files, _ := ioutil.ReadDir(opts.BackupDir); len(files) != 0 {
+		t.Errorf("expected no partial backup, got %d files", len(files))
+	}
+}
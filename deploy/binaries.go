@@ -0,0 +1,116 @@
+package deploy
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// BinarySpec describes an additional Go binary that is built and zipped next to the function binary,
+// e.g. a helper process or an extension.
+type BinarySpec struct {
+	// Source is the Go file or package directory relative to the function directory.
+	Source string `yaml:"source"`
+	// ZipEntryName is the path of the binary inside the zip archive.
+	ZipEntryName string `yaml:"zipEntryName"`
+}
+
+// validateBinaries checks that all Binaries have a source and a unique entry name
+// that collides neither with the function binary nor with the build metadata.
+func (conf *FunctionConfig) validateBinaries() error {
+	if len(conf.Binaries) > 0 && conf.ImageUri != "" {
+		return errors.New("binaries can't be used with imageUri")
+	}
+	entryNames := map[string]bool{conf.getZipEntryName(): true, buildInfoEntryName: true}
+	for _, binary := range conf.Binaries {
+		if binary.Source == "" || !isCleanRelativePath(binary.Source) {
+			return fmt.Errorf("binaries source %q must be a clean relative path", binary.Source)
+		}
+		if binary.ZipEntryName == "" || !isCleanRelativePath(binary.ZipEntryName) {
+			return fmt.Errorf("binaries zipEntryName %q of %s must be a clean relative path", binary.ZipEntryName, binary.Source)
+		}
+		if entryNames[binary.ZipEntryName] {
+			return fmt.Errorf("binaries zipEntryName %s collides with another entry of the zip archive", binary.ZipEntryName)
+		}
+		entryNames[binary.ZipEntryName] = true
+	}
+	return nil
+}
+
+// isBinaryEntry reports whether name is the zip entry name of one of the Binaries.
+func (conf *FunctionConfig) isBinaryEntry(name string) bool {
+	for _, binary := range conf.Binaries {
+		if binary.ZipEntryName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getBinaryOutputPath returns the path where the i-th entry of Binaries is written to.
+func (conf *FunctionConfig) getBinaryOutputPath(i int) string {
+	return fmt.Sprintf("%s/binary-%d", conf.buildDir, i)
+}
+
+// buildBinaries builds all Binaries with the same environment as the function binary.
+// extraArgs are passed to go build before the source.
+func (conf *FunctionConfig) buildBinaries(ctx context.Context, extraArgs ...string) error {
+	for i, binary := range conf.Binaries {
+		if err := conf.goBuild(ctx, conf.getBinaryOutputPath(i), []string{conf.getFullFilePath(binary.Source)}, extraArgs...); err != nil {
+			return fmt.Errorf("error while compiling binary %s: %w", binary.Source, err)
+		}
+	}
+	return nil
+}
+
+// deleteBinaryFiles deletes the built Binaries of this FunctionConfig.
+func (conf *FunctionConfig) deleteBinaryFiles() error {
+	for i := range conf.Binaries {
+		if err := os.Remove(conf.getBinaryOutputPath(i)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error while deleting build at %s: %w", conf.getBinaryOutputPath(i), err)
+		}
+	}
+	return nil
+}
+
+// zipBinaries adds all built Binaries to the zip writer. go build writes executables,
+// so the entries keep their executable mode.
+func (conf *FunctionConfig) zipBinaries(writer *zip.Writer) error {
+	for i, binary := range conf.Binaries {
+		info, err := os.Stat(conf.getBinaryOutputPath(i))
+		if err != nil {
+			return err
+		}
+		if err := addZipEntry(writer, conf.getBinaryOutputPath(i), binary.ZipEntryName, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBinaryEntries checks that every entry of Binaries and Extensions is contained in the zip archive and is executable.
+func (conf *FunctionConfig) validateBinaryEntries(reader *zip.Reader) error {
+	entries := map[string]*zip.File{}
+	for _, entry := range reader.File {
+		entries[entry.Name] = entry
+	}
+	names := make([]string, 0, len(conf.Binaries)+len(conf.Extensions))
+	for _, binary := range conf.Binaries {
+		names = append(names, binary.ZipEntryName)
+	}
+	for _, extension := range conf.Extensions {
+		names = append(names, getExtensionEntryName(extension))
+	}
+	for _, name := range names {
+		entry, ok := entries[name]
+		if !ok {
+			return fmt.Errorf("zip archive %s misses binary %s", conf.getZipOutputPath(), name)
+		}
+		if entry.Mode()&0111 == 0 {
+			return fmt.Errorf("binary %s in zip archive %s is not executable", entry.Name, conf.getZipOutputPath())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package deploy
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDeployZipsAdditionalBinaries(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nbinaries:\n  - source: helper/main.go\n    zipEntryName: bin/helper\n",
+		"helper/main.go", "package main\n\nfunc main() { println(\"helper\") }\n")
+	opts := testOptions(client)
+	opts.KeepArtifacts = true
+
+	deployOne(t, conf, opts)
+	defer os.RemoveAll(conf.buildDir)
+
+	if entries := client.zipEntries(t, "hello"); !reflect.DeepEqual(entries, []string{"hello", "bin/helper"}) {
+		t.Errorf("expected the function binary and bin/helper, got %v", entries)
+	}
+	if err := conf.validateZip(); err != nil {
+		t.Errorf("expected both binaries to be executable, got %v", err)
+	}
+}
+
+func TestParseValidatesBinaries(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{"unclean source", "  - source: ../helper.go\n    zipEntryName: helper\n", `binaries source "../helper.go" must be a clean relative path`},
+		{"unclean entry name", "  - source: helper.go\n    zipEntryName: /bin/helper\n", `binaries zipEntryName "/bin/helper" of helper.go must be a clean relative path`},
+		{"function binary", "  - source: helper.go\n    zipEntryName: hello\n", "binaries zipEntryName hello collides with another entry of the zip archive"},
+		{"build info", "  - source: helper.go\n    zipEntryName: build-info.json\n", "binaries zipEntryName build-info.json collides with another entry of the zip archive"},
+		{"duplicate entry name", "  - source: helper.go\n    zipEntryName: helper\n  - source: other.go\n    zipEntryName: helper\n", "binaries zipEntryName helper collides with another entry of the zip archive"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTestConfig(t, "name: hello\nfileName: main.go\nbinaries:\n"+test.config, "main.go", testMain)
+
+			expectConfigError(t, err, test.message)
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package deploy
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// createBuildDir creates the temporary directory all artifacts of this FunctionConfig are written to.
+// Each deploy gets its own directory, so parallel builds never share output paths.
+func (conf *FunctionConfig) createBuildDir() error {
+	dir, err := ioutil.TempDir("", "lambda-ci-")
+	if err != nil {
+		return err
+	}
+	conf.buildDir = dir
+	return nil
+}
+
+// deleteBuildDir deletes the temporary build directory of this FunctionConfig.
+func (conf *FunctionConfig) deleteBuildDir() error {
+	if err := os.RemoveAll(conf.buildDir); err != nil {
+		return fmt.Errorf("error while deleting build directory at %s: %w", conf.buildDir, err)
+	}
+	return nil
+}
+
+// getBuildOutputPath returns the path where the built function file should be written to.
+func (conf *FunctionConfig) getBuildOutputPath() string {
+	return fmt.Sprintf("%s/%s", conf.buildDir, conf.getBuildName())
+}
+
+// getZipOutputPath returns the path where the zipped built should be written to.
+func (conf *FunctionConfig) getZipOutputPath() string {
+	return fmt.Sprintf("%s/%s.zip", conf.buildDir, conf.getBuildName())
+}
+
+// deleteBuildFile deletes the built file for this FunctionConfig.
+func (conf *FunctionConfig) deleteBuildFile() error {
+	if err := os.Remove(conf.getBuildOutputPath()); err != nil {
+		return fmt.Errorf("error while deleting build at %s: %w", conf.getBuildOutputPath(), err)
+	}
+	return nil
+}
+
+// getZipEntryName returns the path of the binary inside the zip archive.
+// Custom runtimes require the binary to be named bootstrap, otherwise it defaults to the build name.
+func (conf *FunctionConfig) getZipEntryName() string {
+	if conf.ZipEntryName != "" {
+		return conf.ZipEntryName
+	}
+	if conf.isCustomRuntime() {
+		return customRuntimeBinary
+	}
+	return conf.getBuildName()
+}
+
+// copyBootstrap copies the precompiled Bootstrap binary to the build output path and makes it executable.
+func (conf *FunctionConfig) copyBootstrap() error {
+	source, err := os.Open(fmt.Sprintf("%s/%s", conf.getSourceDir(), conf.Bootstrap))
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(conf.getBuildOutputPath(), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
+
+// getFullFilePath returns the path of the given source file of the function.
+func (conf *FunctionConfig) getFullFilePath(fileName string) string {
+	return fmt.Sprintf("%s/%s", conf.getSourceDir(), fileName)
+}
+
+// deleteZipFile deletes the zip file for this FunctionConfig.
+func (conf *FunctionConfig) deleteZipFile() error {
+	if err := os.Remove(conf.getZipOutputPath()); err != nil {
+		return fmt.Errorf("error while deleting zip file at %s: %w", conf.getZipOutputPath(), err)
+	}
+	return nil
+}
+
+// getGoArch returns the GOARCH matching the configured architecture.
+func (conf *FunctionConfig) getGoArch() string {
+	if conf.Architecture == lambda.ArchitectureArm64 {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+// raceTargets contains the GOOS/GOARCH pairs the race detector supports.
+var raceTargets = map[string]bool{
+	"linux/amd64":   true,
+	"linux/arm64":   true,
+	"linux/ppc64le": true,
+	"linux/s390x":   true,
+}
+
+// raceArgs are passed to go build when building with the race detector.
+var raceArgs = []string{"-race"}
+
+// getBuildTarget returns the GOOS and GOARCH the function is built for, including GoEnv overrides.
+func (conf *FunctionConfig) getBuildTarget() (string, string) {
+	goos, goarch := "linux", conf.getGoArch()
+	if value, ok := conf.GoEnv["GOOS"]; ok {
+		goos = value
+	}
+	if value, ok := conf.GoEnv["GOARCH"]; ok {
+		goarch = value
+	}
+	return goos, goarch
+}
+
+// checkRaceSupport returns an error if the race detector can't be used for the build target of this FunctionConfig.
+func (conf *FunctionConfig) checkRaceSupport() error {
+	goos, goarch := conf.getBuildTarget()
+	if !raceTargets[goos+"/"+goarch] {
+		return fmt.Errorf("race detector is not supported for %s/%s", goos, goarch)
+	}
+	return nil
+}
+
+// getBuildEnv returns the environment for the go build command.
+// Lambda runs on linux, GOARCH follows the configured architecture.
+// The GoEnv entries and the GoToolchain of the config are applied afterwards.
+// With Replace directives, GOFLAGS points the go command to the temporary go.mod.
+func (conf *FunctionConfig) getBuildEnv() []string {
+	env := append(os.Environ(), "GOOS=linux", "GOARCH="+conf.getGoArch())
+
+	keys := make([]string, 0, len(conf.GoEnv))
+	for key := range conf.GoEnv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", key, conf.GoEnv[key]))
+	}
+	if conf.GoToolchain != "" {
+		env = append(env, "GOTOOLCHAIN="+conf.GoToolchain)
+	}
+	if conf.replaceModFile != "" {
+		goflags, ok := conf.GoEnv["GOFLAGS"]
+		if !ok {
+			goflags = os.Getenv("GOFLAGS")
+		}
+		env = append(env, strings.TrimSpace(fmt.Sprintf("GOFLAGS=%s -modfile=%s -mod=mod", goflags, conf.replaceModFile)))
+	}
+	return env
+}
+
+// build runs the go build command for the referenced source files and writes the binary to output.
+// extraArgs are passed to go build before the source files.
+func (conf *FunctionConfig) build(ctx context.Context, output string, extraArgs ...string) error {
+	var sources []string
+	for _, fileName := range conf.getSourceFileNames() {
+		sources = append(sources, conf.getFullFilePath(fileName))
+	}
+	return conf.goBuild(ctx, output, sources, extraArgs...)
+}
+
+// goBuild runs go build for the given sources with the build environment of this FunctionConfig.
+// It runs in the source directory of the function, so the go.mod of the module containing the function applies, including its replace directives.
+// Builds with the race detector are linked with cgo.
+func (conf *FunctionConfig) goBuild(ctx context.Context, output string, sources []string, extraArgs ...string) error {
+	output, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	args := append([]string{"build", "-o", output}, extraArgs...)
+	if conf.buildTrace != nil {
+		args = append(args, "-x")
+	}
+	for _, source := range sources {
+		if source, err = filepath.Abs(source); err != nil {
+			return err
+		}
+		args = append(args, source)
+	}
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = conf.getSourceDir()
+	cmd.Env = conf.getBuildEnv()
+	if conf.buildTrace != nil {
+		cmd.Stderr = conf.buildTrace
+	}
+	if contains(extraArgs, raceArgs[0]) {
+		cmd.Env = append(cmd.Env, "CGO_ENABLED=1")
+	}
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// zipEpoch is the modification time of every zip entry. Entries don't carry the time of the build,
+// so the same build always produces the same zip and the same CodeSha256.
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newZipHeader returns the zip header for the given file with the modification time set to zipEpoch.
+func newZipHeader(info os.FileInfo) (*zip.FileHeader, error) {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return nil, err
+	}
+	header.Modified = zipEpoch
+	return header, nil
+}
+
+// zipBuild puts the built for this FunctionConfig into a zip file.
+func (conf *FunctionConfig) zipBuild() error {
+	zipFile, err := os.Create(conf.getZipOutputPath())
+	if err != nil {
+		return err
+	}
+	defer zipFile.Close()
+
+	writer := zip.NewWriter(zipFile)
+	defer writer.Close()
+
+	fileToZip, err := os.Open(conf.getBuildOutputPath())
+	if err != nil {
+		return err
+	}
+	defer fileToZip.Close()
+
+	fileStats, err := fileToZip.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := newZipHeader(fileStats)
+	if err != nil {
+		return err
+	}
+
+	header.Name = conf.getZipEntryName()
+	header.Method = zip.Deflate
+
+	fileWriter, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fileWriter, fileToZip)
+	if err != nil {
+		return err
+	}
+
+	if err := conf.zipBinaries(writer); err != nil {
+		return err
+	}
+	if err := conf.zipExtensions(writer); err != nil {
+		return err
+	}
+
+	if conf.buildInfo != nil {
+		infoWriter, err := writer.CreateHeader(&zip.FileHeader{
+			Name:     buildInfoEntryName,
+			Method:   zip.Deflate,
+			Modified: zipEpoch,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := infoWriter.Write(conf.buildInfo); err != nil {
+			return err
+		}
+	}
+
+	if conf.IncludeDir != "" {
+		return conf.zipIncludeDir(writer)
+	}
+	return nil
+}
+
+// validateZip re-opens the zip file for this FunctionConfig and checks that it is a valid Lambda package.
+// The archive must contain the built binary as first entry and the binary must be executable.
+// Other entries are only allowed if Binaries, Extensions, an IncludeDir or build metadata are added.
+func (conf *FunctionConfig) validateZip() error {
+	reader, err := zip.OpenReader(conf.getZipOutputPath())
+	if err != nil {
+		return fmt.Errorf("malformed zip archive %s: %w", conf.getZipOutputPath(), err)
+	}
+	defer reader.Close()
+
+	if len(reader.File) == 0 {
+		return fmt.Errorf("zip archive %s contains no entries, expected exactly 1", conf.getZipOutputPath())
+	}
+	// Planned zips were built by an earlier run, which may have added build metadata
+	hasBuildInfo := conf.buildInfo != nil || (conf.plan != nil && reader.File[len(reader.File)-1].Name == buildInfoEntryName)
+	if conf.IncludeDir == "" && !hasBuildInfo && len(conf.Binaries) == 0 && len(conf.Extensions) == 0 && len(reader.File) != 1 {
+		return fmt.Errorf("zip archive %s contains %d entries, expected exactly 1", conf.getZipOutputPath(), len(reader.File))
+	}
+
+	entry := reader.File[0]
+	if strings.Compare(entry.Name, conf.getZipEntryName()) != 0 {
+		return fmt.Errorf("zip archive %s contains entry %s, expected %s", conf.getZipOutputPath(), entry.Name, conf.getZipEntryName())
+	}
+	if entry.Mode()&0111 == 0 {
+		return fmt.Errorf("binary %s in zip archive %s is not executable", entry.Name, conf.getZipOutputPath())
+	}
+
+	return conf.validateBinaryEntries(&reader.Reader)
+}
+
+// unzippedSizeLimit is the hard limit of Lambda for the uncompressed size of a zip package.
+const unzippedSizeLimit = 262144000
+
+// largestEntriesReported is the number of entries listed when the package exceeds unzippedSizeLimit.
+const largestEntriesReported = 5
+
+// checkUnzippedSize returns an error listing the largest entries if the zip file for this FunctionConfig
+// exceeds the uncompressed size limit of Lambda, which would otherwise only be rejected by the upload.
+func (conf *FunctionConfig) checkUnzippedSize() error {
+	reader, err := zip.OpenReader(conf.getZipOutputPath())
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var total uint64
+	entries := make([]*zip.File, len(reader.File))
+	copy(entries, reader.File)
+	for _, entry := range entries {
+		total += entry.UncompressedSize64
+	}
+	if total <= unzippedSizeLimit {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].UncompressedSize64 > entries[j].UncompressedSize64
+	})
+	if len(entries) > largestEntriesReported {
+		entries = entries[:largestEntriesReported]
+	}
+	largest := make([]string, len(entries))
+	for i, entry := range entries {
+		largest[i] = fmt.Sprintf("%s (%s)", entry.Name, formatBytes(int64(entry.UncompressedSize64)))
+	}
+	return fmt.Errorf("uncompressed package is %s, above the Lambda limit of %s, largest entries: %s",
+		formatBytes(int64(total)), formatBytes(unzippedSizeLimit), strings.Join(largest, ", "))
+}
+
+// reportPackageSize logs the compressed and uncompressed size of the zip file for this FunctionConfig.
+// Returns the uncompressed size in bytes.
+func (conf *FunctionConfig) reportPackageSize() (int64, error) {
+	reader, err := zip.OpenReader(conf.getZipOutputPath())
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	zipStats, err := os.Stat(conf.getZipOutputPath())
+	if err != nil {
+		return 0, err
+	}
+
+	var uncompressed int64
+	for _, entry := range reader.File {
+		uncompressed += int64(entry.UncompressedSize64)
+	}
+
+	logrus.Infof("package for lambda function %s is %s zipped, %s uncompressed", conf.Name, formatBytes(zipStats.Size()), formatBytes(uncompressed))
+	return uncompressed, nil
+}
+
+// copyZipTo copies the zip file for this FunctionConfig to <dir>/<name>.
+// The zip is written to a temporary file first and renamed, so the output never contains half-written zips.
+func (conf *FunctionConfig) copyZipTo(dir string, name string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	source, err := os.Open(conf.getZipOutputPath())
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := ioutil.TempFile(dir, name+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(target.Name())
+
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+	// TempFile creates the file with mode 0600, packaged zips are uploaded by other tools
+	if err := target.Chmod(0644); err != nil {
+		target.Close()
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+
+	output := filepath.Join(dir, name)
+	if err := os.Rename(target.Name(), output); err != nil {
+		return err
+	}
+	logrus.Infof("packaged lambda function %s to %s", conf.Name, output)
+	return nil
+}
+
+// formatBytes formats the given number of bytes in MB.
+func formatBytes(size int64) string {
+	return fmt.Sprintf("%.2fMB", float64(size)/1024/1024)
+}
@@ -0,0 +1,423 @@
+package deploy
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// zipEntry is an entry written by writeTestZip.
+type zipEntry struct {
+	name string
+	mode os.FileMode
+	data string
+}
+
+// writeTestZip writes the zip of the function with the given entries to its build directory.
+func writeTestZip(t *testing.T, conf *FunctionConfig, entries ...zipEntry) {
+	t.Helper()
+	file, err := os.Create(conf.getZipOutputPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(entry.mode)
+		entryWriter, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entryWriter.Write([]byte(entry.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newBuildConfig returns a config for the function with the given name and an empty build directory.
+func newBuildConfig(t *testing.T, name string) *FunctionConfig {
+	return &FunctionConfig{Name: name, FileName: "main.go", Path: t.TempDir(), buildDir: t.TempDir()}
+}
+
+func TestValidateZipAcceptsExecutableBinary(t *testing.T) {
+	conf := newBuildConfig(t, "hello")
+	writeTestZip(t, conf, zipEntry{name: "hello", mode: 0755, data: "binary"})
+
+	if err := conf.validateZip(); err != nil {
+		t.Errorf("expected a valid zip, got %v", err)
+	}
+}
+
+func TestValidateZipRejectsInvalidPackages(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []zipEntry
+		message string
+	}{
+		{"no entries", nil, "contains no entries"},
+		{"wrong entry", []zipEntry{{name: "main", mode: 0755}}, "contains entry main, expected hello"},
+		{"not executable", []zipEntry{{name: "hello", mode: 0644}}, "is not executable"},
+		{"additional entry", []zipEntry{{name: "hello", mode: 0755}, {name: "data.txt", mode: 0644}}, "contains 2 entries"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := newBuildConfig(t, "hello")
+			writeTestZip(t, conf, test.entries...)
+
+			err := conf.validateZip()
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("expected an error containing %q, got %v", test.message, err)
+			}
+		})
+	}
+}
+
+func TestValidateZipRejectsCorruptZip(t *testing.T) {
+	conf := newBuildConfig(t, "hello")
+	if err := ioutil.WriteFile(conf.getZipOutputPath(), []byte("PK\x03\x04 truncated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := conf.validateZip()
+	if err == nil || !strings.Contains(err.Error(), "malformed zip archive") {
+		t.Errorf("expected a malformed zip error, got %v", err)
+	}
+}
+
+func TestDeployWarnsAboveSizeThreshold(t *testing.T) {
+	logs := captureLogs(t)
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.SizeWarningThreshold = 1
+
+	deployOne(t, conf, opts)
+
+	if !strings.Contains(logs.String(), "close to the 250MB Lambda limit") {
+		t.Errorf("expected a size warning, got logs %s", logs)
+	}
+}
+
+func TestDeployFailsAboveSizeThresholdInStrictMode(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].Runtime = aws.String(lambda.RuntimeProvidedAl2023)
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.SizeWarningThreshold = 1
+	opts.Strict = true
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, opts)
+
+	if err == nil || !strings.Contains(err.Error(), "warning treated as error in strict mode") {
+		t.Errorf("expected the size warning to fail the deploy, got %v", err)
+	}
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Errorf("expected a BuildError, got %T", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestDeployZipsBinaryAtZipEntryName(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nzipEntryName: bin/hello\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if entries := client.zipEntries(t, "hello"); len(entries) != 1 || entries[0] != "bin/hello" {
+		t.Errorf("expected the single entry bin/hello, got %v", entries)
+	}
+	if handler := aws.StringValue(client.function("hello").Handler); handler != "bin/hello" {
+		t.Errorf("expected handler bin/hello, got %s", handler)
+	}
+}
+
+// lookupEnv returns the value of the last entry of key in env, as used by exec.Cmd.
+func lookupEnv(env []string, key string) (string, bool) {
+	value, found := "", false
+	for _, entry := range env {
+		if strings.HasPrefix(entry, key+"=") {
+			value, found = strings.TrimPrefix(entry, key+"="), true
+		}
+	}
+	return value, found
+}
+
+func TestGetBuildEnvAppliesGoEnv(t *testing.T) {
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\narchitecture: arm64\ngoEnv:\n  GOARM64: v8.2\n  GOARCH: arm64\n")
+
+	env := conf.getBuildEnv()
+
+	for key, expected := range map[string]string{"GOOS": "linux", "GOARCH": "arm64", "GOARM64": "v8.2"} {
+		if value, _ := lookupEnv(env, key); value != expected {
+			t.Errorf("expected %s=%s, got %s", key, expected, value)
+		}
+	}
+}
+
+func TestParseRejectsUnsupportedGoEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "main.go", testMain)
+
+	_, err := ParseFunctionConfigFromReader(strings.NewReader("name: hello\nfileName: main.go\ngoEnv:\n  CGO_ENABLED: \"1\"\n"), dir)
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Errorf("expected a ConfigError for an unsupported goEnv key, got %v", err)
+	}
+}
+
+func TestDeployBuildsWithGoEnv(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\ngoEnv:\n  GOAMD64: v3\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if entries := client.zipEntries(t, "hello"); len(entries) != 1 {
+		t.Errorf("expected the built binary in the zip, got %v", entries)
+	}
+}
+
+func TestGetBuildEnvPinsGoToolchain(t *testing.T) {
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\ngoToolchain: go1.22.3\n")
+
+	if value, _ := lookupEnv(conf.getBuildEnv(), "GOTOOLCHAIN"); value != "go1.22.3" {
+		t.Errorf("expected GOTOOLCHAIN=go1.22.3, got %s", value)
+	}
+}
+
+func TestParseRejectsGoToolchainWithGoEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "main.go", testMain)
+
+	_, err := ParseFunctionConfigFromReader(strings.NewReader("name: hello\nfileName: main.go\ngoToolchain: local\ngoEnv:\n  GOTOOLCHAIN: go1.22.3\n"), dir)
+
+	if err == nil || !strings.Contains(err.Error(), "goToolchain and goEnv GOTOOLCHAIN must not be set both") {
+		t.Errorf("expected a conflict error, got %v", err)
+	}
+}
+
+func TestDeployBuildsWithGoToolchain(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\ngoToolchain: local\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if entries := client.zipEntries(t, "hello"); len(entries) != 1 {
+		t.Errorf("expected the built binary in the zip, got %v", entries)
+	}
+}
+
+func TestCheckRaceSupport(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		supported bool
+	}{
+		{"x86_64", "name: hello\nfileName: main.go\n", true},
+		{"arm64", "name: hello\nfileName: main.go\narchitecture: arm64\n", true},
+		{"goEnv GOARCH override", "name: hello\nfileName: main.go\ngoEnv:\n  GOARCH: \"386\"\n", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := newTestFunction(t, test.config)
+
+			err := conf.checkRaceSupport()
+
+			if test.supported && err != nil {
+				t.Errorf("expected the race detector to be supported, got %v", err)
+			} else if !test.supported && (err == nil || !strings.Contains(err.Error(), "race detector is not supported for linux/386")) {
+				t.Errorf("expected an unsupported target error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeployRejectsRaceForUnsupportedTarget(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\ngoEnv:\n  GOARCH: \"386\"\n")
+	opts := testOptions(client)
+	opts.Race = true
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, opts)
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Errorf("expected a BuildError for the unsupported target, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestDeployBuildsWithRaceDetector(t *testing.T) {
+	if _, err := exec.LookPath("gcc"); err != nil || runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skip("race builds need cgo for linux/amd64")
+	}
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.Race = true
+
+	deployOne(t, conf, opts)
+
+	if entries := client.zipEntries(t, "hello"); len(entries) != 1 {
+		t.Errorf("expected the race build in the zip, got %v", entries)
+	}
+}
+
+func TestDeployBuildsFileNamesTogether(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileNames: [main.go, handler.go]\n",
+		"main.go", "package main\n\nfunc main() { handle() }\n",
+		"handler.go", "package main\n\nfunc handle() {}\n",
+		"broken.go", "package main\n\nfunc handle() { undefined() }\n",
+	)
+
+	deployOne(t, conf, testOptions(client))
+
+	if entries := client.zipEntries(t, "hello"); len(entries) != 1 || entries[0] != "hello" {
+		t.Errorf("expected the binary built from main.go and handler.go, got %v", entries)
+	}
+}
+
+func TestDeployKeepsArtifacts(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.KeepArtifacts = true
+
+	deployOne(t, conf, opts)
+	defer os.RemoveAll(conf.buildDir)
+
+	for _, path := range []string{conf.getBuildOutputPath(), conf.getZipOutputPath()} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be kept, got %v", path, err)
+		}
+	}
+}
+
+func TestDeployDeletesBuildDirectory(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if _, err := os.Stat(conf.buildDir); !os.IsNotExist(err) {
+		t.Errorf("expected the build directory %s to be deleted, got %v", conf.buildDir, err)
+	}
+}
+
+func TestDeployZipsPrecompiledBootstrap(t *testing.T) {
+	client := newFakeLambda()
+	client.addFunction("hello", lambda.RuntimeProvidedAl2023)
+	script := "#!/bin/sh\necho hello\n"
+	conf := newTestFunction(t, "name: hello\nbootstrap: target/bootstrap\n", "target/bootstrap", script)
+	opts := testOptions(client)
+	opts.KeepArtifacts = true
+
+	deployOne(t, conf, opts)
+	defer os.RemoveAll(conf.buildDir)
+
+	if entries := client.zipEntries(t, "hello"); len(entries) != 1 || entries[0] != "bootstrap" {
+		t.Fatalf("expected the single entry bootstrap, got %v", entries)
+	}
+	if content := string(client.zipFile(t, "hello", "bootstrap")); content != script {
+		t.Errorf("expected the precompiled binary, got %q", content)
+	}
+	if err := conf.validateZip(); err != nil {
+		t.Errorf("expected an executable bootstrap, got %v", err)
+	}
+}
+
+func TestParseValidatesBootstrap(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{"with fileName", "name: hello\nfileName: main.go\nbootstrap: bootstrap\n", "bootstrap must not be combined with fileName, fileNames or imageUri"},
+		{"outside function directory", "name: hello\nbootstrap: ../bootstrap\n", "bootstrap ../bootstrap must be a clean relative path"},
+		{"go1.x runtime", "name: hello\nbootstrap: bootstrap\nruntime: go1.x\n", "bootstrap requires a provided.* runtime, got go1.x"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTestConfig(t, test.config, "main.go", testMain, "bootstrap", "#!/bin/sh\n")
+
+			expectConfigError(t, err, test.message)
+		})
+	}
+}
+
+// writeSizedZip writes the zip of the function with entries that declare the given uncompressed sizes without any content.
+func writeSizedZip(t *testing.T, conf *FunctionConfig, sizes map[string]uint64) {
+	t.Helper()
+	file, err := os.Create(conf.getZipOutputPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for name, size := range sizes {
+		if _, err := writer.CreateRaw(&zip.FileHeader{Name: name, Method: zip.Store, UncompressedSize64: size}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckUnzippedSizeListsLargestEntries(t *testing.T) {
+	conf := newBuildConfig(t, "hello")
+	const mb = 1024 * 1024
+	writeSizedZip(t, conf, map[string]uint64{
+		"hello": 20 * mb, "a.bin": 100 * mb, "b.bin": 90 * mb, "c.bin": 40 * mb, "d.bin": 2 * mb, "e.bin": 1 * mb,
+	})
+
+	err := conf.checkUnzippedSize()
+
+	expected := "uncompressed package is 253.00MB, above the Lambda limit of 250.00MB, largest entries: " +
+		"a.bin (100.00MB), b.bin (90.00MB), c.bin (40.00MB), hello (20.00MB), d.bin (2.00MB)"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestCheckUnzippedSizeAcceptsPackageAtLimit(t *testing.T) {
+	conf := newBuildConfig(t, "hello")
+	writeSizedZip(t, conf, map[string]uint64{"hello": unzippedSizeLimit})
+
+	if err := conf.checkUnzippedSize(); err != nil {
+		t.Errorf("expected the package at the limit to be accepted, got %v", err)
+	}
+}
+
+func TestDeployZipsCustomRuntimeBinaryAsBootstrap(t *testing.T) {
+	client := newFakeLambda()
+	client.addFunction("hello", lambda.RuntimeProvidedAl2023)
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if entries := client.zipEntries(t, "hello"); len(entries) != 1 || entries[0] != "bootstrap" {
+		t.Errorf("expected the single entry bootstrap, got %v", entries)
+	}
+	if handler := aws.StringValue(client.function("hello").Handler); handler != "bootstrap" {
+		t.Errorf("expected handler bootstrap, got %s", handler)
+	}
+}
@@ -0,0 +1,59 @@
+package deploy
+
+import (
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// buildInfoEntryName is the path of the build metadata inside the zip archive.
+const buildInfoEntryName = "build-info.json"
+
+// BuildInfo is the build metadata written next to the binary when Options.EmitBuildInfo is set.
+type BuildInfo struct {
+	Function  string `json:"function"`
+	BuildTime string `json:"buildTime"`
+	// Commit is the git commit of the function directory, empty outside of a git repository.
+	Commit    string `json:"commit"`
+	GoVersion string `json:"goVersion"`
+	// ModuleHash is the sha256 over the path, version and checksum of every module linked into the binary.
+	ModuleHash string `json:"moduleHash"`
+}
+
+// createBuildInfo reads the build metadata of the built binary and stores it for zipBuild.
+func (conf *FunctionConfig) createBuildInfo() error {
+	info, err := buildinfo.ReadFile(conf.getBuildOutputPath())
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	for _, module := range info.Deps {
+		if module.Replace != nil {
+			module = module.Replace
+		}
+		fmt.Fprintf(hash, "%s %s %s\n", module.Path, module.Version, module.Sum)
+	}
+
+	var commit string
+	if output, err := runGit(conf.Path, "rev-parse", "HEAD"); err == nil {
+		commit = strings.TrimSpace(output)
+	}
+
+	data, err := json.MarshalIndent(BuildInfo{
+		Function:   conf.Name,
+		BuildTime:  time.Now().UTC().Format(time.RFC3339),
+		Commit:     commit,
+		GoVersion:  info.GoVersion,
+		ModuleHash: hex.EncodeToString(hash.Sum(nil)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	conf.buildInfo = data
+	return nil
+}
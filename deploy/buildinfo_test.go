@@ -0,0 +1,58 @@
+package deploy
+
+import (
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeployEmitsBuildInfo(t *testing.T) {
+	dir := newGitRepository(t, "hello/.function.yaml", "name: hello\nfileName: main.go\n", "hello/main.go", testMain)
+	commit, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf, err := ParseFunctionConfig(filepath.Join(dir, "hello", ".function.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	opts.EmitBuildInfo = true
+
+	deployOne(t, conf, opts)
+
+	if entries := client.zipEntries(t, "hello"); len(entries) != 2 || entries[0] != "hello" || entries[1] != buildInfoEntryName {
+		t.Fatalf("expected the binary and %s, got %v", buildInfoEntryName, entries)
+	}
+	var info BuildInfo
+	if err := json.Unmarshal(client.zipFile(t, "hello", buildInfoEntryName), &info); err != nil {
+		t.Fatalf("invalid build info: %v", err)
+	}
+	if info.Function != "hello" || info.Commit != strings.TrimSpace(string(commit)) || info.GoVersion != runtime.Version() || len(info.ModuleHash) != 64 {
+		t.Errorf("unexpected build info %+v", info)
+	}
+	if _, err := time.Parse(time.RFC3339, info.BuildTime); err != nil {
+		t.Errorf("expected an RFC3339 build time, got %s", info.BuildTime)
+	}
+}
+
+func TestDeployEmitsBuildInfoOutsideRepository(t *testing.T) {
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	opts.EmitBuildInfo = true
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+	var info BuildInfo
+	if err := json.Unmarshal(client.zipFile(t, "hello", buildInfoEntryName), &info); err != nil {
+		t.Fatalf("invalid build info: %v", err)
+	}
+	if info.Commit != "" {
+		t.Errorf("expected no commit outside of a git repository, got %s", info.Commit)
+	}
+}
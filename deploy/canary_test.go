@@ -0,0 +1,66 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeployRoutesCanaryWeightToNewVersion(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.setAlias("hello", "live", "7")
+	client.provisioned["hello:7"] = 5
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\ncanaryWeight: 0.1\nprovisionedConcurrency: 5\n")
+
+	result := deployOne(t, conf, testOptions(client))
+
+	version, weights := client.alias("hello", "live")
+	if result.Version != "8" || version != "7" || !reflect.DeepEqual(weights, map[string]float64{"8": 0.1}) {
+		t.Errorf("expected alias at version 7 routing 0.1 to version 8, got version %s with weights %v", version, weights)
+	}
+	if versions := client.provisionedVersions(); !reflect.DeepEqual(versions, []string{"hello:7", "hello:8"}) {
+		t.Errorf("expected provisioned concurrency on both routed versions, got %v", versions)
+	}
+}
+
+func TestDeployWithoutCanaryEndsPreviousCanary(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.setAlias("hello", "live", "7")
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\ncanaryWeight: 0.1\n"), testOptions(client))
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\n"), testOptions(client))
+
+	if version, weights := client.alias("hello", "live"); version != "9" || len(weights) != 0 {
+		t.Errorf("expected all traffic on version 9, got version %s with weights %v", version, weights)
+	}
+}
+
+func TestDeployCanaryCreatesMissingAlias(t *testing.T) {
+	client := newFakeLambda("hello")
+
+	result := deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\ncanaryWeight: 0.1\n"), testOptions(client))
+
+	if version, weights := client.alias("hello", "live"); version != result.Version || len(weights) != 0 {
+		t.Errorf("expected the new alias to point to version %s, got version %s with weights %v", result.Version, version, weights)
+	}
+}
+
+func TestParseValidatesCanaryWeight(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{"all traffic", "alias: live\ncanaryWeight: 1\n", "canaryWeight: must be < 1"},
+		{"without alias", "canaryWeight: 0.1\n", "canaryWeight requires an alias"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTestConfig(t, "name: hello\nfileName: main.go\n"+test.config, "main.go", testMain)
+
+			expectConfigError(t, err, test.message)
+		})
+	}
+	if err := (&FunctionConfig{Name: "hello", FileName: "main.go", Alias: "live", CanaryWeight: 1}).validate(); err == nil || err.Error() != "canaryWeight 1 must be at least 0 and below 1" {
+		t.Errorf("expected validate to agree with the schema, got %v", err)
+	}
+}
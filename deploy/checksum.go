@@ -0,0 +1,111 @@
+package deploy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// LoadSigningKey reads the PEM encoded private key at path used for Options.SigningKey.
+// PKCS#8 keys (RSA, ECDSA and Ed25519) as well as PKCS#1 RSA and SEC 1 EC keys are supported.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM encoded key found in %s", path)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case *ecdsa.PrivateKey:
+			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T in %s", key, path)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %s in %s, expected a private key", block.Type, path)
+}
+
+// prepareSignatureDir creates the signature directory and checks that it is writable.
+// Deploy calls it before anything is uploaded, so a bad directory fails the run before the first function changes.
+func prepareSignatureDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	probe, err := ioutil.TempFile(dir, ".lambda-ci-signature")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// verifyCodeSha256 compares the SHA-256 of the uploaded zip with the base64 encoded CodeSha256 reported by Lambda.
+// Returns the hex encoded SHA-256 of the zip.
+func (conf *FunctionConfig) verifyCodeSha256(codeSha256 string) (string, error) {
+	sum, err := fileSha256(conf.getZipOutputPath())
+	if err != nil {
+		return "", err
+	}
+	if expected := base64.StdEncoding.EncodeToString(sum); codeSha256 != expected {
+		return "", fmt.Errorf("code hash %s reported by Lambda doesn't match the hash %s of the uploaded zip", codeSha256, expected)
+	}
+	logrus.Debugf("verified code hash %s of lambda function %s", codeSha256, conf.Name)
+	return hex.EncodeToString(sum), nil
+}
+
+// signChecksum writes the checksum of the zip to <Options.SignatureDir>/<function>.zip.sha256 in the sha256sum format
+// and its signature with Options.SigningKey to <function>.zip.sha256.sig.
+// RSA and ECDSA keys sign the SHA-256 of the zip, so the signature verifies against the zip itself,
+// e.g. with openssl dgst -sha256 -verify. Ed25519 keys sign the raw 32 byte hash.
+func (d *deployer) signChecksum(conf *FunctionConfig, hexSha256 string) error {
+	sum, err := hex.DecodeString(hexSha256)
+	if err != nil {
+		return err
+	}
+	opts := crypto.SignerOpts(crypto.SHA256)
+	if _, ok := d.opts.SigningKey.(ed25519.PrivateKey); ok {
+		opts = crypto.Hash(0)
+	}
+	signature, err := d.opts.SigningKey.Sign(rand.Reader, sum, opts)
+	if err != nil {
+		return fmt.Errorf("error while signing checksum: %w", err)
+	}
+
+	zipName := conf.getBuildName() + ".zip"
+	path := filepath.Join(d.opts.SignatureDir, zipName+".sha256")
+	if err := ioutil.WriteFile(path, []byte(fmt.Sprintf("%s  %s\n", hexSha256, zipName)), 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".sig", signature, 0644); err != nil {
+		return err
+	}
+	logrus.Infof("signed checksum of lambda function %s into %s.sig", conf.Name, path)
+	return nil
+}
@@ -0,0 +1,155 @@
+package deploy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readSignedChecksum reads the checksum and the signature of the hello zip in dir.
+// It checks that the checksum file is in the sha256sum format and returns the decoded hash.
+func readSignedChecksum(t *testing.T, dir string) ([]byte, []byte) {
+	t.Helper()
+	checksum, err := ioutil.ReadFile(filepath.Join(dir, "hello.zip.sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(string(checksum))
+	if len(fields) != 2 || fields[1] != "hello.zip" || !strings.HasSuffix(string(checksum), "\n") {
+		t.Fatalf("expected a sha256sum line for hello.zip, got %q", checksum)
+	}
+	sum, err := hex.DecodeString(fields[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ioutil.ReadFile(filepath.Join(dir, "hello.zip.sha256.sig"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sum, signature
+}
+
+func TestDeployRejectsMismatchingCodeHash(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.codeSha256 = "bWlzbWF0Y2g="
+	opts := testOptions(client)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	opts.SigningKey = key
+	opts.SignatureDir = t.TempDir()
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\n")}, opts)
+
+	if err == nil || !strings.Contains(err.Error(), "code hash bWlzbWF0Y2g= reported by Lambda doesn't match the hash") {
+		t.Errorf("expected the mismatch to be detected, got %v", err)
+	}
+	if files, _ := ioutil.ReadDir(opts.SignatureDir); len(files) != 0 {
+		t.Errorf("expected no signed checksum, got %d files", len(files))
+	}
+}
+
+func TestDeploySignsVerifiedChecksum(t *testing.T) {
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	opts.SigningKey = key
+	opts.SignatureDir = filepath.Join(t.TempDir(), "signatures")
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+	sum, signature := readSignedChecksum(t, opts.SignatureDir)
+	if uploaded := sha256.Sum256(client.zips["hello"]); hex.EncodeToString(uploaded[:]) != hex.EncodeToString(sum) {
+		t.Errorf("expected the checksum of the uploaded zip, got %x", sum)
+	}
+	if !ecdsa.VerifyASN1(&key.PublicKey, sum, signature) {
+		t.Error("expected the signature to verify against the checksum")
+	}
+}
+
+func TestDeploySignsIntoWorkingDirectoryByDefault(t *testing.T) {
+	dir := t.TempDir()
+	previous, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	opts.SigningKey = key
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+	sum, signature := readSignedChecksum(t, dir)
+	if !ed25519.Verify(key.Public().(ed25519.PublicKey), sum, signature) {
+		t.Error("expected the Ed25519 signature to verify against the checksum")
+	}
+}
+
+func TestDeployChecksSignatureDirBeforeUploading(t *testing.T) {
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	opts.SigningKey = key
+	file := filepath.Join(t.TempDir(), "file")
+	writeFiles(t, filepath.Dir(file), "file", "")
+	opts.SignatureDir = filepath.Join(file, "signatures")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\n")}, opts)
+
+	if err == nil || !strings.Contains(err.Error(), "error while preparing signature directory") {
+		t.Errorf("expected the signature directory to be rejected, got %v", err)
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected no calls, got %v", client.calls)
+	}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecBytes, _ := x509.MarshalECPrivateKey(ecKey)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	tests := []struct {
+		name  string
+		block *pem.Block
+	}{
+		{"pkcs1 rsa", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}},
+		{"sec1 ec", &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecBytes}},
+		{"pkcs8 ed25519", &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "key.pem")
+			writeFiles(t, filepath.Dir(path), "key.pem", string(pem.EncodeToMemory(test.block)))
+
+			if key, err := LoadSigningKey(path); err != nil || key == nil {
+				t.Errorf("expected the key to be loaded, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadSigningKeyRejectsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "plain.txt", "not a key", "cert.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("cert")})))
+
+	if _, err := LoadSigningKey(filepath.Join(dir, "plain.txt")); err == nil || !strings.HasPrefix(err.Error(), "no PEM encoded key found") {
+		t.Errorf("expected a missing PEM block to be reported, got %v", err)
+	}
+	if _, err := LoadSigningKey(filepath.Join(dir, "cert.pem")); err == nil || !strings.HasPrefix(err.Error(), "unsupported PEM block CERTIFICATE") {
+		t.Errorf("expected the certificate to be rejected, got %v", err)
+	}
+}
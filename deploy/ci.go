@@ -0,0 +1,63 @@
+package deploy
+
+import (
+	"strings"
+)
+
+// Tag keys set by Options.StampCI.
+const (
+	ciProviderTagKey = "lambda-ci:ci-provider"
+	ciBuildTagKey    = "lambda-ci:ci-build"
+	ciBuildURLTagKey = "lambda-ci:ci-build-url"
+)
+
+// ciProvider describes how to read the build of a CI provider from its environment variables.
+type ciProvider struct {
+	name string
+	// detect is set by the provider in every build
+	detect string
+	build  string
+	// buildURL returns the URL of the build
+	buildURL func(getenv func(string) string) string
+}
+
+// ciProviders are the CI providers detected by Options.StampCI, in detection order.
+var ciProviders = []ciProvider{
+	{name: "github-actions", detect: "GITHUB_ACTIONS", build: "GITHUB_RUN_ID", buildURL: func(getenv func(string) string) string {
+		if getenv("GITHUB_SERVER_URL") == "" || getenv("GITHUB_REPOSITORY") == "" || getenv("GITHUB_RUN_ID") == "" {
+			return ""
+		}
+		return strings.Join([]string{getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"), "actions", "runs", getenv("GITHUB_RUN_ID")}, "/")
+	}},
+	{name: "gitlab", detect: "GITLAB_CI", build: "CI_PIPELINE_ID", buildURL: envValue("CI_PIPELINE_URL")},
+	{name: "circleci", detect: "CIRCLECI", build: "CIRCLE_BUILD_NUM", buildURL: envValue("CIRCLE_BUILD_URL")},
+	{name: "buildkite", detect: "BUILDKITE", build: "BUILDKITE_BUILD_NUMBER", buildURL: envValue("BUILDKITE_BUILD_URL")},
+	{name: "travis", detect: "TRAVIS", build: "TRAVIS_BUILD_NUMBER", buildURL: envValue("TRAVIS_BUILD_WEB_URL")},
+	{name: "jenkins", detect: "JENKINS_URL", build: "BUILD_NUMBER", buildURL: envValue("BUILD_URL")},
+}
+
+// envValue returns a buildURL func reading the environment variable name.
+func envValue(name string) func(getenv func(string) string) string {
+	return func(getenv func(string) string) string {
+		return getenv(name)
+	}
+}
+
+// getCITags detects the CI provider from the environment and returns the tags identifying the build.
+// Empty values are left out, nil is returned if no provider is detected.
+func getCITags(getenv func(string) string) map[string]string {
+	for _, provider := range ciProviders {
+		if getenv(provider.detect) == "" {
+			continue
+		}
+		tags := map[string]string{ciProviderTagKey: provider.name}
+		if build := getenv(provider.build); build != "" {
+			tags[ciBuildTagKey] = build
+		}
+		if url := provider.buildURL(getenv); url != "" {
+			tags[ciBuildURLTagKey] = url
+		}
+		return tags
+	}
+	return nil
+}
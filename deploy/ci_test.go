@@ -0,0 +1,46 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetCITags(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected map[string]string
+	}{
+		{"github actions", map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_RUN_ID": "42", "GITHUB_SERVER_URL": "https://github.com", "GITHUB_REPOSITORY": "acme/orders"},
+			map[string]string{ciProviderTagKey: "github-actions", ciBuildTagKey: "42", ciBuildURLTagKey: "https://github.com/acme/orders/actions/runs/42"}},
+		{"gitlab", map[string]string{"GITLAB_CI": "true", "CI_PIPELINE_ID": "7", "CI_PIPELINE_URL": "https://gitlab.com/acme/orders/-/pipelines/7"},
+			map[string]string{ciProviderTagKey: "gitlab", ciBuildTagKey: "7", ciBuildURLTagKey: "https://gitlab.com/acme/orders/-/pipelines/7"}},
+		{"missing values are left out", map[string]string{"JENKINS_URL": "https://ci.example.com"}, map[string]string{ciProviderTagKey: "jenkins"}},
+		{"no provider", map[string]string{"BUILD_NUMBER": "3"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			getenv := func(name string) string { return test.env[name] }
+
+			if tags := getCITags(getenv); !reflect.DeepEqual(tags, test.expected) {
+				t.Errorf("expected tags %v, got %v", test.expected, tags)
+			}
+		})
+	}
+}
+
+func TestDeployStampsCIBuild(t *testing.T) {
+	for name, value := range map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_RUN_ID": "42", "GITHUB_SERVER_URL": "https://github.com", "GITHUB_REPOSITORY": "acme/orders"} {
+		t.Setenv(name, value)
+	}
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	opts.StampCI = true
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+	expected := map[string]string{ciProviderTagKey: "github-actions", ciBuildTagKey: "42", ciBuildURLTagKey: "https://github.com/acme/orders/actions/runs/42"}
+	if tags := client.tags["arn:aws:lambda:eu-central-1:"+testAccount+":function:hello"]; !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected tags %v, got %v", expected, tags)
+	}
+}
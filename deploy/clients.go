@@ -0,0 +1,223 @@
+package deploy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/processcreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/signer"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// NewHTTPClient creates a HTTP client for Options.HTTPClient that honors HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+// If caBundle is set, the PEM certificates in that file are trusted in addition to the system certificates,
+// e.g. for a TLS intercepting proxy.
+func NewHTTPClient(caBundle string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if caBundle != "" {
+		pem, err := ioutil.ReadFile(caBundle)
+		if err != nil {
+			return nil, err
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %s", caBundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// newSession creates a session from the environment and the shared config files.
+// Loading the shared config enables credential_process and SSO profiles.
+// The session uses httpClient if it is set.
+func newSession(httpClient *http.Client) (*session.Session, error) {
+	return session.NewSessionWithOptions(session.Options{
+		Config:            aws.Config{HTTPClient: httpClient},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+}
+
+// newDeployer creates a deployer using the clients of opts and creates the missing ones.
+func newDeployer(opts Options) (*deployer, error) {
+	d := &deployer{opts: opts, lambda: opts.Lambda, s3: opts.S3, sts: opts.STS, logs: opts.CloudWatchLogs, kms: opts.KMS, signer: opts.Signer, sess: opts.Session, diffMutex: &sync.Mutex{}, modules: newModuleWarmer(), generator: newModuleGenerator()}
+	if d.sess == nil && (d.lambda == nil || d.s3 == nil || d.sts == nil || d.logs == nil || d.kms == nil || d.signer == nil || opts.SSM == nil || opts.SecretsManager == nil) {
+		var err error
+		d.sess, err = newSession(opts.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if d.sess != nil {
+		d.region = aws.StringValue(d.sess.Config.Region)
+	}
+	d.clientConfig = aws.NewConfig()
+	if opts.MaxRetries != nil {
+		d.clientConfig = d.clientConfig.WithMaxRetries(*opts.MaxRetries)
+	}
+	if opts.HTTPClient != nil {
+		d.clientConfig = d.clientConfig.WithHTTPClient(opts.HTTPClient)
+	}
+
+	if d.lambda == nil {
+		d.lambda = d.newLambdaClient(d.clientConfig)
+	}
+	if d.s3 == nil {
+		d.s3 = d.newS3Client(d.clientConfig)
+	}
+	if d.sts == nil {
+		d.sts = d.newSTSClient(d.clientConfig)
+	}
+	if d.logs == nil {
+		d.logs = d.newCloudWatchLogsClient(d.clientConfig)
+	}
+	if d.kms == nil {
+		d.kms = d.newKMSClient(d.clientConfig)
+	}
+	if d.signer == nil {
+		d.signer = d.newSignerClient(d.clientConfig)
+	}
+	if opts.StampCI {
+		d.ciTags = getCITags(os.Getenv)
+		if d.ciTags == nil {
+			logrus.Warn("no CI provider detected, the functions are deployed without CI tags")
+		}
+	}
+	d.resolver = &valueResolver{ssm: opts.SSM, secretsManager: opts.SecretsManager, lambda: d.lambda, cache: &resolvedValues{values: map[string]string{}}}
+	if d.resolver.ssm == nil {
+		d.resolver.ssm = d.newSSMClient(d.clientConfig)
+	}
+	if d.resolver.secretsManager == nil {
+		d.resolver.secretsManager = d.newSecretsManagerClient(d.clientConfig)
+	}
+	return d, nil
+}
+
+// newLambdaClient creates a Lambda client from the session of the deployer.
+func (d *deployer) newLambdaClient(config *aws.Config) *lambda.Lambda {
+	client := lambda.New(d.sess, config)
+	addRetryHandlers(&client.Handlers)
+	return client
+}
+
+// newS3Client creates a S3 client from the session of the deployer.
+func (d *deployer) newS3Client(config *aws.Config) *s3.S3 {
+	client := s3.New(d.sess, config)
+	addRetryHandlers(&client.Handlers)
+	return client
+}
+
+// newSTSClient creates a STS client from the session of the deployer.
+func (d *deployer) newSTSClient(config *aws.Config) *sts.STS {
+	client := sts.New(d.sess, config)
+	addRetryHandlers(&client.Handlers)
+	return client
+}
+
+// newCloudWatchLogsClient creates a CloudWatch Logs client from the session of the deployer.
+func (d *deployer) newCloudWatchLogsClient(config *aws.Config) *cloudwatchlogs.CloudWatchLogs {
+	client := cloudwatchlogs.New(d.sess, config)
+	addRetryHandlers(&client.Handlers)
+	return client
+}
+
+// newKMSClient creates a KMS client from the session of the deployer.
+func (d *deployer) newKMSClient(config *aws.Config) *kms.KMS {
+	client := kms.New(d.sess, config)
+	addRetryHandlers(&client.Handlers)
+	return client
+}
+
+// newSignerClient creates a Signer client from the session of the deployer.
+func (d *deployer) newSignerClient(config *aws.Config) *signer.Signer {
+	client := signer.New(d.sess, config)
+	addRetryHandlers(&client.Handlers)
+	return client
+}
+
+// newSSMClient creates a SSM client from the session of the deployer.
+func (d *deployer) newSSMClient(config *aws.Config) *ssm.SSM {
+	client := ssm.New(d.sess, config)
+	addRetryHandlers(&client.Handlers)
+	return client
+}
+
+// newSecretsManagerClient creates a Secrets Manager client from the session of the deployer.
+func (d *deployer) newSecretsManagerClient(config *aws.Config) *secretsmanager.SecretsManager {
+	client := secretsmanager.New(d.sess, config)
+	addRetryHandlers(&client.Handlers)
+	return client
+}
+
+// forFunction returns the deployer to use for the given function.
+// Functions with a Region, Edge or CredentialProcess get their own Lambda, S3, STS, CloudWatch Logs, KMS, Signer,
+// SSM and Secrets Manager clients, unless the clients were injected through Options.
+// Their environment references resolve with these clients and are cached separately per region and credentials.
+func (d *deployer) forFunction(conf *FunctionConfig) (*deployer, error) {
+	region := conf.getRegion()
+	if region == "" && conf.CredentialProcess == "" {
+		return d, nil
+	}
+
+	config := d.clientConfig.Copy()
+	fd := *d
+	if region != "" {
+		config = config.WithRegion(region)
+		fd.region = region
+	}
+	if conf.CredentialProcess != "" {
+		credentials := processcreds.NewCredentials(conf.CredentialProcess)
+		if _, err := credentials.Get(); err != nil {
+			return nil, err
+		}
+		config = config.WithCredentials(credentials)
+	}
+
+	if d.opts.Lambda == nil {
+		fd.lambda = d.newLambdaClient(config)
+	}
+	if d.opts.S3 == nil {
+		fd.s3 = d.newS3Client(config)
+	}
+	if d.opts.STS == nil {
+		fd.sts = d.newSTSClient(config)
+	}
+	if d.opts.CloudWatchLogs == nil {
+		fd.logs = d.newCloudWatchLogsClient(config)
+	}
+	if d.opts.KMS == nil {
+		fd.kms = d.newKMSClient(config)
+	}
+	if d.opts.Signer == nil {
+		fd.signer = d.newSignerClient(config)
+	}
+
+	resolver := *d.resolver
+	resolver.scope = region + "\x00" + conf.CredentialProcess
+	resolver.lambda = fd.lambda
+	if d.opts.SSM == nil {
+		resolver.ssm = d.newSSMClient(config)
+	}
+	if d.opts.SecretsManager == nil {
+		resolver.secretsManager = d.newSecretsManagerClient(config)
+	}
+	fd.resolver = &resolver
+	return &fd, nil
+}
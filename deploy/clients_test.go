@@ -0,0 +1,263 @@
+package deploy
+
+import (
+	"context"
+	"encoding/pem"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewDeployerUsesInjectedClients(t *testing.T) {
+	client := newFakeLambda()
+	opts := testOptions(client)
+
+	d, err := newDeployer(opts)
+	if err != nil {
+		t.Fatalf("error while creating deployer: %v", err)
+	}
+	if d.sess != nil {
+		t.Error("expected no session if all clients are injected")
+	}
+	if d.lambda != client || d.resolver.lambda != client {
+		t.Error("expected the injected Lambda client to be used")
+	}
+	if d.resolver.ssm != opts.SSM || d.resolver.secretsManager != opts.SecretsManager {
+		t.Error("expected the injected SSM and Secrets Manager clients to be used")
+	}
+}
+
+func TestForFunctionKeepsInjectedClients(t *testing.T) {
+	client := newFakeLambda()
+	d, err := newDeployer(testOptions(client))
+	if err != nil {
+		t.Fatalf("error while creating deployer: %v", err)
+	}
+
+	fd, err := d.forFunction(&FunctionConfig{Name: "hello", Region: "us-west-2"})
+	if err != nil {
+		t.Fatalf("error while creating function deployer: %v", err)
+	}
+	if fd.lambda != client {
+		t.Error("expected the injected Lambda client to be used for functions with a region")
+	}
+}
+
+// newTestSession returns a session in eu-central-1 with static credentials.
+func newTestSession(t *testing.T) *session.Session {
+	t.Helper()
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("eu-central-1"),
+		Credentials: credentials.NewStaticCredentials("AKIDDEFAULT", "secret", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sess
+}
+
+func TestForFunctionUsesCredentialProcess(t *testing.T) {
+	d, err := newDeployer(Options{Session: newTestSession(t)})
+	if err != nil {
+		t.Fatalf("error while creating deployer: %v", err)
+	}
+	conf := &FunctionConfig{Name: "hello", Region: "us-west-2", CredentialProcess: `echo '{"Version": 1, "AccessKeyId": "AKIDPROCESS", "SecretAccessKey": "secret"}'`}
+
+	fd, err := d.forFunction(conf)
+	if err != nil {
+		t.Fatalf("error while creating function deployer: %v", err)
+	}
+
+	client := fd.lambda.(*lambda.Lambda)
+	value, err := client.Config.Credentials.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "AKIDPROCESS" || aws.StringValue(client.Config.Region) != "us-west-2" || fd.region != "us-west-2" {
+		t.Errorf("expected a us-west-2 client with the process credentials, got %s in %s", value.AccessKeyID, aws.StringValue(client.Config.Region))
+	}
+	ssmClient := fd.resolver.ssm.(*ssm.SSM)
+	if value, err := ssmClient.Config.Credentials.Get(); err != nil || value.AccessKeyID != "AKIDPROCESS" {
+		t.Errorf("expected the SSM client to use the process credentials, got %s", value.AccessKeyID)
+	}
+	if fd.resolver.lambda != fd.lambda || fd.resolver.scope == d.resolver.scope || fd.resolver.cache != d.resolver.cache {
+		t.Error("expected the resolver to use the function clients in its own scope of the shared cache")
+	}
+	if value, err := d.lambda.(*lambda.Lambda).Config.Credentials.Get(); err != nil || value.AccessKeyID != "AKIDDEFAULT" {
+		t.Errorf("expected the default client to keep the default credentials, got %s", value.AccessKeyID)
+	}
+}
+
+func TestForFunctionFailsOnCredentialProcessError(t *testing.T) {
+	d, err := newDeployer(Options{Session: newTestSession(t)})
+	if err != nil {
+		t.Fatalf("error while creating deployer: %v", err)
+	}
+
+	if _, err := d.forFunction(&FunctionConfig{Name: "hello", CredentialProcess: "false"}); err == nil {
+		t.Error("expected an error for a failing credential process")
+	}
+}
+
+func TestResolverCacheIsScoped(t *testing.T) {
+	cache := &resolvedValues{values: map[string]string{}}
+	first := &valueResolver{ssm: &fakeSSM{parameters: map[string]string{"/table": "orders-eu"}}, cache: cache, scope: "eu-central-1\x00"}
+	second := &valueResolver{ssm: &fakeSSM{parameters: map[string]string{"/table": "orders-us"}}, cache: cache, scope: "us-west-2\x00"}
+
+	for resolver, expected := range map[*valueResolver]string{first: "orders-eu", second: "orders-us"} {
+		value, err := resolver.resolve(context.Background(), "ssm:/table")
+		if err != nil {
+			t.Fatalf("error while resolving: %v", err)
+		}
+		if value != expected {
+			t.Errorf("expected %s, got %s", expected, value)
+		}
+	}
+}
+
+// writeCABundle writes the certificate of the TLS server as a PEM file and returns its path.
+func writeCABundle(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewHTTPClientTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("trusted"))
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(writeCABundle(t, server))
+	if err != nil {
+		t.Fatalf("error while creating HTTP client: %v", err)
+	}
+	response, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the server certificate to be trusted, got %v", err)
+	}
+	defer response.Body.Close()
+	if body, _ := ioutil.ReadAll(response.Body); string(body) != "trusted" {
+		t.Errorf("expected the server response, got %q", body)
+	}
+
+	client, err = NewHTTPClient("")
+	if err != nil {
+		t.Fatalf("error while creating HTTP client: %v", err)
+	}
+	if _, err := client.Get(server.URL); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("expected the server certificate to be untrusted without the bundle, got %v", err)
+	}
+}
+
+func TestNewSessionUsesHTTPClient(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"FunctionName": "hello"}`))
+	}))
+	defer server.Close()
+	t.Setenv("AWS_REGION", "eu-central-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+	// The SDK replaces the roots of the HTTP client with AWS_CA_BUNDLE if it is set
+	t.Setenv("AWS_CA_BUNDLE", "")
+	httpClient, err := NewHTTPClient(writeCABundle(t, server))
+	if err != nil {
+		t.Fatalf("error while creating HTTP client: %v", err)
+	}
+
+	sess, err := newSession(httpClient)
+	if err != nil {
+		t.Fatalf("error while creating session: %v", err)
+	}
+	client := lambda.New(sess, &aws.Config{Endpoint: aws.String(server.URL)})
+	output, err := client.GetFunctionConfiguration(&lambda.GetFunctionConfigurationInput{FunctionName: aws.String("hello")})
+
+	if err != nil || aws.StringValue(output.FunctionName) != "hello" {
+		t.Errorf("expected the Lambda call to use the HTTP client trusting the bundle, got %v", err)
+	}
+}
+
+func TestNewHTTPClientRejectsBadCABundle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(path, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewHTTPClient(path); err == nil || err.Error() != "no PEM certificates found in "+path {
+		t.Errorf("expected a bundle without certificates to be rejected, got %v", err)
+	}
+	if _, err := NewHTTPClient(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("expected a missing bundle to be rejected")
+	}
+}
+
+// proxyTargetEnv makes TestNewHTTPClientUsesProxy request its value, the proxy environment is only read once per process.
+const proxyTargetEnv = "LAMBDA_CI_PROXY_TARGET"
+
+func TestNewHTTPClientUsesProxy(t *testing.T) {
+	if target := os.Getenv(proxyTargetEnv); target != "" {
+		client, err := NewHTTPClient("")
+		if err != nil {
+			t.Fatalf("error while creating HTTP client: %v", err)
+		}
+		if response, err := client.Get(target); err == nil {
+			response.Body.Close()
+		}
+		return
+	}
+
+	tests := []struct {
+		name     string
+		variable string
+		target   string
+		expected string
+	}{
+		{"http", "HTTP_PROXY", "http://lambda.eu-central-1.amazonaws.com/2015-03-31/functions", "GET http://lambda.eu-central-1.amazonaws.com/2015-03-31/functions"},
+		{"https", "HTTPS_PROXY", "https://lambda.eu-central-1.amazonaws.com/2015-03-31/functions", "CONNECT lambda.eu-central-1.amazonaws.com:443"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := make(chan string, 1)
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case requests <- r.Method + " " + r.RequestURI:
+				default:
+				}
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer proxy.Close()
+
+			cmd := exec.Command(os.Args[0], "-test.run=^TestNewHTTPClientUsesProxy$")
+			cmd.Env = append(os.Environ(), proxyTargetEnv+"="+test.target, test.variable+"="+proxy.URL, "NO_PROXY=", "no_proxy=")
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("error while requesting through the proxy: %v\n%s", err, output)
+			}
+
+			select {
+			case request := <-requests:
+				if request != test.expected {
+					t.Errorf("expected the proxy to receive %q, got %q", test.expected, request)
+				}
+			default:
+				t.Error("expected the request to go through the proxy")
+			}
+		})
+	}
+}
@@ -0,0 +1,723 @@
+package deploy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/sirupsen/logrus"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// customRuntimeBinary is the name of the executable Lambda starts for custom runtimes.
+const customRuntimeBinary = "bootstrap"
+
+// goEnvVariables contains the Go environment variables that may be set through goEnv.
+var goEnvVariables = map[string]bool{
+	"GO386":        true,
+	"GOAMD64":      true,
+	"GOARCH":       true,
+	"GOARM":        true,
+	"GOARM64":      true,
+	"GODEBUG":      true,
+	"GOEXPERIMENT": true,
+	"GOFLAGS":      true,
+	"GOINSECURE":   true,
+	"GOMIPS":       true,
+	"GOMIPS64":     true,
+	"GONOPROXY":    true,
+	"GONOSUMDB":    true,
+	"GOOS":         true,
+	"GOPPC64":      true,
+	"GOPRIVATE":    true,
+	"GOPROXY":      true,
+	"GORISCV64":    true,
+	"GOSUMDB":      true,
+	"GOTOOLCHAIN":  true,
+	"GOWASM":       true,
+}
+
+// FunctionConfig describes a single Lambda function as declared in a .function.yaml file.
+type FunctionConfig struct {
+	Name     string `yaml:"name"`
+	FileName string `yaml:"fileName"`
+	ImageUri string `yaml:"imageUri"`
+	Path     string `yaml:"-"`
+
+	// NamePrefix and NameSuffix replace the prefix and suffix given to DecorateName for this function.
+	NamePrefix string `yaml:"namePrefix"`
+	NameSuffix string `yaml:"nameSuffix"`
+
+	// FileNames lists several Go files of the function directory that are built together into one binary.
+	// It replaces fileName for functions split across multiple files.
+	FileNames []string `yaml:"fileNames"`
+	// Bootstrap is the path of an already built binary relative to the function directory, e.g. a Rust or shell binary.
+	// It is zipped as bootstrap for a provided.* runtime instead of building Go code.
+	Bootstrap string `yaml:"bootstrap"`
+	// Binaries are additional Go binaries built and zipped next to the function binary, see BinarySpec.
+	Binaries []BinarySpec `yaml:"binaries"`
+	// Extensions are paths of prebuilt extension binaries relative to the function directory.
+	// They are zipped as extensions/<file name>, which Lambda mounts at /opt/extensions.
+	Extensions []string `yaml:"extensions"`
+
+	// buildDir is the temporary directory the artifacts of the current deploy are written to.
+	buildDir string
+	// resolvedEnvironment is the Environment with all SSM and Secrets Manager references resolved.
+	resolvedEnvironment map[string]string
+	// liveConfig caches the live configuration of the function, see getLiveConfig.
+	liveConfig *lambda.FunctionConfiguration
+	// buildInfo is the build metadata added to the zip archive, see createBuildInfo.
+	buildInfo []byte
+	// buildTrace receives the go build -x output while Options.BuildProfileDir is set.
+	buildTrace *os.File
+	// sourceDir is the fetched Source, see getSourceDir.
+	sourceDir string
+	// replaceModFile is the temporary go.mod with the Replace directives, see createReplaceModFile.
+	replaceModFile string
+	// sourceSha256 is the hex encoded hash of the parsed config, used to detect stale plans.
+	sourceSha256 string
+	// plan and plannedZip are set by ApplyPlan, the function is deployed with the planned zip instead of being built.
+	plan       *PlannedFunction
+	plannedZip string
+
+	// ZipEntryName is the path of the binary inside the zip archive.
+	// Defaults to the function name.
+	ZipEntryName string `yaml:"zipEntryName"`
+
+	// IncludeDir is a directory relative to the function directory whose files are added to the zip archive.
+	// Entries keep their relative path, files matched by a .lambdaignore inside the directory are skipped.
+	IncludeDir string `yaml:"includeDir"`
+
+	// PackageType is the expected package type of the function, Zip or Image.
+	// It must match the configured artifact, Image requires an imageUri.
+	PackageType string `yaml:"packageType"`
+
+	// Runtime is the expected runtime of the function, e.g. go1.x or provided.al2023.
+	Runtime string `yaml:"runtime"`
+	// Handler overrides the handler derived from the runtime, see handlerForRuntime.
+	Handler string `yaml:"handler"`
+	// HandlerRule replaces the derivation of the handler from the runtime, one of the HandlerRule constants.
+	HandlerRule string `yaml:"handlerRule"`
+	// Architecture is the instruction set the function runs on, x86_64 or arm64.
+	// Defaults to x86_64 and determines GOARCH for the build.
+	Architecture string `yaml:"architecture"`
+
+	// Replace adds replace directives for the build, e.g. to build against a local checkout of a shared library.
+	// Keys are module paths, optionally with a version, values are module paths or directories relative to the function.
+	// The directives are added to a temporary copy of go.mod, the module itself is never edited.
+	Replace map[string]string `yaml:"replace"`
+
+	// GoEnv holds additional GO* environment variables for the build, e.g. GOARM or GOAMD64.
+	// They are applied after the GOOS/GOARCH defaults and may override them.
+	GoEnv map[string]string `yaml:"goEnv"`
+	// GoToolchain pins the Go toolchain used for the build through GOTOOLCHAIN, e.g. go1.22.3.
+	GoToolchain string `yaml:"goToolchain"`
+
+	// Source is the directory or archive the function is built from instead of the function directory,
+	// relative paths are resolved against the function directory. Archives (.zip, .tar, .tar.gz or .tgz)
+	// can also be http or https URLs, they are downloaded and extracted before the build.
+	Source string `yaml:"source"`
+	// SourceChecksum is the hex encoded SHA-256 the Source archive must have.
+	SourceChecksum string `yaml:"sourceChecksum"`
+	// GoGenerate runs go generate ./... in the root of the module before the function is built,
+	// once per module and deploy.
+	GoGenerate bool `yaml:"goGenerate"`
+	// PostBuild is a command run in the function directory after the build and before zipping.
+	PostBuild []string `yaml:"postBuild"`
+	// PreDeploy is a command run in the function directory after zipping and before the update.
+	// The path of the zip file, or the image URI, is passed in the LAMBDA_CI_ARTIFACT environment variable.
+	PreDeploy []string `yaml:"preDeploy"`
+	// DeployIf is a command run in the function directory before anything else.
+	// The function is only deployed if it exits with zero, otherwise it is skipped.
+	DeployIf []string `yaml:"deployIf"`
+
+	// Labels are free form tags used to deploy subsets of the functions, see SelectLabeled.
+	Labels []string `yaml:"labels"`
+
+	// DependsOn lists the names of functions that must be deployed before this one,
+	// e.g. because their ARN is resolved into the environment. See SortByDependencies.
+	DependsOn []string `yaml:"dependsOn"`
+
+	// ManagedTagKey and ManagedTagValue override the tag marking the function as managed by lambda-ci,
+	// see Options.ManagedTagKey.
+	ManagedTagKey   string `yaml:"managedTagKey"`
+	ManagedTagValue string `yaml:"managedTagValue"`
+
+	// Region overrides the AWS region the function is deployed to.
+	Region string `yaml:"region"`
+	// AllowedAccounts replaces Options.AllowedAccounts for this function.
+	AllowedAccounts []string `yaml:"allowedAccounts"`
+	// BranchGuard replaces Options.BranchGuard for this function.
+	BranchGuard string `yaml:"branchGuard"`
+
+	// CredentialProcess is a command printing AWS credentials, like credential_process in the AWS config.
+	// When set, the function is deployed with these credentials instead of the default ones.
+	CredentialProcess string `yaml:"credentialProcess"`
+
+	// SigningProfile is the AWS Signer profile the zip is signed with before it is deployed,
+	// as required by functions with a code signing config. Requires Options.ArtifactBucket to be versioned.
+	SigningProfile string `yaml:"signingProfile"`
+
+	// Alias is shifted to a newly published version after each update.
+	Alias string `yaml:"alias"`
+	// Publish publishes a new version after each update, also without an Alias.
+	Publish bool `yaml:"publish"`
+	// Edge deploys the function for Lambda@Edge: it is deployed to us-east-1 and every published version ARN is logged
+	// for the CloudFront association. Requires Publish, environment variables aren't supported.
+	Edge bool `yaml:"edge"`
+	// LogRetentionDays is the retention of the log group of the function, the log group is created if it is missing.
+	// Must be one of the periods CloudWatch Logs accepts. The retention is left as is if not set.
+	LogRetentionDays int64 `yaml:"logRetentionDays"`
+	// ReservedConcurrency is the number of concurrent executions reserved for the function.
+	// It is left as is if not set.
+	ReservedConcurrency *int64 `yaml:"reservedConcurrency"`
+	// RemoveReservedConcurrency removes the reserved concurrency, so the function uses the unreserved account concurrency.
+	RemoveReservedConcurrency bool `yaml:"removeReservedConcurrency"`
+	// ProvisionedConcurrency is configured on the new version before the alias is shifted.
+	ProvisionedConcurrency int64 `yaml:"provisionedConcurrency"`
+	// ProvisionedConcurrencyTimeout bounds the wait for provisioned concurrency to become ready.
+	ProvisionedConcurrencyTimeout time.Duration `yaml:"provisionedConcurrencyTimeout"`
+	// CanaryWeight routes only this share of the alias traffic, between 0 and 1, to the new version.
+	// The alias keeps pointing to its previous version for the rest, deploy with 0 to shift all traffic.
+	CanaryWeight float64 `yaml:"canaryWeight"`
+
+	// MemorySize is the memory of the function in MB, left untouched if zero.
+	MemorySize int64 `yaml:"memorySize"`
+	// Timeout is the timeout of the function in seconds, left untouched if zero.
+	Timeout int64 `yaml:"timeout"`
+	// Layers replaces the layer ARNs of the function, an empty list removes all layers.
+	// The live layers are left untouched if it is not set.
+	Layers []string `yaml:"layers"`
+	// LayersMode is LayersModeReplace (default) or LayersModeMerge, which adds the Layers to the live layers.
+	LayersMode string `yaml:"layersMode"`
+
+	// HealthCheck invokes the function after the update. With an alias the new version is checked
+	// before the alias is shifted, so a failing version never receives traffic.
+	HealthCheck *HealthCheck `yaml:"healthCheck"`
+
+	// Environment replaces the environment variables of the function.
+	// Values of the form ssm:/path or secretsmanager:arn are resolved at deploy time.
+	// The live environment is left untouched if it is not set.
+	Environment map[string]string `yaml:"environment"`
+
+	// LoggingConfig configures the log format, log levels and log group of the function.
+	// The live logging config is left untouched if it is not set.
+	LoggingConfig *LoggingConfig `yaml:"loggingConfig"`
+
+	// ManageHandler controls whether the handler of the function is reconciled with its name.
+	// Defaults to true, set it to false if the handler is managed outside of lambda-ci.
+	ManageHandler *bool `yaml:"manageHandler"`
+}
+
+// LoggingConfig describes the logging configuration of a function.
+type LoggingConfig struct {
+	LogFormat           string `yaml:"logFormat"`
+	ApplicationLogLevel string `yaml:"applicationLogLevel"`
+	SystemLogLevel      string `yaml:"systemLogLevel"`
+	LogGroup            string `yaml:"logGroup"`
+}
+
+// validate checks the enum values of the LoggingConfig.
+func (conf *LoggingConfig) validate() error {
+	if conf.LogFormat != "" && !contains(lambda.LogFormat_Values(), conf.LogFormat) {
+		return fmt.Errorf("loggingConfig logFormat %s must be one of %s", conf.LogFormat, strings.Join(lambda.LogFormat_Values(), ", "))
+	}
+	if conf.ApplicationLogLevel != "" && !contains(lambda.ApplicationLogLevel_Values(), conf.ApplicationLogLevel) {
+		return fmt.Errorf("loggingConfig applicationLogLevel %s must be one of %s", conf.ApplicationLogLevel, strings.Join(lambda.ApplicationLogLevel_Values(), ", "))
+	}
+	if conf.SystemLogLevel != "" && !contains(lambda.SystemLogLevel_Values(), conf.SystemLogLevel) {
+		return fmt.Errorf("loggingConfig systemLogLevel %s must be one of %s", conf.SystemLogLevel, strings.Join(lambda.SystemLogLevel_Values(), ", "))
+	}
+	if (conf.ApplicationLogLevel != "" || conf.SystemLogLevel != "") && conf.LogFormat != lambda.LogFormatJson {
+		return fmt.Errorf("loggingConfig log levels require logFormat %s", lambda.LogFormatJson)
+	}
+	return nil
+}
+
+// toLambda converts the LoggingConfig to its Lambda API representation.
+func (conf *LoggingConfig) toLambda() *lambda.LoggingConfig {
+	loggingConfig := &lambda.LoggingConfig{}
+	if conf.LogFormat != "" {
+		loggingConfig.LogFormat = &conf.LogFormat
+	}
+	if conf.ApplicationLogLevel != "" {
+		loggingConfig.ApplicationLogLevel = &conf.ApplicationLogLevel
+	}
+	if conf.SystemLogLevel != "" {
+		loggingConfig.SystemLogLevel = &conf.SystemLogLevel
+	}
+	if conf.LogGroup != "" {
+		loggingConfig.LogGroup = &conf.LogGroup
+	}
+	return loggingConfig
+}
+
+// contains reports whether values contains value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// getRuntime returns the declared runtime of the function.
+// Without a declared runtime the runtime of the live function is used once it was looked up,
+// so the binary name and handler of a provided.* function are derived correctly without declaring it.
+func (conf *FunctionConfig) getRuntime() string {
+	if conf.Runtime == "" && conf.liveConfig != nil {
+		return aws.StringValue(conf.liveConfig.Runtime)
+	}
+	return conf.Runtime
+}
+
+// isCustomRuntime reports whether the function runs on an OS-only runtime like provided.al2023.
+// Functions with a precompiled Bootstrap always do.
+func (conf *FunctionConfig) isCustomRuntime() bool {
+	return conf.Bootstrap != "" || strings.HasPrefix(conf.getRuntime(), "provided")
+}
+
+// Values of FunctionConfig.HandlerRule.
+const (
+	// HandlerRuleName expects the build name as handler, or the ZipEntryName if set, regardless of the runtime.
+	HandlerRuleName = "name"
+	// HandlerRuleBootstrap expects bootstrap as handler, regardless of the runtime.
+	HandlerRuleBootstrap = "bootstrap"
+	// HandlerRuleExplicit expects the declared Handler, which must be set.
+	HandlerRuleExplicit = "explicit"
+	// HandlerRuleNone leaves the handler as it is, like setting ManageHandler to false.
+	HandlerRuleNone = "none"
+)
+
+// Values of FunctionConfig.LayersMode.
+const (
+	// LayersModeReplace sets exactly the declared layers, other live layers are removed.
+	LayersModeReplace = "replace"
+	// LayersModeMerge keeps the live layers and adds the declared ones, declared versions replace live versions of the same layer.
+	LayersModeMerge = "merge"
+)
+
+// handlerRules contains the valid values of FunctionConfig.HandlerRule.
+var handlerRules = []string{HandlerRuleName, HandlerRuleBootstrap, HandlerRuleExplicit, HandlerRuleNone}
+
+// handlerForRuntime returns the handler the function is expected to have.
+// The HandlerRule decides if set. Otherwise an explicit Handler always wins, custom runtimes execute
+// the bootstrap binary and for go1.x the handler is the path of the compiled binary in the zip, see getZipEntryName.
+func handlerForRuntime(conf *FunctionConfig) string {
+	switch conf.HandlerRule {
+	case HandlerRuleName:
+		if conf.ZipEntryName != "" {
+			return conf.ZipEntryName
+		}
+		return conf.getBuildName()
+	case HandlerRuleBootstrap:
+		return customRuntimeBinary
+	}
+	if conf.Handler != "" {
+		return conf.Handler
+	}
+	if conf.isCustomRuntime() {
+		return customRuntimeBinary
+	}
+	return conf.getZipEntryName()
+}
+
+// managesHandler reports whether the handler of the function should be reconciled.
+func (conf *FunctionConfig) managesHandler() bool {
+	if conf.HandlerRule == HandlerRuleNone {
+		return false
+	}
+	return conf.ManageHandler == nil || *conf.ManageHandler
+}
+
+// validateHandlerRule checks the HandlerRule and that the handler fields fit it.
+func (conf *FunctionConfig) validateHandlerRule() error {
+	if conf.HandlerRule == "" {
+		return nil
+	}
+	if !contains(handlerRules, conf.HandlerRule) {
+		return fmt.Errorf("handlerRule %s must be one of %s", conf.HandlerRule, strings.Join(handlerRules, ", "))
+	}
+	if conf.HandlerRule == HandlerRuleExplicit && conf.Handler == "" {
+		return errors.New("handlerRule explicit requires handler to be set")
+	}
+	if conf.HandlerRule != HandlerRuleExplicit && conf.Handler != "" {
+		return fmt.Errorf("handler can't be used with handlerRule %s", conf.HandlerRule)
+	}
+	if conf.HandlerRule == HandlerRuleNone && conf.ManageHandler != nil && *conf.ManageHandler {
+		return errors.New("handlerRule none can't be used with manageHandler true")
+	}
+	return nil
+}
+
+// validate checks that the FunctionConfig is complete and has no conflicting fields.
+func (conf *FunctionConfig) validate() error {
+	if conf.Name == "" {
+		return errors.New("name must be set")
+	}
+	if _, _, ok := splitFunctionName(conf.Name); !ok {
+		return fmt.Errorf("name %s must be a function name, name:qualifier or function ARN", conf.Name)
+	}
+	if conf.NamePrefix != "" && !isFunctionNameAffix(conf.NamePrefix) {
+		return fmt.Errorf("namePrefix %s may only contain letters, digits, - and _", conf.NamePrefix)
+	}
+	if conf.NameSuffix != "" && !isFunctionNameAffix(conf.NameSuffix) {
+		return fmt.Errorf("nameSuffix %s may only contain letters, digits, - and _", conf.NameSuffix)
+	}
+	if conf.FileName != "" && len(conf.FileNames) > 0 {
+		return errors.New("fileName and fileNames must not be set both")
+	}
+	if conf.ImageUri != "" && (conf.FileName != "" || len(conf.FileNames) > 0) {
+		return errors.New("imageUri and fileName must not be set both")
+	}
+	if conf.Bootstrap != "" && (conf.FileName != "" || len(conf.FileNames) > 0 || conf.ImageUri != "") {
+		return errors.New("bootstrap must not be combined with fileName, fileNames or imageUri")
+	}
+	if conf.ImageUri == "" && conf.Bootstrap == "" && conf.FileName == "" && len(conf.FileNames) == 0 {
+		return errors.New("either fileName, fileNames, bootstrap or imageUri must be set")
+	}
+	if conf.Bootstrap != "" && !isCleanRelativePath(conf.Bootstrap) {
+		return fmt.Errorf("bootstrap %s must be a clean relative path", conf.Bootstrap)
+	}
+	if conf.Bootstrap != "" && conf.Runtime != "" && !strings.HasPrefix(conf.Runtime, "provided") {
+		return fmt.Errorf("bootstrap requires a provided.* runtime, got %s", conf.Runtime)
+	}
+	for _, fileName := range conf.FileNames {
+		if fileName == "" || strings.ContainsAny(fileName, "/\\") {
+			return fmt.Errorf("fileNames entry %q must be a file in the function directory", fileName)
+		}
+	}
+	switch conf.PackageType {
+	case "":
+	case lambda.PackageTypeImage:
+		if conf.ImageUri == "" {
+			return fmt.Errorf("packageType %s requires an imageUri", conf.PackageType)
+		}
+	case lambda.PackageTypeZip:
+		if conf.ImageUri != "" {
+			return fmt.Errorf("packageType %s must not be combined with imageUri", conf.PackageType)
+		}
+	default:
+		return fmt.Errorf("packageType %s must be %s or %s", conf.PackageType, lambda.PackageTypeZip, lambda.PackageTypeImage)
+	}
+	if conf.ZipEntryName != "" && !isCleanRelativePath(conf.ZipEntryName) {
+		return fmt.Errorf("zipEntryName %s must be a clean relative path", conf.ZipEntryName)
+	}
+	if conf.IncludeDir != "" && !isCleanRelativePath(conf.IncludeDir) {
+		return fmt.Errorf("includeDir %s must be a clean relative path", conf.IncludeDir)
+	}
+	if conf.ImageUri != "" && conf.IncludeDir != "" {
+		return errors.New("includeDir can't be used with imageUri")
+	}
+	for _, account := range conf.AllowedAccounts {
+		if !accountIdPattern.MatchString(account) {
+			return fmt.Errorf("allowedAccounts entry %q must be a 12 digit account ID", account)
+		}
+	}
+	if err := conf.validateBinaries(); err != nil {
+		return err
+	}
+	if err := conf.validateExtensions(); err != nil {
+		return err
+	}
+	if conf.Architecture != "" && conf.Architecture != lambda.ArchitectureX8664 && conf.Architecture != lambda.ArchitectureArm64 {
+		return fmt.Errorf("architecture %s must be %s or %s", conf.Architecture, lambda.ArchitectureX8664, lambda.ArchitectureArm64)
+	}
+	for key := range conf.GoEnv {
+		if !goEnvVariables[key] {
+			return fmt.Errorf("goEnv key %s is not a supported Go environment variable", key)
+		}
+	}
+	if conf.LayersMode != "" && conf.LayersMode != LayersModeReplace && conf.LayersMode != LayersModeMerge {
+		return fmt.Errorf("layersMode %s must be %s or %s", conf.LayersMode, LayersModeReplace, LayersModeMerge)
+	}
+	if err := conf.validateHandlerRule(); err != nil {
+		return err
+	}
+	if err := conf.validateEdge(); err != nil {
+		return err
+	}
+	if err := conf.validateDependsOn(); err != nil {
+		return err
+	}
+	if err := conf.validateReplace(); err != nil {
+		return err
+	}
+	if _, ok := conf.GoEnv["GOTOOLCHAIN"]; ok && conf.GoToolchain != "" {
+		return errors.New("goToolchain and goEnv GOTOOLCHAIN must not be set both")
+	}
+	if conf.ImageUri != "" && len(conf.PostBuild) > 0 {
+		return errors.New("postBuild can't be used with imageUri")
+	}
+	if conf.ImageUri != "" && conf.GoGenerate {
+		return errors.New("goGenerate can't be used with imageUri")
+	}
+	if conf.ImageUri != "" && conf.SigningProfile != "" {
+		return errors.New("signingProfile can't be used with imageUri")
+	}
+	if conf.MemorySize != 0 && (conf.MemorySize < 128 || conf.MemorySize > 10240) {
+		return fmt.Errorf("memorySize %d must be between 128 and 10240", conf.MemorySize)
+	}
+	// A zero timeout isn't declared, the live timeout is left untouched
+	if conf.Timeout < 0 || conf.Timeout > 900 {
+		return fmt.Errorf("timeout %d must be between 1 and 900, or 0 to leave the live timeout untouched", conf.Timeout)
+	}
+	if conf.HealthCheck != nil {
+		if err := conf.HealthCheck.validate(); err != nil {
+			return err
+		}
+	}
+	if conf.LoggingConfig != nil {
+		if err := conf.LoggingConfig.validate(); err != nil {
+			return err
+		}
+	}
+	if err := conf.validateLogRetention(); err != nil {
+		return err
+	}
+	if conf.ReservedConcurrency != nil && *conf.ReservedConcurrency < 0 {
+		return errors.New("reservedConcurrency must not be negative")
+	}
+	if conf.ReservedConcurrency != nil && conf.RemoveReservedConcurrency {
+		return errors.New("reservedConcurrency can't be used with removeReservedConcurrency")
+	}
+	if conf.ProvisionedConcurrency < 0 {
+		return errors.New("provisionedConcurrency must not be negative")
+	}
+	if conf.ProvisionedConcurrency > 0 && conf.Alias == "" {
+		return errors.New("provisionedConcurrency requires an alias")
+	}
+	if conf.CanaryWeight < 0 || conf.CanaryWeight >= 1 {
+		return fmt.Errorf("canaryWeight %g must be at least 0 and below 1", conf.CanaryWeight)
+	}
+	if conf.CanaryWeight > 0 && conf.Alias == "" {
+		return errors.New("canaryWeight requires an alias")
+	}
+	if err := conf.validateSource(); err != nil {
+		return err
+	}
+	// The files of a Source are checked once they are fetched
+	if len(conf.getSourceFileNames()) > 0 && conf.Source == "" {
+		return conf.validateMainFile()
+	}
+	return nil
+}
+
+// overridableFields lists the fields that can be changed with Override.
+var overridableFields = []string{"memorySize", "timeout"}
+
+// Override sets the field with the given YAML name to value and validates the config again.
+// It is meant for one-off changes at deploy time without editing the config, only the overridableFields are supported.
+func (conf *FunctionConfig) Override(field string, value string) error {
+	switch field {
+	case "memorySize", "timeout":
+		number, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer, got %s", field, value)
+		}
+		if field == "memorySize" {
+			conf.MemorySize = number
+		} else {
+			conf.Timeout = number
+		}
+	default:
+		return fmt.Errorf("%s can't be overridden, supported fields are %s", field, strings.Join(overridableFields, ", "))
+	}
+	return conf.validate()
+}
+
+// getSourceFileNames returns the Go files the function is built from.
+func (conf *FunctionConfig) getSourceFileNames() []string {
+	if conf.FileName != "" {
+		return []string{conf.FileName}
+	}
+	return conf.FileNames
+}
+
+// validateMainFile checks that all source files declare package main and one of them a main function.
+// The files are built on their own, so the main function can't live in another file of the package.
+func (conf *FunctionConfig) validateMainFile() error {
+	fileNames := conf.getSourceFileNames()
+	hasMain := false
+	for _, fileName := range fileNames {
+		file, err := parser.ParseFile(token.NewFileSet(), conf.getFullFilePath(fileName), nil, 0)
+		if err != nil {
+			return fmt.Errorf("fileName %s can't be parsed: %w", fileName, err)
+		}
+		if file.Name.Name != "main" {
+			return fmt.Errorf("fileName %s declares package %s, expected package main", fileName, file.Name.Name)
+		}
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+				hasMain = true
+			}
+		}
+	}
+	if !hasMain {
+		return fmt.Errorf("fileName %s declares no main function", strings.Join(fileNames, ", "))
+	}
+	return nil
+}
+
+// SelectLabeled returns the configs carrying at least one of the given labels.
+// All configs are returned if no labels are given.
+func SelectLabeled(configs []*FunctionConfig, labels []string) []*FunctionConfig {
+	if len(labels) == 0 {
+		return configs
+	}
+
+	var selected []*FunctionConfig
+	for _, config := range configs {
+		for _, label := range labels {
+			if contains(config.Labels, label) {
+				selected = append(selected, config)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// isCleanRelativePath reports whether p is a relative slash separated path without any . or .. elements.
+func isCleanRelativePath(p string) bool {
+	return p != "." && !strings.HasPrefix(p, "/") && !strings.HasPrefix(p, "../") && p != ".." &&
+		!strings.Contains(p, "\\") && path.Clean(p) == p
+}
+
+// FindFunctionConfigs searches recursively starting a root directory.
+// Symlinked directories are skipped unless followSymlinks is set,
+// in which case every directory is visited at most once so symlink cycles terminate.
+// Files whose name matches the glob pattern, e.g. *.lambda.yaml, are found in addition to .function.yaml
+// and .function.jsonnet, the pattern is ignored if it is empty.
+// returns a slice of found function configs without duplicates.
+func FindFunctionConfigs(root string, followSymlinks bool, pattern string) ([]string, error) {
+	var files []string
+	found := map[string]bool{}
+	visited := map[string]bool{}
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if visited[path] {
+					return filepath.SkipDir
+				}
+				visited[path] = true
+				return nil
+			}
+			if followSymlinks && info.Mode()&os.ModeSymlink != 0 {
+				target, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					logrus.WithError(err).Warnf("skipping broken symlink at %s", path)
+					return nil
+				}
+				targetInfo, err := os.Stat(target)
+				if err != nil {
+					return err
+				}
+				if targetInfo.IsDir() {
+					return walk(target)
+				}
+			}
+			match, err := isConfigFileName(info.Name(), pattern)
+			if err != nil {
+				return fmt.Errorf("invalid config glob %s: %w", pattern, err)
+			}
+			if match && !found[path] {
+				found[path] = true
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+
+	if followSymlinks {
+		var err error
+		root, err = filepath.EvalSymlinks(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// ParseFunctionConfig parses a .function.yaml file at the given path.
+// .function.jsonnet and other .jsonnet files are evaluated with the jsonnet command first and their output parsed.
+func ParseFunctionConfig(path string) (*FunctionConfig, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if isJsonnetConfig(path) {
+		data, err := evaluateJsonnet(absPath)
+		if err != nil {
+			return nil, err
+		}
+		return ParseFunctionConfigFromReader(bytes.NewReader(data), filepath.Dir(absPath))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseFunctionConfigFromReader(file, filepath.Dir(absPath))
+}
+
+// ParseFunctionConfigFromReader parses a function config from the given reader.
+// dir is the directory containing the function source.
+// The config is checked against the embedded JSON Schema first, so typos and type mismatches are reported by path.
+// Decoding is strict as well, unknown and duplicate keys are errors instead of being dropped.
+// String values of the form file:<path> are replaced with the content of the file, see resolveFileRefs.
+// Invalid configs are reported as ConfigError.
+func ParseFunctionConfigFromReader(reader io.Reader, dir string) (*FunctionConfig, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateSchema(data); err != nil {
+		return nil, &ConfigError{Path: dir, Err: err}
+	}
+
+	var function FunctionConfig
+	if err := yaml.UnmarshalStrict(data, &function); err != nil {
+		return nil, &ConfigError{Path: dir, Err: err}
+	}
+
+	function.Path = dir
+	sum := sha256.Sum256(data)
+	function.sourceSha256 = hex.EncodeToString(sum[:])
+
+	if err := function.resolveFileRefs(); err != nil {
+		return nil, &ConfigError{Path: dir, Err: err}
+	}
+	if err := function.validate(); err != nil {
+		return nil, &ConfigError{Path: dir, Err: err}
+	}
+
+	return &function, nil
+}
@@ -0,0 +1,300 @@
+package deploy
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// parseTestConfig parses the config in a function directory with the given files.
+func parseTestConfig(t *testing.T, config string, files ...string) (*FunctionConfig, error) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files...)
+	return ParseFunctionConfigFromReader(strings.NewReader(config), dir)
+}
+
+// expectConfigError fails the test unless err is a ConfigError containing message.
+func expectConfigError(t *testing.T, err error, message string) {
+	t.Helper()
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || !strings.Contains(err.Error(), message) {
+		t.Errorf("expected a ConfigError containing %q, got %v", message, err)
+	}
+}
+
+func TestParseValidatesMainFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+	}{
+		{"library package", "package handler\n\nfunc main() {}\n", "declares package handler, expected package main"},
+		{"missing main", "package main\n\nfunc handle() {}\n", "declares no main function"},
+		{"main method", "package main\n\ntype server struct{}\n\nfunc (server) main() {}\n", "declares no main function"},
+		{"syntax error", "package main\n\nfunc main() {\n", "can't be parsed"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTestConfig(t, "name: hello\nfileName: main.go\n", "main.go", test.source)
+
+			expectConfigError(t, err, test.message)
+		})
+	}
+}
+
+func TestParseAcceptsMainFile(t *testing.T) {
+	if _, err := parseTestConfig(t, "name: hello\nfileName: main.go\n", "main.go", testMain); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+}
+
+func TestHandlerForRuntime(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     *FunctionConfig
+		expected string
+	}{
+		{"go1.x", &FunctionConfig{Name: "hello", Runtime: "go1.x"}, "hello"},
+		{"go1.x qualified", &FunctionConfig{Name: "hello:PROD", Runtime: "go1.x"}, "hello"},
+		{"go1.x zip entry", &FunctionConfig{Name: "hello", Runtime: "go1.x", ZipEntryName: "bin/hello"}, "bin/hello"},
+		{"custom runtime", &FunctionConfig{Name: "hello", Runtime: "provided.al2023"}, "bootstrap"},
+		{"live custom runtime", &FunctionConfig{Name: "hello", liveConfig: &lambda.FunctionConfiguration{Runtime: aws.String("provided.al2")}}, "bootstrap"},
+		{"precompiled bootstrap", &FunctionConfig{Name: "hello", Bootstrap: "target/bootstrap"}, "bootstrap"},
+		{"explicit handler", &FunctionConfig{Name: "hello", Runtime: "go1.x", Handler: "main"}, "main"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if handler := handlerForRuntime(test.conf); handler != test.expected {
+				t.Errorf("expected handler %s, got %s", test.expected, handler)
+			}
+		})
+	}
+}
+
+func TestFindFunctionConfigsHandlesSymlinks(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	external, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, "hello/.function.yaml", "name: hello\n")
+	writeFiles(t, external, "shared/.function.yaml", "name: shared\n")
+	if err := os.Symlink(root, filepath.Join(root, "hello", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(external, filepath.Join(root, "external")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := FindFunctionConfigs(root, false, "")
+	if err != nil {
+		t.Fatalf("error while searching configs: %v", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(root, "hello", ".function.yaml") {
+		t.Errorf("expected only the config of hello without following symlinks, got %v", files)
+	}
+
+	files, err = FindFunctionConfigs(root, true, "")
+	if err != nil {
+		t.Fatalf("error while searching configs: %v", err)
+	}
+	sort.Strings(files)
+	expected := []string{filepath.Join(external, "shared", ".function.yaml"), filepath.Join(root, "hello", ".function.yaml")}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v following symlinks, got %v", expected, files)
+	}
+}
+
+func TestSelectLabeled(t *testing.T) {
+	configs := []*FunctionConfig{
+		{Name: "orders", Labels: []string{"api", "payments"}},
+		{Name: "invoices", Labels: []string{"payments"}},
+		{Name: "cron"},
+	}
+	tests := []struct {
+		name     string
+		labels   []string
+		expected []string
+	}{
+		{"no labels", nil, []string{"orders", "invoices", "cron"}},
+		{"single label", []string{"api"}, []string{"orders"}},
+		{"any of the labels", []string{"api", "payments"}, []string{"orders", "invoices"}},
+		{"unknown label", []string{"batch"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var names []string
+			for _, config := range SelectLabeled(configs, test.labels) {
+				names = append(names, config.Name)
+			}
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestParseValidatesFileNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		files   []string
+		message string
+	}{
+		{"fileName and fileNames", "name: hello\nfileName: main.go\nfileNames: [main.go]\n", []string{"main.go", testMain}, "fileName and fileNames must not be set both"},
+		{"entry in subdirectory", "name: hello\nfileNames: [main.go, lib/handler.go]\n", []string{"main.go", testMain, "lib/handler.go", "package main\n"}, `fileNames entry "lib/handler.go" must be a file in the function directory`},
+		{"library package after main", "name: hello\nfileNames: [main.go, handler.go]\n", []string{"main.go", testMain, "handler.go", "package handler\n"}, "fileName handler.go declares package handler, expected package main"},
+		{"no main in any file", "name: hello\nfileNames: [handler.go, util.go]\n", []string{"handler.go", "package main\n", "util.go", "package main\n"}, "fileName handler.go, util.go declares no main function"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTestConfig(t, test.config, test.files...)
+
+			expectConfigError(t, err, test.message)
+		})
+	}
+}
+
+func TestParseValidatesPackageType(t *testing.T) {
+	image := "imageUri: 123456789012.dkr.ecr.eu-central-1.amazonaws.com/hello:v2\n"
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{"image without imageUri", "name: hello\nfileName: main.go\npackageType: Image\n", "packageType Image requires an imageUri"},
+		{"zip with imageUri", "name: hello\n" + image + "packageType: Zip\n", "packageType Zip must not be combined with imageUri"},
+		{"unknown package type", "name: hello\nfileName: main.go\npackageType: Jar\n", "packageType: must be one of Zip, Image"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTestConfig(t, test.config, "main.go", testMain)
+
+			expectConfigError(t, err, test.message)
+		})
+	}
+}
+
+func TestValidateRejectsUnknownPackageType(t *testing.T) {
+	conf := &FunctionConfig{Name: "hello", ImageUri: "123456789012.dkr.ecr.eu-central-1.amazonaws.com/hello:v2", PackageType: "Jar"}
+
+	if err := conf.validate(); err == nil || err.Error() != "packageType Jar must be Zip or Image" {
+		t.Errorf("expected the unknown package type to be rejected, got %v", err)
+	}
+}
+
+func TestExplicitHandlerOverridesCustomRuntimeDefault(t *testing.T) {
+	client := newFakeLambda()
+	client.addFunction("hello", lambda.RuntimeProvidedAl2023)
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nhandler: custom.handler\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if handler := aws.StringValue(client.function("hello").Handler); handler != "custom.handler" {
+		t.Errorf("expected handler custom.handler, got %s", handler)
+	}
+	if entries := client.zipEntries(t, "hello"); len(entries) != 1 || entries[0] != "bootstrap" {
+		t.Errorf("expected the single entry bootstrap, got %v", entries)
+	}
+}
+
+func TestOverrideWinsOverConfig(t *testing.T) {
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nmemorySize: 128\ntimeout: 10\n")
+
+	if err := conf.Override("memorySize", "512"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Override("timeout", "30"); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.MemorySize != 512 || conf.Timeout != 30 {
+		t.Errorf("expected memory size 512 and timeout 30, got %d and %d", conf.MemorySize, conf.Timeout)
+	}
+}
+
+func TestOverrideRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   string
+		value   string
+		message string
+	}{
+		{"not a number", "timeout", "30s", "timeout must be an integer, got 30s"},
+		{"unknown field", "handler", "main", "handler can't be overridden, supported fields are memorySize, timeout"},
+		{"invalid value", "memorySize", "64", "memorySize 64 must be between 128 and 10240"},
+		{"negative timeout", "timeout", "-5", "timeout -5 must be between 1 and 900, or 0 to leave the live timeout untouched"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+
+			if err := conf.Override(test.field, test.value); err == nil || err.Error() != test.message {
+				t.Errorf("expected %q, got %v", test.message, err)
+			}
+		})
+	}
+}
+
+func TestValidateHandlerRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    FunctionConfig
+		message string
+	}{
+		{"unknown", FunctionConfig{HandlerRule: "binary"}, "handlerRule binary must be one of name, bootstrap, explicit, none"},
+		{"explicit without handler", FunctionConfig{HandlerRule: HandlerRuleExplicit}, "handlerRule explicit requires handler to be set"},
+		{"handler with other rule", FunctionConfig{HandlerRule: HandlerRuleBootstrap, Handler: "main"}, "handler can't be used with handlerRule bootstrap"},
+		{"none with managed handler", FunctionConfig{HandlerRule: HandlerRuleNone, ManageHandler: aws.Bool(true)}, "handlerRule none can't be used with manageHandler true"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.conf.validateHandlerRule(); err == nil || err.Error() != test.message {
+				t.Errorf("expected %q, got %v", test.message, err)
+			}
+		})
+	}
+}
+
+func TestFindFunctionConfigsMatchesGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"orders/orders.lambda.yaml", "name: orders\n",
+		"hello/.function.yaml", "name: hello\n",
+		"docs/openapi.yaml", "openapi: 3.0.0\n",
+		"nested/deeper/billing.lambda.yaml", "name: billing\n")
+
+	files, err := FindFunctionConfigs(dir, false, "*.lambda.yaml")
+
+	if err != nil {
+		t.Fatalf("error while searching configs: %v", err)
+	}
+	expected := []string{
+		filepath.Join(dir, "hello", ".function.yaml"),
+		filepath.Join(dir, "nested", "deeper", "billing.lambda.yaml"),
+		filepath.Join(dir, "orders", "orders.lambda.yaml"),
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected %v, got %v", expected, files)
+	}
+}
+
+func TestFindFunctionConfigsRejectsInvalidGlob(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "hello/main.go", testMain)
+
+	if _, err := FindFunctionConfigs(dir, false, "[a-"); err == nil || !strings.HasPrefix(err.Error(), "invalid config glob [a-") {
+		t.Errorf("expected the invalid glob to be reported, got %v", err)
+	}
+}
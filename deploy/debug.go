@@ -0,0 +1,78 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/sirupsen/logrus"
+	"os"
+	"path"
+)
+
+// stripArgs are passed to go build to strip debug information from the deployed binary.
+var stripArgs = []string{"-ldflags=-s -w"}
+
+// getDebugBuildOutputPath returns the path where the unstripped debug build should be written to.
+func (conf *FunctionConfig) getDebugBuildOutputPath() string {
+	return fmt.Sprintf("%s/%s.debug", conf.buildDir, conf.getBuildName())
+}
+
+// deleteDebugBuildFile deletes the unstripped debug build for this FunctionConfig.
+func (conf *FunctionConfig) deleteDebugBuildFile() error {
+	if err := os.Remove(conf.getDebugBuildOutputPath()); err != nil {
+		return fmt.Errorf("error while deleting debug build at %s: %w", conf.getDebugBuildOutputPath(), err)
+	}
+	return nil
+}
+
+// getDebugArchiveKey returns the S3 key of the debug build for the deployed code hash.
+// codeSha256 is the base64 encoded hash reported by Lambda, the key uses its hex form.
+func (conf *FunctionConfig) getDebugArchiveKey(codeSha256 string) (string, error) {
+	hash, err := base64.StdEncoding.DecodeString(codeSha256)
+	if err != nil {
+		return "", fmt.Errorf("invalid code hash %s: %w", codeSha256, err)
+	}
+	return fmt.Sprintf("%s/%s/%s", conf.getFunctionName(), hex.EncodeToString(hash), conf.getFunctionName()), nil
+}
+
+// uploadDebugArchive uploads the unstripped debug build to the given bucket.
+func (conf *FunctionConfig) uploadDebugArchive(ctx context.Context, client s3iface.S3API, bucket string, codeSha256 string) error {
+	key, err := conf.getDebugArchiveKey(codeSha256)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(conf.getDebugBuildOutputPath())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+		Body:   file,
+	})
+	if err != nil {
+		return err
+	}
+	logrus.Infof("archived debug build of lambda function %s at s3://%s/%s", conf.Name, bucket, key)
+
+	if conf.buildInfo != nil {
+		infoKey := path.Join(path.Dir(key), buildInfoEntryName)
+		_, err = client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+			Bucket: &bucket,
+			Key:    &infoKey,
+			Body:   bytes.NewReader(conf.buildInfo),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
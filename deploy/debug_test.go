@@ -0,0 +1,47 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDeployArchivesUnstrippedDebugBuild(t *testing.T) {
+	server, s3Client := newS3Server(t)
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.S3 = s3Client
+	opts.DebugArchiveBucket = "debug"
+
+	result := deployOne(t, conf, opts)
+
+	hash, err := base64.StdEncoding.DecodeString(result.CodeSha256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	debugBuild, _ := server.object("debug/hello/" + hex.EncodeToString(hash) + "/hello")
+	if len(debugBuild) == 0 {
+		t.Fatal("expected the debug build to be archived at the key of the deployed code hash")
+	}
+	reader, err := zip.NewReader(bytes.NewReader(client.zips["hello"]), int64(len(client.zips["hello"])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployed := reader.File[0].UncompressedSize64; deployed >= uint64(len(debugBuild)) {
+		t.Errorf("expected the deployed binary (%d bytes) to be stripped and smaller than the debug build (%d bytes)", deployed, len(debugBuild))
+	}
+}
+
+func TestUploadDebugArchiveRejectsInvalidHash(t *testing.T) {
+	_, s3Client := newS3Server(t)
+	conf := newBuildConfig(t, "hello")
+
+	err := conf.uploadDebugArchive(context.Background(), s3Client, "debug", "not base64!")
+	if err == nil {
+		t.Error("expected an error for an invalid code hash")
+	}
+}
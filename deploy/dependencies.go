@@ -0,0 +1,106 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// validateDependsOn checks that every dependsOn entry names another function.
+func (conf *FunctionConfig) validateDependsOn() error {
+	for _, dependency := range conf.DependsOn {
+		if dependency == "" {
+			return errors.New("dependsOn entries must not be empty")
+		}
+		if dependency == conf.Name {
+			return errors.New("dependsOn must not contain the function itself")
+		}
+	}
+	return nil
+}
+
+// getDependencies returns the names of the functions this one depends on,
+// the dependsOn entries and the functions whose ARN is resolved into the environment.
+func (conf *FunctionConfig) getDependencies() []string {
+	return append(append([]string{}, conf.DependsOn...), conf.getFunctionArnReferences()...)
+}
+
+// SortByDependencies returns the configs ordered so every function comes after the functions it depends on,
+// either through dependsOn or through a fn-arn: environment variable.
+// Functions keep their config order unless a dependency requires otherwise.
+// A dependency on a name deployed to several regions waits for all of them.
+// Dependencies on functions that aren't part of configs are ignored, they are expected to be deployed already.
+// Deploy runs this before deploying anything, a dependency cycle is returned as ConfigError.
+func SortByDependencies(configs []*FunctionConfig) ([]*FunctionConfig, error) {
+	byName := make(map[string][]*FunctionConfig, len(configs))
+	for _, config := range configs {
+		byName[config.Name] = append(byName[config.Name], config)
+	}
+
+	sorted := make([]*FunctionConfig, 0, len(configs))
+	placed := make(map[*FunctionConfig]bool, len(configs))
+	for len(sorted) < len(configs) {
+		progress := false
+		for _, config := range configs {
+			if placed[config] || !dependenciesPlaced(config, byName, placed) {
+				continue
+			}
+			sorted = append(sorted, config)
+			placed[config] = true
+			progress = true
+		}
+		if !progress {
+			return nil, dependencyCycle(configs, byName, placed)
+		}
+	}
+	return sorted, nil
+}
+
+// getUnplaced returns the first config of the given name that isn't placed yet, nil if all of them are placed.
+func getUnplaced(name string, byName map[string][]*FunctionConfig, placed map[*FunctionConfig]bool) *FunctionConfig {
+	for _, config := range byName[name] {
+		if !placed[config] {
+			return config
+		}
+	}
+	return nil
+}
+
+// dependenciesPlaced reports whether all dependencies of the config that are part of byName are placed.
+func dependenciesPlaced(config *FunctionConfig, byName map[string][]*FunctionConfig, placed map[*FunctionConfig]bool) bool {
+	for _, dependency := range config.getDependencies() {
+		if getUnplaced(dependency, byName, placed) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyCycle returns the error for a cycle among the configs that couldn't be placed.
+// Every one of them has an unplaced dependency, so following those from the first one must lead into a cycle.
+func dependencyCycle(configs []*FunctionConfig, byName map[string][]*FunctionConfig, placed map[*FunctionConfig]bool) error {
+	var current *FunctionConfig
+	for _, config := range configs {
+		if !placed[config] {
+			current = config
+			break
+		}
+	}
+
+	var path []string
+	seen := map[string]int{}
+	for {
+		if start, ok := seen[current.Name]; ok {
+			cycle := append(path[start:], current.Name)
+			return &ConfigError{Path: current.Path, Err: fmt.Errorf("dependsOn cycle between lambda functions %s", strings.Join(cycle, " -> "))}
+		}
+		seen[current.Name] = len(path)
+		path = append(path, current.Name)
+		for _, dependency := range current.getDependencies() {
+			if next := getUnplaced(dependency, byName, placed); next != nil {
+				current = next
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// dependentConfig returns a config with the given name and dependsOn entries, it is not parsed or built.
+func dependentConfig(name string, dependsOn ...string) *FunctionConfig {
+	return &FunctionConfig{Name: name, Path: "/functions/" + name, DependsOn: dependsOn}
+}
+
+// configNames returns the names of the configs in order.
+func configNames(configs []*FunctionConfig) string {
+	names := make([]string, len(configs))
+	for i, config := range configs {
+		names[i] = config.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+func TestSortByDependencies(t *testing.T) {
+	worker := dependentConfig("worker", "api")
+	worker.Environment = map[string]string{"QUEUE_ARN": functionArnPrefix + "queue"}
+	configs := []*FunctionConfig{
+		worker,
+		dependentConfig("api", "db", "external"),
+		dependentConfig("standalone"),
+		dependentConfig("queue"),
+		dependentConfig("db"),
+	}
+
+	sorted, err := SortByDependencies(configs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := configNames(sorted); names != "standalone, queue, db, api, worker" {
+		t.Errorf("expected every function after its dependencies, got %s", names)
+	}
+}
+
+func TestSortByDependenciesWaitsForAllRegions(t *testing.T) {
+	usHello := dependentConfig("hello")
+	usHello.Region = "us-east-1"
+	configs := []*FunctionConfig{dependentConfig("api", "hello"), dependentConfig("hello"), usHello}
+
+	sorted, err := SortByDependencies(configs)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := configNames(sorted); names != "hello, hello, api" || sorted[1] != usHello {
+		t.Errorf("expected api after hello in both regions, got %s", names)
+	}
+}
+
+func TestDeployFunctionsOfTheSameNameInDifferentRegions(t *testing.T) {
+	client := newFakeLambda("hello")
+	configs := []*FunctionConfig{
+		newTestFunction(t, "name: hello\nfileName: main.go\n"),
+		newTestFunction(t, "name: hello\nfileName: main.go\nregion: us-east-1\n"),
+	}
+
+	results, err := Deploy(context.Background(), configs, testOptions(client))
+
+	if err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+	if len(results) != 2 || results[0].Action != ActionUpdated || results[1].Action != ActionUpdated || results[1].Region != "us-east-1" {
+		t.Errorf("expected hello to be updated in both regions, got %+v", results)
+	}
+}
+
+func TestSortByDependenciesReportsCycle(t *testing.T) {
+	configs := []*FunctionConfig{
+		dependentConfig("standalone"),
+		dependentConfig("a", "b"),
+		dependentConfig("b", "c"),
+		dependentConfig("c", "a"),
+	}
+
+	_, err := SortByDependencies(configs)
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || err.Error() != "dependsOn cycle between lambda functions a -> b -> c -> a" {
+		t.Errorf("expected the cycle as ConfigError, got %v", err)
+	}
+}
+
+func TestDeployStartsDependentsAfterTheirDependencies(t *testing.T) {
+	client := newFakeLambda("db", "api", "worker", "standalone")
+	configs := []*FunctionConfig{
+		newTestFunction(t, "name: worker\nfileName: main.go\ndependsOn: [api]\n"),
+		newTestFunction(t, "name: api\nfileName: main.go\ndependsOn: [db]\n"),
+		newTestFunction(t, "name: standalone\nfileName: main.go\n"),
+		newTestFunction(t, "name: db\nfileName: main.go\n"),
+	}
+	opts := testOptions(client)
+	opts.Concurrency = 4
+
+	if _, err := Deploy(context.Background(), configs, opts); err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+
+	first, last := map[string]int{}, map[string]int{}
+	for i, call := range client.calls {
+		name := call[strings.LastIndex(call, " ")+1:]
+		if _, ok := first[name]; !ok {
+			first[name] = i
+		}
+		last[name] = i
+	}
+	for _, dependency := range [][2]string{{"db", "api"}, {"api", "worker"}} {
+		if last[dependency[0]] > first[dependency[1]] {
+			t.Errorf("expected %s to start after %s finished, got calls %v", dependency[1], dependency[0], client.calls)
+		}
+	}
+}
+
+func TestDeployRejectsCycleBeforeDeploying(t *testing.T) {
+	client := newFakeLambda("a", "b")
+	configs := []*FunctionConfig{
+		newTestFunction(t, "name: a\nfileName: main.go\ndependsOn: [b]\n"),
+		newTestFunction(t, "name: b\nfileName: main.go\ndependsOn: [a]\n"),
+	}
+
+	_, err := Deploy(context.Background(), configs, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "dependsOn cycle") {
+		t.Errorf("expected the cycle to be reported, got %v", err)
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected no calls, got %v", client.calls)
+	}
+}
+
+func TestValidateDependsOn(t *testing.T) {
+	for dependsOn, message := range map[string]string{
+		"":      "dependsOn entries must not be empty",
+		"hello": "dependsOn must not contain the function itself",
+	} {
+		if err := dependentConfig("hello", dependsOn).validateDependsOn(); err == nil || err.Error() != message {
+			t.Errorf("expected %q for %q, got %v", message, dependsOn, err)
+		}
+	}
+}
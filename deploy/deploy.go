@@ -0,0 +1,718 @@
+// Package deploy builds, zips and deploys Go Lambda functions described by .function.yaml files.
+package deploy
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/signer/signeriface"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultSizeWarningThreshold = 240 * 1024 * 1024
+
+// Options controls how Deploy talks to AWS.
+type Options struct {
+	// Session is used to create the AWS clients.
+	// Defaults to a session created from the environment.
+	Session *session.Session
+	// Lambda is the client used for all Lambda API calls.
+	// Defaults to a client created from Session.
+	Lambda lambdaiface.LambdaAPI
+
+	// SizeWarningThreshold is the uncompressed package size in bytes above which a warning is logged.
+	// Defaults to 240MB, just below Lambda's 250MB limit.
+	SizeWarningThreshold int64
+
+	// MaxRetries is the number of retries for throttled or failed AWS API calls.
+	// Defaults to the SDK default. Only applies to the clients created by Deploy.
+	MaxRetries *int
+
+	// HandlerCheck controls what happens if the live handler differs from the config.
+	// One of HandlerCheckApply, HandlerCheckWarn or HandlerCheckOff, defaults to HandlerCheckApply.
+	// Functions with manageHandler set to false are never checked.
+	HandlerCheck string
+
+	// Strict turns every warning into an error that fails the function.
+	Strict bool
+
+	// Force deploys functions even if their live runtime or architecture differs from the config.
+	Force bool
+
+	// DebugArchiveBucket is the S3 bucket the unstripped binaries are archived in.
+	// When set, the deployed binaries are stripped of debug information.
+	DebugArchiveBucket string
+	// S3 is the client used to upload artifacts.
+	// Defaults to a client created from Session.
+	S3 s3iface.S3API
+
+	// BackupDir is the directory the live code of every function is downloaded to before it is replaced,
+	// as <function>-<sha256>.zip. Image based functions aren't backed up.
+	BackupDir string
+	// HTTPClient is the client used to download the live code for backups and the source archives,
+	// it is also used by the AWS clients created by Deploy, see NewHTTPClient. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// SigningKey signs the checksum of every uploaded zip after its hash was verified against the one reported by Lambda,
+	// see LoadSigningKey. The checksums and signatures are written to SignatureDir.
+	SigningKey crypto.Signer
+	// SignatureDir is the directory of the checksums and signatures of SigningKey, defaults to the working directory.
+	SignatureDir string
+
+	// ArtifactBucket is the S3 bucket zips are staged in. When set, functions are updated
+	// from the staged object instead of sending the zip inline, which lifts the 50MB limit of direct uploads.
+	// The bucket must be in the region of the functions.
+	ArtifactBucket string
+	// S3PartSize is the part size in bytes of multipart uploads to ArtifactBucket.
+	// Defaults to the SDK minimum of 5MB.
+	S3PartSize int64
+	// S3Concurrency is the number of parts uploaded to ArtifactBucket in parallel per zip.
+	// Defaults to the SDK default of 5.
+	S3Concurrency int
+	// S3ServerSideEncryption is the server-side encryption of the staged zips, AES256 or aws:kms.
+	// Defaults to the default encryption of the bucket.
+	S3ServerSideEncryption string
+	// S3SseKmsKeyId is the KMS key the staged zips are encrypted with, it implies aws:kms encryption.
+	S3SseKmsKeyId string
+	// S3ACL is the canned ACL of the staged zips, e.g. bucket-owner-full-control.
+	S3ACL string
+	// Signer is the client used to sign the staged zips of functions with a signingProfile.
+	// Defaults to a client created from Session.
+	Signer signeriface.SignerAPI
+
+	// Race builds the functions with the race detector, which requires cgo and a supported target.
+	// Meant for staging deploys, race enabled binaries are considerably slower.
+	Race bool
+
+	// EmitBuildInfo adds a build-info.json with build time, git commit, Go version and module hash to the zip archive.
+	// It is also archived next to the debug build if DebugArchiveBucket is set.
+	EmitBuildInfo bool
+
+	// DryRun only reports what would change, nothing is built or mutated.
+	// The functions are reported with ActionDryRun.
+	DryRun bool
+	// Explain writes a plain description of every step the deploy of a function would take to this writer,
+	// including the built sources, the zip entries and the AWS calls. Like DryRun nothing is built or mutated.
+	Explain io.Writer
+	// Diff receives the differing configuration fields of every function during a dry run.
+	// Live values encrypted with the KMS key of the function are decrypted before they are compared.
+	Diff io.Writer
+	// ShowSecrets prints the values of environment variables in the Diff instead of only their keys.
+	ShowSecrets bool
+	// KMS is the client used to decrypt live environment variables during a dry run.
+	// Defaults to a client created from Session.
+	KMS kmsiface.KMSAPI
+
+	// OnlyChangedConfig skips the code update of functions whose zip matches the live code,
+	// their configuration is still reconciled. Zips are reproducible, but the build info of EmitBuildInfo
+	// contains the build time, so functions emitting it always update their code.
+	// The same applies to functions with a signingProfile, the signed zip never matches the local one.
+	OnlyChangedConfig bool
+	// KeepAliasIfUnchanged leaves the alias as it is if neither the code nor the configuration changed,
+	// instead of publishing a new version. Requires OnlyChangedConfig, which detects the unchanged code.
+	KeepAliasIfUnchanged bool
+
+	// OutputDir turns Deploy into a packager: the zip of every function is copied to <OutputDir>/<function>.zip
+	// and no AWS calls are made. Image based functions are skipped.
+	// The functions are reported with ActionPackaged.
+	OutputDir string
+
+	// ManagedTagKey is the tag set on every deployed function to mark it as managed by lambda-ci.
+	// No tag is set if it is empty. Functions can override the key and value in their config.
+	ManagedTagKey string
+	// ManagedTagValue is the value of the managed tag, defaults to lambda-ci.
+	ManagedTagValue string
+
+	// StampCI tags every deployed function with the CI provider, build number and build URL of the run.
+	// The provider is detected from its environment variables, nothing is tagged outside of CI.
+	StampCI bool
+
+	// Resume holds the results of a previous run, e.g. read from its manifest. Functions it reports as updated
+	// are skipped after the build if their live code and the new zip still have the recorded hash,
+	// only the failed, changed and remaining functions are deployed.
+	// The skipped functions are reported with ActionSkipped and keep the version and hash of the previous run.
+	Resume []Result
+
+	// KeepArtifacts leaves the binaries and zip files in the build directory instead of deleting them.
+	KeepArtifacts bool
+
+	// Concurrency is the number of functions deployed in parallel.
+	// Defaults to 1.
+	Concurrency int
+
+	// SSM is the client used to resolve ssm: environment variables.
+	// Defaults to a client created from Session.
+	SSM ssmiface.SSMAPI
+	// SecretsManager is the client used to resolve secretsmanager: environment variables.
+	// Defaults to a client created from Session.
+	SecretsManager secretsmanageriface.SecretsManagerAPI
+
+	// AllowedAccounts lists the AWS account IDs functions may be deployed to. Before anything is changed,
+	// the account of the credentials is resolved through STS and functions of other accounts fail.
+	// Functions can override the list in their config. Any account is allowed if it is empty.
+	AllowedAccounts []string
+	// BranchGuard is the git branch functions may only be deployed from, the branch is resolved
+	// in the directory of each config. Functions can override it in their config. Any branch is allowed if it is empty.
+	BranchGuard string
+
+	// STS is the client used to resolve the account of the credentials.
+	// Defaults to a client created from Session.
+	STS stsiface.STSAPI
+
+	// CloudWatchLogs is the client used to set the retention of the log groups.
+	// Defaults to a client created from Session.
+	CloudWatchLogs cloudwatchlogsiface.CloudWatchLogsAPI
+
+	// BuildProfileDir is the directory a build profile is written to for diagnosing slow builds.
+	// Every go build runs with -x, its command trace is written to <function>.trace,
+	// the summed up build durations of all functions are written to build-report.txt.
+	BuildProfileDir string
+
+	// Metrics receives the duration of the build, zip and upload steps.
+	// Defaults to discarding them.
+	Metrics MetricsRecorder
+
+	// planning is set while CreatePlan packages the functions, the live runtime is then still queried.
+	planning bool
+}
+
+// Modes for Options.HandlerCheck.
+const (
+	// HandlerCheckApply updates the handler if it differs from the expected handler.
+	HandlerCheckApply = "apply"
+	// HandlerCheckWarn only logs a warning if the handler differs from the expected handler.
+	HandlerCheckWarn = "warn"
+	// HandlerCheckOff skips the handler check.
+	HandlerCheckOff = "off"
+)
+
+// Actions reported in a Result.
+const (
+	ActionUpdated  = "updated"
+	ActionSkipped  = "skipped"
+	ActionFailed   = "failed"
+	ActionDryRun   = "dry-run"
+	ActionPackaged = "packaged"
+)
+
+// Result describes the outcome of deploying a single function.
+type Result struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	CodeSha256 string `json:"codeSha256"`
+	Region     string `json:"region"`
+	Action     string `json:"action"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// deployer holds the clients and options shared by all functions of a Deploy call.
+type deployer struct {
+	opts   Options
+	lambda lambdaiface.LambdaAPI
+	s3     s3iface.S3API
+	sts    stsiface.STSAPI
+	logs   cloudwatchlogsiface.CloudWatchLogsAPI
+	kms    kmsiface.KMSAPI
+	signer signeriface.SignerAPI
+	region string
+	// ciTags are the tags of Options.StampCI, nil if it is disabled or no CI provider was detected.
+	ciTags map[string]string
+
+	resolver *valueResolver
+	// diffMutex serializes the writes to Options.Diff, it is shared with the per function deployers.
+	diffMutex *sync.Mutex
+	// modules warms up the module cache before parallel builds, it is shared with the per function deployers.
+	modules *moduleWarmer
+	// generator runs go generate for functions with GoGenerate, it is shared with the per function deployers.
+	generator *moduleGenerator
+
+	// sess and clientConfig are used to create per function clients, they are nil if all clients were injected.
+	sess         *session.Session
+	clientConfig *aws.Config
+}
+
+// Deploy builds, zips and updates the Lambda function for every given config.
+// Up to Options.Concurrency functions are deployed in parallel. Functions with dependsOn are started
+// once the functions they depend on finished, see SortByDependencies for the resulting order.
+// After the first failure no further functions are started, functions already in progress are finished.
+// If ctx is done, the functions that weren't started yet are reported as skipped and ctx.Err() is returned.
+// The returned results contain an entry for every processed function in deploy order, including the failed one.
+func Deploy(ctx context.Context, configs []*FunctionConfig, opts Options) ([]Result, error) {
+	if opts.SizeWarningThreshold == 0 {
+		opts.SizeWarningThreshold = defaultSizeWarningThreshold
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = noopRecorder{}
+	}
+	var profile *buildProfile
+	if opts.BuildProfileDir != "" {
+		if err := os.MkdirAll(opts.BuildProfileDir, 0755); err != nil {
+			return nil, err
+		}
+		profile = newBuildProfile(opts.Metrics)
+		opts.Metrics = profile
+	}
+	switch opts.HandlerCheck {
+	case "":
+		opts.HandlerCheck = HandlerCheckApply
+	case HandlerCheckApply, HandlerCheckWarn, HandlerCheckOff:
+	default:
+		return nil, fmt.Errorf("handler check mode %s must be %s, %s or %s", opts.HandlerCheck, HandlerCheckApply, HandlerCheckWarn, HandlerCheckOff)
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.KeepAliasIfUnchanged && !opts.OnlyChangedConfig {
+		return nil, errors.New("keeping unchanged aliases requires OnlyChangedConfig")
+	}
+	if err := opts.validateStaging(); err != nil {
+		return nil, err
+	}
+	if opts.SigningKey != nil && opts.SignatureDir == "" {
+		opts.SignatureDir = "."
+	}
+	// Dry runs and packaging never upload, so nothing is signed
+	if opts.SigningKey != nil && !opts.DryRun && opts.Explain == nil && opts.OutputDir == "" {
+		if err := prepareSignatureDir(opts.SignatureDir); err != nil {
+			return nil, fmt.Errorf("error while preparing signature directory %s: %w", opts.SignatureDir, err)
+		}
+	}
+	for _, account := range opts.AllowedAccounts {
+		if !accountIdPattern.MatchString(account) {
+			return nil, fmt.Errorf("allowed account %q must be a 12 digit account ID", account)
+		}
+	}
+
+	d, err := newDeployer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkDuplicateNames(configs, d.region); err != nil {
+		return nil, err
+	}
+	configs, err = SortByDependencies(configs)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Result, len(configs))
+	var (
+		mutex    sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	workers := make(chan struct{}, opts.Concurrency)
+	// A name finishes once all of its functions finished, functions of the same name may be deployed to several regions
+	done := make(map[string]*sync.WaitGroup, len(configs))
+	for _, config := range configs {
+		if done[config.Name] == nil {
+			done[config.Name] = &sync.WaitGroup{}
+		}
+		done[config.Name].Add(1)
+	}
+
+	for i, config := range configs {
+		// Dependencies were started before, a function only starts once all of them finished
+		for _, dependency := range config.getDependencies() {
+			if finished, ok := done[dependency]; ok {
+				finished.Wait()
+			}
+		}
+		workers <- struct{}{}
+
+		mutex.Lock()
+		failed := firstErr != nil
+		if ctx.Err() != nil {
+			for j := i; j < len(configs); j++ {
+				results[j] = &Result{Name: configs[j].Name, Region: d.region, Action: ActionSkipped}
+			}
+			if firstErr == nil {
+				firstErr = ctx.Err()
+			}
+			failed = true
+		}
+		mutex.Unlock()
+		if failed {
+			<-workers
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, config *FunctionConfig) {
+			defer wg.Done()
+			defer done[config.Name].Done()
+			defer func() { <-workers }()
+
+			result := d.deployFunctionTimed(ctx, config)
+			mutex.Lock()
+			defer mutex.Unlock()
+			results[i] = &result.Result
+			if result.err != nil && firstErr == nil {
+				firstErr = result.err
+			}
+		}(i, config)
+	}
+	wg.Wait()
+
+	if profile != nil {
+		if err := profile.writeReport(opts.BuildProfileDir); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("error while writing build report: %w", err)
+		} else if err == nil {
+			logrus.Infof("wrote build profile to %s", opts.BuildProfileDir)
+		}
+	}
+
+	var processed []Result
+	for _, result := range results {
+		if result != nil {
+			processed = append(processed, *result)
+		}
+	}
+	return processed, firstErr
+}
+
+// timedResult is the outcome of deployFunctionTimed.
+type timedResult struct {
+	Result
+	err error
+}
+
+// deployFunctionTimed deploys a single function in its own build directory and records the outcome.
+// The wall-clock time from the build start to the end of the deploy is logged and recorded in the result.
+func (d *deployer) deployFunctionTimed(ctx context.Context, config *FunctionConfig) timedResult {
+	start := time.Now()
+	result := timedResult{Result: Result{Name: config.Name, Region: d.region}}
+
+	fd, err := d.forFunction(config)
+	if err != nil {
+		result.err = deployError(config, fmt.Errorf("error while creating clients for config at %s: %w", config.Path, err))
+	} else if err := config.createBuildDir(); err != nil {
+		result.err = buildError(config, err)
+	} else {
+		result.Region = fd.region
+		result.err = fd.deployFunction(ctx, config, &result.Result)
+		if d.opts.KeepArtifacts {
+			logrus.Infof("kept artifacts of lambda function %s in %s", config.Name, config.buildDir)
+		} else if err := config.deleteBuildDir(); err != nil {
+			logrus.WithError(err).Warn("error while cleaning up")
+		}
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	if result.err != nil {
+		result.Action = ActionFailed
+	} else if result.Action == "" {
+		result.Action = ActionUpdated
+	}
+	logrus.Infof("finished lambda function %s in %s (%s)", config.Name, time.Duration(result.DurationMs)*time.Millisecond, result.Action)
+	return result
+}
+
+// deployFunction runs the full pipeline for a single function.
+// The deployed version and code hash are recorded in result.
+// Errors are returned as BuildError or DeployError depending on the failed step.
+// Planned functions skip the guard and the hooks, they already ran while planning.
+func (d *deployer) deployFunction(ctx context.Context, conf *FunctionConfig, result *Result) error {
+	if len(conf.DeployIf) > 0 && conf.plan == nil {
+		proceed, err := conf.runGuard(ctx)
+		if err != nil {
+			return deployError(conf, fmt.Errorf("error while running deploy guard for config at %s: %w", conf.Path, err))
+		}
+		if !proceed {
+			logrus.Infof("skipped lambda function %s, deploy guard failed", conf.Name)
+			result.Action = ActionSkipped
+			return nil
+		}
+	}
+
+	if d.opts.OutputDir != "" && conf.ImageUri != "" {
+		logrus.Infof("skipped lambda function %s, image based functions can't be packaged", conf.Name)
+		result.Action = ActionSkipped
+		return nil
+	}
+
+	// Packaging only needs the build, the live function is never queried
+	if d.opts.OutputDir == "" {
+		if err := d.checkBranch(conf); err != nil {
+			return deployError(conf, fmt.Errorf("error while checking branch for config at %s: %w", conf.Path, err))
+		}
+
+		if err := d.checkAccount(ctx, conf); err != nil {
+			return deployError(conf, fmt.Errorf("error while checking account for config at %s: %w", conf.Path, err))
+		}
+
+		if err := conf.resolveEnvironment(ctx, d.resolver); err != nil {
+			return deployError(conf, fmt.Errorf("error while resolving environment for config at %s: %w", conf.Path, err))
+		}
+
+		if err := d.checkCompatibility(ctx, conf); err != nil {
+			return deployError(conf, fmt.Errorf("error while checking compatibility for config at %s: %w", conf.Path, err))
+		}
+	}
+	if err := d.checkDeprecatedRuntime(ctx, conf); err != nil {
+		return deployError(conf, fmt.Errorf("error while checking runtime for config at %s: %w", conf.Path, err))
+	}
+
+	if conf.plan != nil {
+		if err := d.checkPlan(ctx, conf); err != nil {
+			return deployError(conf, fmt.Errorf("error while checking plan for config at %s: %w", conf.Path, err))
+		}
+		if !conf.plan.HasChanges() {
+			logrus.Infof("skipped lambda function %s, the plan has no changes", conf.Name)
+			result.Action = ActionSkipped
+			return nil
+		}
+	}
+
+	if d.opts.Explain != nil {
+		if err := d.explain(ctx, conf, result); err != nil {
+			return deployError(conf, fmt.Errorf("error while explaining deploy for config at %s: %w", conf.Path, err))
+		}
+		return nil
+	}
+	if d.opts.DryRun {
+		if err := d.planDryRun(ctx, conf, result); err != nil {
+			return deployError(conf, fmt.Errorf("error while planning dry run for config at %s: %w", conf.Path, err))
+		}
+		return nil
+	}
+
+	// Image based functions are built and pushed outside of lambda-ci
+	if conf.ImageUri == "" && conf.plan != nil {
+		if err := conf.copyPlannedZip(); err != nil {
+			return buildError(conf, fmt.Errorf("error while copying planned zip for config at %s: %w", conf.Path, err))
+		}
+		defer d.deleteArtifact(conf.deleteZipFile)
+
+		if err := conf.validateZip(); err != nil {
+			return buildError(conf, fmt.Errorf("error while validating planned zip for config at %s: %w", conf.Path, err))
+		}
+	} else if conf.ImageUri == "" {
+		if conf.Source != "" {
+			if err := d.fetchSource(ctx, conf); err != nil {
+				return buildError(conf, fmt.Errorf("error while fetching source for config at %s: %w", conf.Path, err))
+			}
+		}
+		if len(conf.Replace) > 0 && (conf.Bootstrap == "" || len(conf.Binaries) > 0) {
+			if err := conf.createReplaceModFile(ctx); err != nil {
+				return buildError(conf, fmt.Errorf("error while applying replace directives for config at %s: %w", conf.Path, err))
+			}
+		}
+		if conf.GoGenerate {
+			if err := d.generator.generate(ctx, conf); err != nil {
+				return buildError(conf, fmt.Errorf("error while generating code for config at %s: %w", conf.Path, err))
+			}
+		}
+		if d.opts.Concurrency > 1 && (conf.Bootstrap == "" || len(conf.Binaries) > 0) {
+			d.modules.warm(ctx, conf)
+		}
+		if d.opts.BuildProfileDir != "" {
+			if err := conf.openBuildTrace(d.opts.BuildProfileDir); err != nil {
+				return buildError(conf, fmt.Errorf("error while creating build trace for config at %s: %w", conf.Path, err))
+			}
+			defer conf.closeBuildTrace()
+		}
+
+		var buildArgs []string
+		if conf.Bootstrap != "" {
+			if err := conf.copyBootstrap(); err != nil {
+				return buildError(conf, fmt.Errorf("error while copying bootstrap for config at %s: %w", conf.Path, err))
+			}
+			defer d.deleteArtifact(conf.deleteBuildFile)
+		} else {
+			if d.opts.Race {
+				if err := conf.checkRaceSupport(); err != nil {
+					return buildError(conf, fmt.Errorf("error while checking build target for config at %s: %w", conf.Path, err))
+				}
+				buildArgs = append(buildArgs, raceArgs...)
+			}
+			if d.opts.DebugArchiveBucket != "" {
+				err := d.measure(conf.getFunctionName(), StepBuild, func() error {
+					return conf.build(ctx, conf.getDebugBuildOutputPath(), buildArgs...)
+				})
+				if err != nil {
+					return buildError(conf, fmt.Errorf("error while compiling debug build for config at %s: %w", conf.Path, err))
+				}
+				defer d.deleteArtifact(conf.deleteDebugBuildFile)
+
+				buildArgs = append(buildArgs, stripArgs...)
+			}
+
+			err := d.measure(conf.getFunctionName(), StepBuild, func() error {
+				return conf.build(ctx, conf.getBuildOutputPath(), buildArgs...)
+			})
+			if err != nil {
+				return buildError(conf, fmt.Errorf("error while compiling for config at %s: %w", conf.Path, err))
+			}
+			defer d.deleteArtifact(conf.deleteBuildFile)
+
+			if d.opts.EmitBuildInfo {
+				if err := conf.createBuildInfo(); err != nil {
+					return buildError(conf, fmt.Errorf("error while reading build info for config at %s: %w", conf.Path, err))
+				}
+			}
+		}
+
+		if len(conf.Binaries) > 0 {
+			err := d.measure(conf.getFunctionName(), StepBuild, func() error {
+				return conf.buildBinaries(ctx, buildArgs...)
+			})
+			if err != nil {
+				return buildError(conf, fmt.Errorf("error while compiling binaries for config at %s: %w", conf.Path, err))
+			}
+			defer d.deleteArtifact(conf.deleteBinaryFiles)
+		}
+
+		if len(conf.PostBuild) > 0 {
+			if err := conf.runHook(ctx, "postBuild", conf.PostBuild); err != nil {
+				return buildError(conf, fmt.Errorf("error while running post build hook for config at %s: %w", conf.Path, err))
+			}
+		}
+
+		if err := d.measure(conf.getFunctionName(), StepZip, conf.zipBuild); err != nil {
+			return buildError(conf, fmt.Errorf("error while building function config at %s: %w", conf.Path, err))
+		}
+		defer d.deleteArtifact(conf.deleteZipFile)
+
+		if err := conf.validateZip(); err != nil {
+			return buildError(conf, fmt.Errorf("error while validating zip for config at %s: %w", conf.Path, err))
+		}
+		if err := conf.checkUnzippedSize(); err != nil {
+			return buildError(conf, fmt.Errorf("error while checking package size for config at %s: %w", conf.Path, err))
+		}
+
+		size, err := conf.reportPackageSize()
+		if err != nil {
+			return buildError(conf, fmt.Errorf("error while reading package size for config at %s: %w", conf.Path, err))
+		}
+		if size > d.opts.SizeWarningThreshold {
+			err := d.warn("uncompressed package for lambda function %s is %s, close to the 250MB Lambda limit", conf.Name, formatBytes(size))
+			if err != nil {
+				return buildError(conf, fmt.Errorf("error while checking package size for config at %s: %w", conf.Path, err))
+			}
+		}
+	}
+
+	// The new zip is compared with the code of the resumed run, so local changes since then are still deployed
+	if d.opts.OutputDir == "" {
+		resumed, err := d.resume(ctx, conf, result)
+		if err != nil {
+			return deployError(conf, fmt.Errorf("error while resuming deploy for config at %s: %w", conf.Path, err))
+		}
+		if resumed {
+			logrus.Infof("skipped lambda function %s, it was already deployed by the resumed run", conf.Name)
+			result.Action = ActionSkipped
+			return nil
+		}
+	}
+
+	if len(conf.PreDeploy) > 0 && conf.plan == nil {
+		artifact := conf.getZipOutputPath()
+		if conf.ImageUri != "" {
+			artifact = conf.ImageUri
+		}
+		if err := conf.runHook(ctx, "preDeploy", conf.PreDeploy, artifactEnv+"="+artifact); err != nil {
+			return deployError(conf, fmt.Errorf("error while running pre deploy hook for config at %s: %w", conf.Path, err))
+		}
+	}
+
+	if d.opts.OutputDir != "" {
+		name := conf.getBuildName() + ".zip"
+		if d.opts.planning {
+			name = conf.getPlanZipName()
+		}
+		if err := conf.copyZipTo(d.opts.OutputDir, name); err != nil {
+			return buildError(conf, fmt.Errorf("error while copying zip for config at %s: %w", conf.Path, err))
+		}
+		result.Action = ActionPackaged
+		return nil
+	}
+
+	if d.opts.BackupDir != "" {
+		if err := d.backupCode(ctx, conf); err != nil {
+			return deployError(conf, fmt.Errorf("error while backing up code for config at %s: %w", conf.Path, err))
+		}
+	}
+
+	err := d.measure(conf.getFunctionName(), StepUpload, func() error {
+		return d.updateLambda(ctx, conf, result)
+	})
+	if err != nil {
+		return deployError(conf, fmt.Errorf("error while updating Lambda-Function for config at %s: %w", conf.Path, err))
+	}
+
+	if conf.ImageUri == "" && conf.Bootstrap == "" && d.opts.DebugArchiveBucket != "" {
+		if err := conf.uploadDebugArchive(ctx, d.s3, d.opts.DebugArchiveBucket, result.CodeSha256); err != nil {
+			return deployError(conf, fmt.Errorf("error while archiving debug build for config at %s: %w", conf.Path, err))
+		}
+	}
+	return nil
+}
+
+// deleteArtifact calls the given delete function unless Options.KeepArtifacts is set.
+// A failed cleanup is only logged, so it never masks the outcome of the deploy.
+func (d *deployer) deleteArtifact(remove func() error) {
+	if d.opts.KeepArtifacts {
+		return
+	}
+	if err := remove(); err != nil {
+		logrus.WithError(err).Warn("error while cleaning up")
+	}
+}
+
+// warn logs a warning for the given message.
+// In strict mode the warning is returned as an error instead.
+func (d *deployer) warn(format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	if d.opts.Strict {
+		return fmt.Errorf("%s (warning treated as error in strict mode)", message)
+	}
+	logrus.Warn(message)
+	return nil
+}
+
+// CheckDuplicateNames returns an error if two configs deploy to the same function.
+// Names, name:qualifier and ARNs are compared by their bare function name in the region of the function,
+// so foo, foo:PROD and the ARN of foo are the same function. Names without a region are only compared among each other,
+// Deploy runs this check in the default region before deploying anything.
+// Such configs would deploy to the same function and overwrite each others build output.
+func CheckDuplicateNames(configs []*FunctionConfig) error {
+	return checkDuplicateNames(configs, "")
+}
+
+// checkDuplicateNames implements CheckDuplicateNames, functions without a region are deployed to defaultRegion.
+func checkDuplicateNames(configs []*FunctionConfig, defaultRegion string) error {
+	paths := make(map[string]string, len(configs))
+	for _, config := range configs {
+		// Configs built through the library API aren't validated, the region of an ARN is only read from valid ARNs
+		if _, _, ok := splitFunctionName(config.Name); !ok {
+			return &ConfigError{Path: config.Path, Err: fmt.Errorf("name %s must be a function name, name:qualifier or function ARN", config.Name)}
+		}
+		region := config.getNameRegion()
+		if region == "" {
+			region = defaultRegion
+		}
+		key := region + "/" + config.getFunctionName()
+		if other, ok := paths[key]; ok {
+			return &ConfigError{Path: config.Path, Err: fmt.Errorf("function %s is declared by the configs at %s and %s", config.getFunctionName(), other, config.Path)}
+		}
+		paths[key] = config.Path
+	}
+	return nil
+}
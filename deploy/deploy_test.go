@@ -0,0 +1,363 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeployUpdatesCodeAndHandler(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].Handler = aws.String("main")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+
+	result := deployOne(t, conf, testOptions(client))
+
+	if result.Action != ActionUpdated {
+		t.Errorf("expected action %s, got %s", ActionUpdated, result.Action)
+	}
+	live := client.function("hello")
+	if result.CodeSha256 != aws.StringValue(live.CodeSha256) {
+		t.Errorf("expected code hash %s, got %s", aws.StringValue(live.CodeSha256), result.CodeSha256)
+	}
+	if handler := aws.StringValue(live.Handler); handler != "hello" {
+		t.Errorf("expected handler hello, got %s", handler)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(client.zips["hello"]), int64(len(client.zips["hello"])))
+	if err != nil {
+		t.Fatalf("uploaded zip is invalid: %v", err)
+	}
+	if len(reader.File) != 1 || reader.File[0].Name != "hello" {
+		t.Errorf("expected a zip with the single entry hello, got %v", reader.File)
+	}
+	if reader.File[0].Mode()&0111 == 0 {
+		t.Error("expected the binary to be executable")
+	}
+}
+
+func TestDeployLeavesMatchingHandler(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if count := client.count("UpdateFunctionConfiguration"); count != 0 {
+		t.Errorf("expected no configuration update, got %d", count)
+	}
+}
+
+func TestDeployReportsMissingFunction(t *testing.T) {
+	client := newFakeLambda()
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+
+	results, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil {
+		t.Fatal("expected an error for a missing function")
+	}
+	if len(results) != 1 || results[0].Action != ActionFailed {
+		t.Errorf("expected a failed result, got %v", results)
+	}
+}
+
+func TestDeployImageFunction(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].PackageType = aws.String(lambda.PackageTypeImage)
+	client.functions["hello"].Handler = nil
+	conf := newTestFunction(t, "name: hello\nimageUri: 123456789012.dkr.ecr.eu-central-1.amazonaws.com/hello:v2\npackageType: Image\n")
+
+	result := deployOne(t, conf, testOptions(client))
+
+	if result.Action != ActionUpdated {
+		t.Errorf("expected action %s, got %s", ActionUpdated, result.Action)
+	}
+	if client.zips["hello"] != nil {
+		t.Error("expected no zip to be uploaded for an image function")
+	}
+	if count := client.count("UpdateFunctionConfiguration"); count != 0 {
+		t.Errorf("expected the handler of the image function to be left alone, got %d configuration updates", count)
+	}
+}
+
+func TestDeployRejectsImageForZipFunction(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nimageUri: 123456789012.dkr.ecr.eu-central-1.amazonaws.com/hello:v2\npackageType: Image\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "package type is Zip but config declares Image") {
+		t.Errorf("expected a package type mismatch, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestCheckDuplicateNames(t *testing.T) {
+	arn := "arn:aws:lambda:eu-central-1:123456789012:function:hello"
+	tests := []struct {
+		name          string
+		configs       []*FunctionConfig
+		defaultRegion string
+		duplicate     bool
+	}{
+		{"distinct names", []*FunctionConfig{{Name: "hello"}, {Name: "world"}}, "", false},
+		{"qualified name", []*FunctionConfig{{Name: "hello"}, {Name: "hello:PROD"}}, "", true},
+		{"arn in the region of the name", []*FunctionConfig{{Name: "hello", Region: "eu-central-1"}, {Name: arn + ":PROD"}}, "", true},
+		{"arn in the default region", []*FunctionConfig{{Name: "hello"}, {Name: arn}}, "eu-central-1", true},
+		{"arn in another region", []*FunctionConfig{{Name: "hello"}, {Name: arn}}, "us-east-1", false},
+		{"qualifier after a name like an arn prefix", []*FunctionConfig{{Name: "arn"}, {Name: "arn:x"}}, "", true},
+		{"same name in different regions", []*FunctionConfig{{Name: "hello", Region: "eu-central-1"}, {Name: "hello", Region: "us-east-1"}}, "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, config := range test.configs {
+				config.Path = fmt.Sprintf("/functions/%d", i)
+			}
+
+			err := checkDuplicateNames(test.configs, test.defaultRegion)
+
+			var configErr *ConfigError
+			if test.duplicate && !errors.As(err, &configErr) {
+				t.Errorf("expected a ConfigError for the duplicate, got %v", err)
+			} else if !test.duplicate && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDeployRejectsMalformedARNWithoutValidation(t *testing.T) {
+	client := newFakeLambda("hello")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{{Name: "arn:aws:lambda", Path: "/functions/hello"}}, testOptions(client))
+
+	expectConfigError(t, err, "name arn:aws:lambda must be a function name, name:qualifier or function ARN")
+	if calls := client.mutations(); len(calls) != 0 {
+		t.Errorf("expected no calls, got %v", calls)
+	}
+}
+
+func TestDeployRejectsDuplicatesBeforeDeploying(t *testing.T) {
+	client := newFakeLambda("hello")
+	first := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	second := newTestFunction(t, "name: hello:PROD\nfileName: main.go\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{first, second}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "function hello is declared by the configs") {
+		t.Errorf("expected a duplicate error, got %v", err)
+	}
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected nothing to be deployed, got %v", mutations)
+	}
+}
+
+func TestDeployBuildsFunctionsInParallel(t *testing.T) {
+	names := []string{"alpha", "beta", "gamma", "delta"}
+	client := newFakeLambda(names...)
+	var configs []*FunctionConfig
+	for _, name := range names {
+		configs = append(configs, newTestFunction(t, "name: "+name+"\nfileName: main.go\n"))
+	}
+	opts := testOptions(client)
+	opts.Concurrency = 3
+
+	results, err := Deploy(context.Background(), configs, opts)
+	if err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+
+	buildDirs := map[string]bool{}
+	for i, result := range results {
+		if result.Name != names[i] || result.Action != ActionUpdated {
+			t.Errorf("expected %s to be updated at position %d, got %v", names[i], i, result)
+		}
+		if entries := client.zipEntries(t, names[i]); len(entries) != 1 || entries[0] != names[i] {
+			t.Errorf("expected the zip of %s to contain its own binary, got %v", names[i], entries)
+		}
+		buildDirs[configs[i].buildDir] = true
+	}
+	if len(buildDirs) != len(names) {
+		t.Errorf("expected a build directory per function, got %v", buildDirs)
+	}
+}
+
+func TestDeployStopsStartingFunctionsAfterFailure(t *testing.T) {
+	client := newFakeLambda("alpha", "gamma")
+	var configs []*FunctionConfig
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		configs = append(configs, newTestFunction(t, "name: "+name+"\nfileName: main.go\n"))
+	}
+
+	results, err := Deploy(context.Background(), configs, testOptions(client))
+
+	if err == nil {
+		t.Fatal("expected the missing function beta to fail the deploy")
+	}
+	if len(results) != 2 || results[1].Name != "beta" || results[1].Action != ActionFailed {
+		t.Errorf("expected alpha and the failed beta, got %v", results)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 1 {
+		t.Errorf("expected only alpha to be deployed, got %d code updates", count)
+	}
+}
+
+func TestWarnReturnsErrorInStrictMode(t *testing.T) {
+	logs := captureLogs(t)
+	d := &deployer{opts: Options{}}
+	if err := d.warn("package of %s is large", "hello"); err != nil {
+		t.Errorf("expected no error outside of strict mode, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "package of hello is large") {
+		t.Errorf("expected the warning to be logged, got logs %s", logs)
+	}
+
+	d.opts.Strict = true
+	err := d.warn("package of %s is large", "hello")
+	if err == nil || err.Error() != "package of hello is large (warning treated as error in strict mode)" {
+		t.Errorf("expected the warning as error, got %v", err)
+	}
+}
+
+func TestDeployFailsOnWarningInStrictMode(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.Strict = true
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, opts)
+
+	if err == nil || !strings.Contains(err.Error(), "uses the deprecated runtime go1.x") {
+		t.Errorf("expected the deprecation warning to fail the deploy, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestDeleteArtifactOnlyLogsFailures(t *testing.T) {
+	logs := captureLogs(t)
+	d := &deployer{opts: Options{}}
+
+	d.deleteArtifact(func() error { return errors.New("error while deleting build at /tmp/hello") })
+
+	if !strings.Contains(logs.String(), "error while cleaning up") || !strings.Contains(logs.String(), "/tmp/hello") {
+		t.Errorf("expected the failed cleanup to be logged, got logs %s", logs)
+	}
+}
+
+func TestDeleteArtifactKeepsArtifacts(t *testing.T) {
+	d := &deployer{opts: Options{KeepArtifacts: true}}
+
+	d.deleteArtifact(func() error {
+		t.Error("expected the artifact to be kept")
+		return nil
+	})
+}
+
+func TestDeployRejectsZipForImageFunction(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].PackageType = aws.String(lambda.PackageTypeImage)
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\npackageType: Zip\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "package type is Image but config declares Zip") {
+		t.Errorf("expected a package type mismatch, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestDeploySkipsAllFunctionsWithCancelledContext(t *testing.T) {
+	client := newFakeLambda("alpha", "beta")
+	configs := []*FunctionConfig{newTestFunction(t, "name: alpha\nfileName: main.go\n"), newTestFunction(t, "name: beta\nfileName: main.go\n")}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := Deploy(ctx, configs, testOptions(client))
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation error, got %v", err)
+	}
+	for _, result := range results {
+		if result.Action != ActionSkipped {
+			t.Errorf("expected %s to be skipped, got %s", result.Name, result.Action)
+		}
+	}
+	if len(results) != 2 {
+		t.Errorf("expected a result for every function, got %v", results)
+	}
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected nothing to be deployed, got %v", mutations)
+	}
+}
+
+// cancelRecorder cancels the run once the first upload is recorded.
+type cancelRecorder struct {
+	cancel context.CancelFunc
+}
+
+func (r cancelRecorder) RecordDuration(function string, step string, duration time.Duration) {
+	if step == StepUpload {
+		r.cancel()
+	}
+}
+
+func TestDeploySkipsRemainingFunctionsAfterCancellation(t *testing.T) {
+	names := []string{"alpha", "beta", "gamma"}
+	client := newFakeLambda(names...)
+	var configs []*FunctionConfig
+	for _, name := range names {
+		configs = append(configs, newTestFunction(t, "name: "+name+"\nfileName: main.go\n"))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := testOptions(client)
+	opts.Metrics = cancelRecorder{cancel: cancel}
+
+	results, err := Deploy(ctx, configs, opts)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation error, got %v", err)
+	}
+	if len(results) != 3 || results[1].Action != ActionSkipped || results[2].Action != ActionSkipped {
+		t.Errorf("expected beta and gamma to be skipped, got %v", results)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 1 {
+		t.Errorf("expected only alpha to be uploaded, got %d code updates", count)
+	}
+}
+
+func TestDeployRecordsDuration(t *testing.T) {
+	logs := captureLogs(t)
+	client := newFakeLambda("hello")
+	configs := []*FunctionConfig{
+		newTestFunction(t, "name: hello\nfileName: main.go\n"),
+		newTestFunction(t, "name: missing\nfileName: main.go\n"),
+	}
+
+	results, _ := Deploy(context.Background(), configs, testOptions(client))
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", results)
+	}
+	if results[0].DurationMs <= 0 {
+		t.Errorf("expected a positive duration for the built function, got %dms", results[0].DurationMs)
+	}
+	for _, message := range []string{"finished lambda function hello in ", "finished lambda function missing in "} {
+		if !strings.Contains(logs.String(), message) {
+			t.Errorf("expected the logs to contain %q, got %s", message, logs.String())
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package deploy
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+// planDryRun reports what a deploy of the function would change without building or mutating anything.
+// If Options.Diff is set, the differing configuration fields are written to it with their live and declared values.
+func (d *deployer) planDryRun(ctx context.Context, conf *FunctionConfig, result *Result) error {
+	result.Action = ActionDryRun
+
+	info, err := d.getLiveConfig(ctx, conf)
+	if err != nil {
+		return err
+	}
+	// The declared environment is compared with the decrypted live values, the diff masks them unless ShowSecrets is set
+	if info, err = d.decryptEnvironment(ctx, conf, info); err != nil {
+		return err
+	}
+	input, changes, err := d.planConfiguration(conf, info)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		logrus.Infof("dry run: would update the code of lambda function %s, configuration is up to date", conf.Name)
+	} else {
+		logrus.Infof("dry run: would update the code and %s of lambda function %s", strings.Join(changes, ", "), conf.Name)
+	}
+	if conf.ReservedConcurrency != nil {
+		logrus.Infof("dry run: would reserve %d concurrent executions for lambda function %s", *conf.ReservedConcurrency, conf.Name)
+	} else if conf.RemoveReservedConcurrency {
+		logrus.Infof("dry run: would remove the reserved concurrency of lambda function %s", conf.Name)
+	}
+	if conf.Alias != "" && conf.CanaryWeight > 0 {
+		logrus.Infof("dry run: would publish a new version of lambda function %s and route %g of alias %s to it", conf.Name, conf.CanaryWeight, conf.Alias)
+	} else if conf.Alias != "" {
+		logrus.Infof("dry run: would publish a new version of lambda function %s and point alias %s to it", conf.Name, conf.Alias)
+	} else if conf.Publish {
+		logrus.Infof("dry run: would publish a new version of lambda function %s", conf.Name)
+	}
+
+	if d.opts.Diff != nil {
+		d.diffMutex.Lock()
+		defer d.diffMutex.Unlock()
+		_, err := fmt.Fprint(d.opts.Diff, formatDiff(conf, info, input, d.opts.ShowSecrets))
+		return err
+	}
+	return nil
+}
+
+// decryptEnvironment returns a copy of info with the environment variables decrypted that were encrypted
+// with the KMS key of the function, like the Lambda console encryption helpers do.
+// Values that aren't valid ciphertexts of that key are kept as they are.
+func (d *deployer) decryptEnvironment(ctx context.Context, conf *FunctionConfig, info *lambda.FunctionConfiguration) (*lambda.FunctionConfiguration, error) {
+	if info.KMSKeyArn == nil || info.Environment == nil {
+		return info, nil
+	}
+
+	variables := make(map[string]*string, len(info.Environment.Variables))
+	for key, value := range info.Environment.Variables {
+		variables[key] = value
+		ciphertext, err := base64.StdEncoding.DecodeString(aws.StringValue(value))
+		if err != nil || len(ciphertext) == 0 {
+			continue
+		}
+		output, err := d.kms.DecryptWithContext(ctx, &kms.DecryptInput{
+			CiphertextBlob:    ciphertext,
+			KeyId:             info.KMSKeyArn,
+			EncryptionContext: map[string]*string{"LambdaFunctionName": aws.String(conf.getFunctionName())},
+		})
+		if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == kms.ErrCodeInvalidCiphertextException || aerr.Code() == kms.ErrCodeIncorrectKeyException) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error while decrypting environment variable %s: %w", key, err)
+		}
+		variables[key] = aws.String(string(output.Plaintext))
+	}
+
+	decrypted := *info
+	decrypted.Environment = &lambda.EnvironmentResponse{Variables: variables}
+	return &decrypted, nil
+}
+
+// formatDiff formats every field set in input next to its live value.
+// Environment variables are listed by key only, so resolved secrets are never printed, unless showSecrets is set.
+func formatDiff(conf *FunctionConfig, info *lambda.FunctionConfiguration, input *lambda.UpdateFunctionConfigurationInput, showSecrets bool) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s (live)\n+++ %s (config)\n", conf.Name, conf.Name)
+
+	field := func(name string, live interface{}, declared interface{}) {
+		fmt.Fprintf(&builder, "  %s: %v -> %v\n", name, live, declared)
+	}
+	if input.Handler != nil {
+		field("handler", aws.StringValue(info.Handler), aws.StringValue(input.Handler))
+	}
+	if input.MemorySize != nil {
+		field("memorySize", aws.Int64Value(info.MemorySize), aws.Int64Value(input.MemorySize))
+	}
+	if input.Timeout != nil {
+		field("timeout", aws.Int64Value(info.Timeout), aws.Int64Value(input.Timeout))
+	}
+	if input.Layers != nil {
+		var live []string
+		for _, layer := range info.Layers {
+			live = append(live, aws.StringValue(layer.Arn))
+		}
+		field("layers", live, aws.StringValueSlice(input.Layers))
+	}
+	if input.LoggingConfig != nil {
+		live := info.LoggingConfig
+		if live == nil {
+			live = &lambda.LoggingConfig{}
+		}
+		field("loggingConfig", formatLoggingConfig(live), formatLoggingConfig(input.LoggingConfig))
+	}
+	if input.Environment != nil {
+		var liveVariables map[string]*string
+		if info.Environment != nil {
+			liveVariables = info.Environment.Variables
+		}
+		builder.WriteString("  environment:\n")
+		for _, line := range diffVariables(liveVariables, input.Environment.Variables, showSecrets) {
+			fmt.Fprintf(&builder, "    %s\n", line)
+		}
+	}
+	return builder.String()
+}
+
+// formatLoggingConfig formats the fields of a logging config in a stable order.
+func formatLoggingConfig(config *lambda.LoggingConfig) string {
+	return fmt.Sprintf("{logFormat: %s, applicationLogLevel: %s, systemLogLevel: %s, logGroup: %s}",
+		aws.StringValue(config.LogFormat), aws.StringValue(config.ApplicationLogLevel),
+		aws.StringValue(config.SystemLogLevel), aws.StringValue(config.LogGroup))
+}
+
+// diffVariables lists the added (+), removed (-) and changed (~) keys between two sets of environment variables.
+// The values are only listed with showValues.
+func diffVariables(live map[string]*string, declared map[string]*string, showValues bool) []string {
+	keys := map[string]bool{}
+	for key := range live {
+		keys[key] = true
+	}
+	for key := range declared {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var lines []string
+	for _, key := range sorted {
+		liveValue, inLive := live[key]
+		declaredValue, inDeclared := declared[key]
+		switch {
+		case !inLive && showValues:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", key, aws.StringValue(declaredValue)))
+		case !inLive:
+			lines = append(lines, "+ "+key)
+		case !inDeclared && showValues:
+			lines = append(lines, fmt.Sprintf("- %s: %s", key, aws.StringValue(liveValue)))
+		case !inDeclared:
+			lines = append(lines, "- "+key)
+		case aws.StringValue(liveValue) == aws.StringValue(declaredValue):
+		case showValues:
+			lines = append(lines, fmt.Sprintf("~ %s: %s -> %s", key, aws.StringValue(liveValue), aws.StringValue(declaredValue)))
+		default:
+			lines = append(lines, "~ "+key)
+		}
+	}
+	return lines
+}
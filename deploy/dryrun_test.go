@@ -0,0 +1,94 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDryRunWritesDiffWithoutMutations(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].Handler = aws.String("main")
+	client.functions["hello"].MemorySize = aws.Int64(128)
+	client.functions["hello"].Environment = &lambda.EnvironmentResponse{Variables: aws.StringMap(map[string]string{"STAGE": "dev", "OLD": "1"})}
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nmemorySize: 512\nenvironment:\n  STAGE: prod\n  TOKEN: secret\n")
+	var diff bytes.Buffer
+	opts := testOptions(client)
+	opts.DryRun = true
+	opts.Diff = &diff
+
+	result := deployOne(t, conf, opts)
+
+	if result.Action != ActionDryRun {
+		t.Errorf("expected action %s, got %s", ActionDryRun, result.Action)
+	}
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected no mutations, got %v", mutations)
+	}
+	expected := "--- hello (live)\n+++ hello (config)\n" +
+		"  handler: main -> hello\n" +
+		"  memorySize: 128 -> 512\n" +
+		"  environment:\n" +
+		"    - OLD\n" +
+		"    ~ STAGE\n" +
+		"    + TOKEN\n"
+	if diff.String() != expected {
+		t.Errorf("expected diff\n%s\ngot\n%s", expected, diff.String())
+	}
+}
+
+func TestDiffVariablesShowsValuesOnlyIfRequested(t *testing.T) {
+	live := aws.StringMap(map[string]string{"STAGE": "dev", "OLD": "1", "REGION": "eu-central-1"})
+	declared := aws.StringMap(map[string]string{"STAGE": "prod", "TOKEN": "secret", "REGION": "eu-central-1"})
+
+	if lines := diffVariables(live, declared, false); !reflect.DeepEqual(lines, []string{"- OLD", "~ STAGE", "+ TOKEN"}) {
+		t.Errorf("expected only the keys, got %v", lines)
+	}
+	if lines := diffVariables(live, declared, true); !reflect.DeepEqual(lines, []string{"- OLD: 1", "~ STAGE: dev -> prod", "+ TOKEN: secret"}) {
+		t.Errorf("expected the values, got %v", lines)
+	}
+}
+
+func TestDryRunDiffsDecryptedLiveSecrets(t *testing.T) {
+	encrypted := func(plaintext string) *string {
+		return aws.String(base64.StdEncoding.EncodeToString([]byte("encrypted:" + plaintext)))
+	}
+	tests := []struct {
+		name        string
+		showSecrets bool
+		expected    string
+	}{
+		{"masked", false, "  environment:\n    ~ TOKEN\n"},
+		{"shown", true, "  environment:\n    ~ TOKEN: old -> new\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeLambda("hello")
+			client.functions["hello"].KMSKeyArn = aws.String("arn:aws:kms:eu-central-1:" + testAccount + ":key/hello")
+			client.functions["hello"].Environment = &lambda.EnvironmentResponse{Variables: map[string]*string{
+				"TOKEN": encrypted("old"),
+				"SAME":  encrypted("same"),
+				"STAGE": aws.String("prod"),
+			}}
+			conf := newTestFunction(t, "name: hello\nfileName: main.go\nenvironment:\n  TOKEN: new\n  SAME: same\n  STAGE: prod\n")
+			var diff bytes.Buffer
+			opts := testOptions(client)
+			opts.DryRun = true
+			opts.Diff = &diff
+			opts.ShowSecrets = test.showSecrets
+
+			deployOne(t, conf, opts)
+
+			if !strings.HasSuffix(diff.String(), test.expected) {
+				t.Errorf("expected the diff to end with\n%s\ngot\n%s", test.expected, diff.String())
+			}
+			if !test.showSecrets && (strings.Contains(diff.String(), "old") || strings.Contains(diff.String(), "new")) {
+				t.Errorf("expected no secret values, got\n%s", diff.String())
+			}
+		})
+	}
+}
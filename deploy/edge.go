@@ -0,0 +1,40 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// edgeRegion is the region Lambda@Edge functions must be deployed to, CloudFront replicates them from there.
+const edgeRegion = "us-east-1"
+
+// getRegion returns the region the function is deployed to, empty for the default region.
+// Edge functions are always deployed to us-east-1.
+func (conf *FunctionConfig) getRegion() string {
+	if conf.Edge && conf.Region == "" {
+		return edgeRegion
+	}
+	return conf.Region
+}
+
+// validateEdge checks the constraints of Lambda@Edge for Edge functions: they are deployed to us-east-1,
+// CloudFront is associated with a published version and environment variables aren't supported.
+func (conf *FunctionConfig) validateEdge() error {
+	if !conf.Edge {
+		return nil
+	}
+	if conf.Region != "" && conf.Region != edgeRegion {
+		return fmt.Errorf("edge functions must be deployed to %s, not %s", edgeRegion, conf.Region)
+	}
+	if strings.HasPrefix(conf.Name, "arn:") && strings.Split(conf.Name, ":")[3] != edgeRegion {
+		return fmt.Errorf("edge functions must be deployed to %s, name %s is in another region", edgeRegion, conf.Name)
+	}
+	if !conf.Publish {
+		return errors.New("edge functions require publish to be true, CloudFront can only use published versions")
+	}
+	if len(conf.Environment) > 0 {
+		return errors.New("environment can't be used with edge, Lambda@Edge doesn't support environment variables")
+	}
+	return nil
+}
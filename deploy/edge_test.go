@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeployEdgeFunctionToUSEast1(t *testing.T) {
+	logs := captureLogs(t)
+	client := newFakeLambda("hello")
+
+	result := deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\nedge: true\npublish: true\n"), testOptions(client))
+
+	if result.Region != edgeRegion || result.Version != "1" {
+		t.Errorf("expected version 1 in %s, got %+v", edgeRegion, result)
+	}
+	if !strings.Contains(logs.String(), "associate CloudFront with arn:aws:lambda:eu-central-1:"+testAccount+":function:hello:1") {
+		t.Errorf("expected the version ARN to be logged, got logs %s", logs.String())
+	}
+}
+
+func TestParseEnforcesEdgeConstraints(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{"other region", "name: hello\nedge: true\npublish: true\nregion: eu-west-1\n", "edge functions must be deployed to us-east-1, not eu-west-1"},
+		{"arn in other region", "name: arn:aws:lambda:eu-west-1:123456789012:function:hello\nedge: true\npublish: true\n", "name arn:aws:lambda:eu-west-1:123456789012:function:hello is in another region"},
+		{"not published", "name: hello\nedge: true\n", "edge functions require publish to be true"},
+		{"environment", "name: hello\nedge: true\npublish: true\nenvironment:\n  STAGE: prod\n", "environment can't be used with edge"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, "main.go", testMain)
+
+			_, err := ParseFunctionConfigFromReader(strings.NewReader(test.config+"fileName: main.go\n"), dir)
+
+			expectConfigError(t, err, test.message)
+		})
+	}
+}
+
+func TestGetRegion(t *testing.T) {
+	tests := []struct {
+		conf     FunctionConfig
+		expected string
+	}{
+		{FunctionConfig{}, ""},
+		{FunctionConfig{Region: "eu-west-1"}, "eu-west-1"},
+		{FunctionConfig{Edge: true}, edgeRegion},
+		{FunctionConfig{Edge: true, Region: edgeRegion}, edgeRegion},
+	}
+	for _, test := range tests {
+		if region := test.conf.getRegion(); region != test.expected {
+			t.Errorf("expected region %q for %+v, got %q", test.expected, test.conf, region)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package deploy
+
+// ConfigError is returned for function configs that can't be parsed or are invalid.
+type ConfigError struct {
+	// Path is the directory of the function.
+	Path string
+	Err  error
+}
+
+// Error returns the message of the underlying error.
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// BuildError is returned if building, zipping or packaging a function failed.
+type BuildError struct {
+	// Function is the name of the function.
+	Function string
+	Err      error
+}
+
+// Error returns the message of the underlying error.
+func (e *BuildError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// DeployError is returned if a function could not be deployed after it was built,
+// mostly because an AWS API call, a hook or a check against the live function failed.
+type DeployError struct {
+	// Function is the name of the function.
+	Function string
+	Err      error
+}
+
+// Error returns the message of the underlying error.
+func (e *DeployError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *DeployError) Unwrap() error {
+	return e.Err
+}
+
+// buildError wraps err in a BuildError for the function.
+func buildError(conf *FunctionConfig, err error) error {
+	return &BuildError{Function: conf.Name, Err: err}
+}
+
+// deployError wraps err in a DeployError for the function.
+func deployError(conf *FunctionConfig, err error) error {
+	return &DeployError{Function: conf.Name, Err: err}
+}
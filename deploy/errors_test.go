@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"strings"
+	"testing"
+)
+
+func TestParseReturnsConfigError(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := ParseFunctionConfigFromReader(strings.NewReader("name: hello\n"), dir)
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) || configErr.Path != dir {
+		t.Errorf("expected a ConfigError for %s, got %#v", dir, err)
+	}
+}
+
+func TestDeployReturnsBuildError(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n", "main.go", "package main\n\nfunc main() { undefined() }\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) || buildErr.Function != "hello" {
+		t.Errorf("expected a BuildError for hello, got %#v", err)
+	}
+	var deployErr *DeployError
+	if errors.As(err, &deployErr) {
+		t.Errorf("expected no DeployError, got %v", deployErr)
+	}
+}
+
+func TestDeployReturnsDeployErrorWrappingAWSError(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.fail("UpdateFunctionCode", awserr.New(lambda.ErrCodeInvalidParameterValueException, "invalid zip", nil))
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	var deployErr *DeployError
+	if !errors.As(err, &deployErr) || deployErr.Function != "hello" {
+		t.Fatalf("expected a DeployError for hello, got %#v", err)
+	}
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) || awsErr.Code() != lambda.ErrCodeInvalidParameterValueException {
+		t.Errorf("expected the AWS error to be unwrapped, got %v", err)
+	}
+}
+
+func TestDeployErrorsKeepTheirCause(t *testing.T) {
+	cause := errors.New("cause")
+	conf := &FunctionConfig{Name: "hello"}
+
+	for _, err := range []error{buildError(conf, cause), deployError(conf, cause), &ConfigError{Path: "/tmp", Err: cause}} {
+		if !errors.Is(err, cause) || err.Error() != "cause" {
+			t.Errorf("expected %T to wrap the cause, got %v", err, err)
+		}
+	}
+}
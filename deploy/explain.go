@@ -0,0 +1,131 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// explain writes a description of every step a deploy of the function would take to Options.Explain.
+// Like planDryRun it only reads the live function, nothing is built or mutated.
+func (d *deployer) explain(ctx context.Context, conf *FunctionConfig, result *Result) error {
+	result.Action = ActionDryRun
+
+	info, err := d.getLiveConfig(ctx, conf)
+	if err != nil {
+		return err
+	}
+	_, changes, err := d.planConfiguration(conf, info)
+	if err != nil {
+		return err
+	}
+
+	var steps []string
+	step := func(format string, args ...interface{}) {
+		steps = append(steps, fmt.Sprintf(format, args...))
+	}
+
+	if conf.Source != "" && getArchiveType(conf.Source) == "" {
+		step("Builds from the directory %s instead of the function directory.", conf.Source)
+	} else if conf.Source != "" {
+		step("Fetches and extracts the source archive %s and builds from it.", conf.Source)
+	}
+	goos, goarch := conf.getBuildTarget()
+	switch {
+	case conf.ImageUri != "":
+		step("Nothing is built, the image %s is built and pushed outside of lambda-ci.", conf.ImageUri)
+	case conf.Bootstrap != "":
+		step("Nothing is built, the prebuilt binary %s is used.", conf.Bootstrap)
+	default:
+		step("Builds %s with go build for %s/%s.", strings.Join(conf.getSourceFileNames(), ", "), goos, goarch)
+	}
+	for _, binary := range conf.Binaries {
+		step("Builds %s with the same environment for the additional binary %s.", binary.Source, binary.ZipEntryName)
+	}
+	if len(conf.PostBuild) > 0 {
+		step("Runs the postBuild hook %s.", strings.Join(conf.PostBuild, " "))
+	}
+
+	if conf.ImageUri == "" {
+		entries := []string{fmt.Sprintf("the binary as %s", conf.getZipEntryName())}
+		for _, binary := range conf.Binaries {
+			entries = append(entries, binary.ZipEntryName)
+		}
+		for _, extension := range conf.Extensions {
+			entries = append(entries, fmt.Sprintf("the extension %s as %s", extension, getExtensionEntryName(extension)))
+		}
+		if conf.IncludeDir != "" {
+			entries = append(entries, fmt.Sprintf("the files below %s that aren't listed in its %s", conf.IncludeDir, ignoreFileName))
+		}
+		if d.opts.EmitBuildInfo {
+			entries = append(entries, "the build info as "+buildInfoEntryName)
+		}
+		if len(entries) > 1 {
+			entries[len(entries)-1] = "and " + entries[len(entries)-1]
+		}
+		step("Zips %s.", strings.Join(entries, ", "))
+	}
+	if len(conf.PreDeploy) > 0 {
+		step("Runs the preDeploy hook %s.", strings.Join(conf.PreDeploy, " "))
+	}
+
+	switch {
+	case conf.ImageUri != "":
+		step("Points lambda function %s to the image with UpdateFunctionCode.", conf.Name)
+	case d.opts.ArtifactBucket != "" && conf.SigningProfile != "":
+		step("Uploads the zip to s3://%s, signs it with the AWS Signer profile %s and deploys the signed zip to lambda function %s with UpdateFunctionCode.", d.opts.ArtifactBucket, conf.SigningProfile, conf.Name)
+	case d.opts.ArtifactBucket != "":
+		step("Uploads the zip to s3://%s and deploys it to lambda function %s with UpdateFunctionCode.", d.opts.ArtifactBucket, conf.Name)
+	default:
+		step("Uploads the zip to lambda function %s with UpdateFunctionCode.", conf.Name)
+	}
+	if len(changes) > 0 {
+		step("Updates %s of the function with UpdateFunctionConfiguration.", strings.Join(changes, ", "))
+	} else {
+		step("Leaves the configuration as it is, it already matches the config.")
+	}
+
+	if conf.ReservedConcurrency != nil {
+		step("Reserves %d concurrent executions with PutFunctionConcurrency.", *conf.ReservedConcurrency)
+	} else if conf.RemoveReservedConcurrency {
+		step("Removes the reserved concurrency with DeleteFunctionConcurrency.")
+	}
+	if conf.LogRetentionDays > 0 {
+		step("Sets the retention of the log group %s to %d days, the log group is created if it is missing.", conf.getLogGroupName(), conf.LogRetentionDays)
+	}
+	if conf.Alias != "" {
+		step("Publishes a new version with PublishVersion.")
+		if conf.ProvisionedConcurrency > 0 {
+			step("Configures %d provisioned concurrent executions on the new version and waits until they are ready.", conf.ProvisionedConcurrency)
+		}
+		if conf.HealthCheck != nil {
+			step("Invokes the new version to check its health.")
+		}
+		if conf.CanaryWeight > 0 {
+			step("Routes %g of the traffic of alias %s to the new version with UpdateAlias.", conf.CanaryWeight, conf.Alias)
+		} else {
+			step("Points alias %s to the new version with UpdateAlias.", conf.Alias)
+		}
+	} else if conf.Publish {
+		step("Publishes a new version with PublishVersion.")
+		if conf.HealthCheck != nil {
+			step("Invokes the new version to check its health.")
+		}
+	} else if conf.HealthCheck != nil {
+		step("Invokes $LATEST to check its health.")
+	}
+	if conf.Edge {
+		step("Logs the version ARN to associate with CloudFront, the function is deployed to %s for Lambda@Edge.", edgeRegion)
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "lambda function %s (%s):\n", conf.Name, conf.Path)
+	for i, s := range steps {
+		fmt.Fprintf(&builder, "  %d. %s\n", i+1, s)
+	}
+
+	d.diffMutex.Lock()
+	defer d.diffMutex.Unlock()
+	_, err = fmt.Fprint(d.opts.Explain, builder.String())
+	return err
+}
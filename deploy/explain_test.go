@@ -0,0 +1,57 @@
+package deploy
+
+import (
+	"bytes"
+	"github.com/aws/aws-sdk-go/aws"
+	"testing"
+)
+
+func TestExplainDescribesStepsWithoutMutations(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nmemorySize: 512\nalias: live\nlogRetentionDays: 14\n")
+	var explanation bytes.Buffer
+	opts := testOptions(client)
+	opts.Explain = &explanation
+
+	result := deployOne(t, conf, opts)
+
+	if result.Action != ActionDryRun {
+		t.Errorf("expected action %s, got %s", ActionDryRun, result.Action)
+	}
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected no mutations, got %v", mutations)
+	}
+	expected := "lambda function hello (" + conf.Path + "):\n" +
+		"  1. Builds main.go with go build for linux/amd64.\n" +
+		"  2. Zips the binary as hello.\n" +
+		"  3. Uploads the zip to lambda function hello with UpdateFunctionCode.\n" +
+		"  4. Updates memory size of the function with UpdateFunctionConfiguration.\n" +
+		"  5. Sets the retention of the log group /aws/lambda/hello to 14 days, the log group is created if it is missing.\n" +
+		"  6. Publishes a new version with PublishVersion.\n" +
+		"  7. Points alias live to the new version with UpdateAlias.\n"
+	if explanation.String() != expected {
+		t.Errorf("expected explanation\n%s\ngot\n%s", expected, explanation.String())
+	}
+}
+
+func TestExplainDescribesStagingAndArm64(t *testing.T) {
+	client := newFakeLambda()
+	client.addFunction("hello", "provided.al2023").Architectures = []*string{aws.String("arm64")}
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nruntime: provided.al2023\narchitecture: arm64\n")
+	var explanation bytes.Buffer
+	opts := testOptions(client)
+	opts.Explain = &explanation
+	opts.ArtifactBucket = "artifacts"
+
+	deployOne(t, conf, opts)
+
+	for _, step := range []string{
+		"  1. Builds main.go with go build for linux/arm64.\n",
+		"  2. Zips the binary as bootstrap.\n",
+		"  3. Uploads the zip to s3://artifacts and deploys it to lambda function hello with UpdateFunctionCode.\n",
+	} {
+		if !bytes.Contains(explanation.Bytes(), []byte(step)) {
+			t.Errorf("expected the explanation to contain %q, got\n%s", step, explanation.String())
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// extensionsDir is the directory of the zip archive Lambda starts external extensions from, mounted at /opt/extensions.
+const extensionsDir = "extensions"
+
+// getExtensionEntryName returns the path of the given extension binary inside the zip archive.
+func getExtensionEntryName(extension string) string {
+	return path.Join(extensionsDir, path.Base(extension))
+}
+
+// validateExtensions checks that all Extensions are clean relative paths whose entries collide
+// neither with each other nor with the function binary or the Binaries.
+func (conf *FunctionConfig) validateExtensions() error {
+	if len(conf.Extensions) > 0 && conf.ImageUri != "" {
+		return errors.New("extensions can't be used with imageUri")
+	}
+	entryNames := map[string]bool{}
+	for _, extension := range conf.Extensions {
+		if extension == "" || !isCleanRelativePath(extension) {
+			return fmt.Errorf("extensions entry %q must be a clean relative path", extension)
+		}
+		name := getExtensionEntryName(extension)
+		if entryNames[name] || name == conf.getZipEntryName() || conf.isBinaryEntry(name) {
+			return fmt.Errorf("extension %s collides with another entry of the zip archive", name)
+		}
+		entryNames[name] = true
+	}
+	return nil
+}
+
+// isExtensionEntry reports whether name is the zip entry name of one of the Extensions.
+func (conf *FunctionConfig) isExtensionEntry(name string) bool {
+	for _, extension := range conf.Extensions {
+		if getExtensionEntryName(extension) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// zipExtensions adds all Extensions to the zip writer below extensionsDir.
+// Lambda only starts executable extensions, so the entries are always written with mode 0755.
+func (conf *FunctionConfig) zipExtensions(writer *zip.Writer) error {
+	for _, extension := range conf.Extensions {
+		if err := zipExtension(writer, conf.getFullFilePath(extension), getExtensionEntryName(extension)); err != nil {
+			return fmt.Errorf("error while adding extension %s: %w", extension, err)
+		}
+	}
+	return nil
+}
+
+// zipExtension writes the extension binary at file into the zip writer as an executable named name.
+func zipExtension(writer *zip.Writer, file string, name string) error {
+	source, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", file)
+	}
+	header, err := newZipHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	header.SetMode(0755)
+
+	fileWriter, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fileWriter, source)
+	return err
+}
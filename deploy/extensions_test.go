@@ -0,0 +1,56 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeployZipsExtensionsAsExecutables(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nextensions: [vendor/otel-collector]\n", "vendor/otel-collector", "#!/bin/sh\n")
+	opts := testOptions(client)
+	opts.KeepArtifacts = true
+
+	deployOne(t, conf, opts)
+	defer os.RemoveAll(conf.buildDir)
+
+	if entries := client.zipEntries(t, "hello"); !reflect.DeepEqual(entries, []string{"hello", "extensions/otel-collector"}) {
+		t.Errorf("expected the function binary and extensions/otel-collector, got %v", entries)
+	}
+	if err := conf.validateZip(); err != nil {
+		t.Errorf("expected the extension written with mode 0644 to be executable, got %v", err)
+	}
+}
+
+func TestDeployRejectsMissingExtension(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nextensions: [vendor/otel-collector]\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "error while adding extension vendor/otel-collector") {
+		t.Errorf("expected the missing extension to fail the build, got %v", err)
+	}
+}
+
+func TestParseValidatesExtensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{"unclean path", "extensions: [../collector]\n", `extensions entry "../collector" must be a clean relative path`},
+		{"same base name", "extensions: [a/collector, b/collector]\n", "extension extensions/collector collides with another entry of the zip archive"},
+		{"function binary", "zipEntryName: extensions/collector\nextensions: [collector]\n", "extension extensions/collector collides with another entry of the zip archive"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseTestConfig(t, "name: hello\nfileName: main.go\n"+test.config, "main.go", testMain)
+
+			expectConfigError(t, err, test.message)
+		})
+	}
+}
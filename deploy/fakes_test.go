@@ -0,0 +1,861 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/signer"
+	"github.com/aws/aws-sdk-go/service/signer/signeriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testAccount is the account of the fake clients.
+const testAccount = "123456789012"
+
+// testMain is the source of a function that builds without dependencies.
+const testMain = "package main\n\nfunc main() {}\n"
+
+// TestMain silences the deploy logs, tests that check them use captureLogs.
+func TestMain(m *testing.M) {
+	logrus.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// captureLogs records the logs until the end of the test.
+func captureLogs(t *testing.T) *syncBuffer {
+	t.Helper()
+	buffer := &syncBuffer{}
+	logrus.SetOutput(buffer)
+	t.Cleanup(func() { logrus.SetOutput(ioutil.Discard) })
+	return buffer
+}
+
+// syncBuffer is a string buffer for concurrent writers.
+type syncBuffer struct {
+	mutex sync.Mutex
+	data  strings.Builder
+}
+
+// Write appends p to the buffer.
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.data.Write(p)
+}
+
+// String returns the written data.
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.data.String()
+}
+
+// writeFiles writes the given files, pairs of a path relative to dir and its content.
+func writeFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for i := 0; i+1 < len(files); i += 2 {
+		path := filepath.Join(dir, files[i])
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(files[i+1]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newTestFunction writes a function directory with a main.go and the given files and parses the config.
+func newTestFunction(t *testing.T, config string, files ...string) *FunctionConfig {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, append([]string{"main.go", testMain}, files...)...)
+	conf, err := ParseFunctionConfigFromReader(strings.NewReader(config), dir)
+	if err != nil {
+		t.Fatalf("error while parsing config: %v", err)
+	}
+	return conf
+}
+
+// testOptions returns Options with fakes for all clients, so Deploy makes no AWS calls.
+func testOptions(client *fakeLambda) Options {
+	return Options{
+		Lambda:         client,
+		S3:             &fakeS3{},
+		STS:            &fakeSTS{account: testAccount},
+		CloudWatchLogs: &fakeLogs{},
+		KMS:            &fakeKMS{},
+		Signer:         &fakeSigner{},
+		SSM:            &fakeSSM{},
+		SecretsManager: &fakeSecretsManager{},
+	}
+}
+
+// fakeLambda is an in-memory Lambda API. Functions are stored by their unqualified name,
+// calls are recorded as "<operation> <function>". Errors queued with fail are returned by the next calls.
+type fakeLambda struct {
+	lambdaiface.LambdaAPI
+
+	mutex     sync.Mutex
+	functions map[string]*lambda.FunctionConfiguration
+	zips      map[string][]byte
+	versions  map[string]int
+	aliases   map[string]*lambda.AliasConfiguration
+	reserved  map[string]int64
+	// provisioned holds the provisioned concurrency by <function>:<version>.
+	provisioned map[string]int64
+	tags        map[string]map[string]string
+	calls       []string
+	errs        map[string][]error
+
+	// staged is the bucket code updates from S3 are read from, the code is the S3 path if it is nil.
+	staged *s3Server
+	// codeSha256 replaces the code hash reported by UpdateFunctionCode if it is set.
+	codeSha256 string
+	// codeLocation is the URL returned by GetFunction.
+	codeLocation string
+	// updateStatuses are returned as LastUpdateStatus by the next GetFunctionConfiguration calls.
+	updateStatuses []string
+	// provisionedStatus is the status of provisioned concurrency, defaults to READY.
+	provisionedStatus string
+	// invoke answers Invoke calls, all invocations succeed if it is nil.
+	invoke func(input *lambda.InvokeInput) (*lambda.InvokeOutput, error)
+}
+
+// newFakeLambda creates a fakeLambda with the given functions on the go1.x runtime.
+func newFakeLambda(names ...string) *fakeLambda {
+	client := &fakeLambda{
+		functions:   map[string]*lambda.FunctionConfiguration{},
+		zips:        map[string][]byte{},
+		versions:    map[string]int{},
+		aliases:     map[string]*lambda.AliasConfiguration{},
+		reserved:    map[string]int64{},
+		provisioned: map[string]int64{},
+		tags:        map[string]map[string]string{},
+		errs:        map[string][]error{},
+	}
+	for _, name := range names {
+		client.addFunction(name, lambda.RuntimeGo1X)
+	}
+	return client
+}
+
+// addFunction adds a function with the given runtime and returns its live configuration.
+func (client *fakeLambda) addFunction(name string, runtime string) *lambda.FunctionConfiguration {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	info := &lambda.FunctionConfiguration{
+		FunctionName:     aws.String(name),
+		FunctionArn:      aws.String(fmt.Sprintf("arn:aws:lambda:eu-central-1:%s:function:%s", testAccount, name)),
+		Runtime:          aws.String(runtime),
+		Handler:          aws.String(name),
+		CodeSha256:       aws.String(base64.StdEncoding.EncodeToString([]byte("initial"))),
+		PackageType:      aws.String(lambda.PackageTypeZip),
+		Architectures:    []*string{aws.String(lambda.ArchitectureX8664)},
+		LastUpdateStatus: aws.String(lambda.LastUpdateStatusSuccessful),
+		Version:          aws.String("$LATEST"),
+	}
+	client.functions[name] = info
+	return info
+}
+
+// fail queues errors for the next calls of the given operation.
+func (client *fakeLambda) fail(operation string, errs ...error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.errs[operation] = append(client.errs[operation], errs...)
+}
+
+// call records a call and returns its queued error. The mutex must be held.
+func (client *fakeLambda) call(operation string, name string) error {
+	client.calls = append(client.calls, operation+" "+name)
+	if errs := client.errs[operation]; len(errs) > 0 {
+		client.errs[operation] = errs[1:]
+		return errs[0]
+	}
+	return nil
+}
+
+// count returns the number of calls of the given operation.
+func (client *fakeLambda) count(operation string) int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	count := 0
+	for _, call := range client.calls {
+		if strings.HasPrefix(call, operation+" ") {
+			count++
+		}
+	}
+	return count
+}
+
+// zipEntries returns the entry names of the zip last uploaded to the function.
+func (client *fakeLambda) zipEntries(t *testing.T, name string) []string {
+	t.Helper()
+	client.mutex.Lock()
+	data := client.zips[name]
+	client.mutex.Unlock()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("uploaded zip of %s is invalid: %v", name, err)
+	}
+	entries := make([]string, len(reader.File))
+	for i, file := range reader.File {
+		entries[i] = file.Name
+	}
+	return entries
+}
+
+// zipFile returns the content of the entry of the zip last uploaded to the function.
+func (client *fakeLambda) zipFile(t *testing.T, name string, entry string) []byte {
+	t.Helper()
+	client.mutex.Lock()
+	data := client.zips[name]
+	client.mutex.Unlock()
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("uploaded zip of %s is invalid: %v", name, err)
+	}
+	file, err := reader.Open(entry)
+	if err != nil {
+		t.Fatalf("uploaded zip of %s has no entry %s: %v", name, entry, err)
+	}
+	defer file.Close()
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// mutations returns the recorded calls that change a function.
+func (client *fakeLambda) mutations() []string {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	var mutations []string
+	for _, call := range client.calls {
+		if !strings.HasPrefix(call, "Get") && !strings.HasPrefix(call, "Invoke") {
+			mutations = append(mutations, call)
+		}
+	}
+	return mutations
+}
+
+// function returns a copy of the live configuration of the function.
+func (client *fakeLambda) function(name string) *lambda.FunctionConfiguration {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	info := *client.functions[name]
+	return &info
+}
+
+// lookup returns the function of the given name, qualified name or ARN. The mutex must be held.
+func (client *fakeLambda) lookup(name string) (*lambda.FunctionConfiguration, string, error) {
+	if strings.HasPrefix(name, "arn:") {
+		name = strings.Join(strings.Split(name, ":")[6:], ":")
+	}
+	bare, _, _ := splitFunctionName(name)
+	info, ok := client.functions[bare]
+	if !ok {
+		return nil, bare, notFound("Function not found: " + name)
+	}
+	return info, bare, nil
+}
+
+// notFound returns a ResourceNotFoundException with the given message.
+func notFound(message string) error {
+	return awserr.New(lambda.ErrCodeResourceNotFoundException, message, nil)
+}
+
+// conflict returns a ResourceConflictException, as returned while an update is in progress.
+func conflict() error {
+	return awserr.New(lambda.ErrCodeResourceConflictException, "The operation cannot be performed at this time.", nil)
+}
+
+func (client *fakeLambda) GetFunctionConfigurationWithContext(ctx aws.Context, input *lambda.GetFunctionConfigurationInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.call("GetFunctionConfiguration", aws.StringValue(input.FunctionName)); err != nil {
+		return nil, err
+	}
+	info, _, err := client.lookup(aws.StringValue(input.FunctionName))
+	if err != nil {
+		return nil, err
+	}
+	output := *info
+	if len(client.updateStatuses) > 0 {
+		output.LastUpdateStatus = aws.String(client.updateStatuses[0])
+		client.updateStatuses = client.updateStatuses[1:]
+	}
+	return &output, nil
+}
+
+func (client *fakeLambda) GetFunctionWithContext(ctx aws.Context, input *lambda.GetFunctionInput, opts ...request.Option) (*lambda.GetFunctionOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.call("GetFunction", aws.StringValue(input.FunctionName)); err != nil {
+		return nil, err
+	}
+	info, _, err := client.lookup(aws.StringValue(input.FunctionName))
+	if err != nil {
+		return nil, err
+	}
+	output := *info
+	return &lambda.GetFunctionOutput{
+		Configuration: &output,
+		Code:          &lambda.FunctionCodeLocation{Location: aws.String(client.codeLocation)},
+	}, nil
+}
+
+func (client *fakeLambda) UpdateFunctionCodeWithContext(ctx aws.Context, input *lambda.UpdateFunctionCodeInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.call("UpdateFunctionCode", aws.StringValue(input.FunctionName)); err != nil {
+		return nil, err
+	}
+	info, bare, err := client.lookup(aws.StringValue(input.FunctionName))
+	if err != nil {
+		return nil, err
+	}
+	code, archive := input.ZipFile, input.ZipFile
+	if input.ImageUri != nil {
+		code = []byte(*input.ImageUri)
+	} else if input.S3Key != nil && client.staged != nil {
+		archive, _ = client.staged.object(aws.StringValue(input.S3Bucket) + "/" + *input.S3Key)
+		code = archive
+	} else if input.S3Key != nil {
+		code = []byte("s3://" + aws.StringValue(input.S3Bucket) + "/" + *input.S3Key)
+	}
+	sum := sha256.Sum256(code)
+	info.CodeSha256 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	if client.codeSha256 != "" {
+		info.CodeSha256 = aws.String(client.codeSha256)
+	}
+	if input.Architectures != nil {
+		info.Architectures = input.Architectures
+	}
+	client.zips[bare] = archive
+	output := *info
+	return &output, nil
+}
+
+func (client *fakeLambda) UpdateFunctionConfigurationWithContext(ctx aws.Context, input *lambda.UpdateFunctionConfigurationInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.call("UpdateFunctionConfiguration", aws.StringValue(input.FunctionName)); err != nil {
+		return nil, err
+	}
+	info, _, err := client.lookup(aws.StringValue(input.FunctionName))
+	if err != nil {
+		return nil, err
+	}
+	if input.Handler != nil {
+		info.Handler = input.Handler
+	}
+	if input.MemorySize != nil {
+		info.MemorySize = input.MemorySize
+	}
+	if input.Timeout != nil {
+		info.Timeout = input.Timeout
+	}
+	if input.Environment != nil {
+		info.Environment = &lambda.EnvironmentResponse{Variables: input.Environment.Variables}
+	}
+	if input.Layers != nil {
+		info.Layers = nil
+		for _, arn := range input.Layers {
+			info.Layers = append(info.Layers, &lambda.Layer{Arn: arn})
+		}
+	}
+	if input.LoggingConfig != nil {
+		info.LoggingConfig = input.LoggingConfig
+	}
+	output := *info
+	return &output, nil
+}
+
+func (client *fakeLambda) PublishVersionWithContext(ctx aws.Context, input *lambda.PublishVersionInput, opts ...request.Option) (*lambda.FunctionConfiguration, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.call("PublishVersion", aws.StringValue(input.FunctionName)); err != nil {
+		return nil, err
+	}
+	info, bare, err := client.lookup(aws.StringValue(input.FunctionName))
+	if err != nil {
+		return nil, err
+	}
+	client.versions[bare]++
+	output := *info
+	output.Version = aws.String(strconv.Itoa(client.versions[bare]))
+	output.FunctionArn = aws.String(aws.StringValue(info.FunctionArn) + ":" + *output.Version)
+	return &output, nil
+}
+
+func (client *fakeLambda) GetAliasWithContext(ctx aws.Context, input *lambda.GetAliasInput, opts ...request.Option) (*lambda.AliasConfiguration, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	key := aws.StringValue(input.FunctionName) + ":" + aws.StringValue(input.Name)
+	if err := client.call("GetAlias", key); err != nil {
+		return nil, err
+	}
+	alias, ok := client.aliases[key]
+	if !ok {
+		return nil, notFound("Alias not found: " + key)
+	}
+	output := *alias
+	return &output, nil
+}
+
+func (client *fakeLambda) UpdateAliasWithContext(ctx aws.Context, input *lambda.UpdateAliasInput, opts ...request.Option) (*lambda.AliasConfiguration, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	key := aws.StringValue(input.FunctionName) + ":" + aws.StringValue(input.Name)
+	if err := client.call("UpdateAlias", key); err != nil {
+		return nil, err
+	}
+	if _, ok := client.aliases[key]; !ok {
+		return nil, notFound("Alias not found: " + key)
+	}
+	alias := &lambda.AliasConfiguration{Name: input.Name, FunctionVersion: input.FunctionVersion, RoutingConfig: input.RoutingConfig}
+	client.aliases[key] = alias
+	output := *alias
+	return &output, nil
+}
+
+func (client *fakeLambda) CreateAliasWithContext(ctx aws.Context, input *lambda.CreateAliasInput, opts ...request.Option) (*lambda.AliasConfiguration, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	key := aws.StringValue(input.FunctionName) + ":" + aws.StringValue(input.Name)
+	if err := client.call("CreateAlias", key); err != nil {
+		return nil, err
+	}
+	alias := &lambda.AliasConfiguration{Name: input.Name, FunctionVersion: input.FunctionVersion, RoutingConfig: input.RoutingConfig}
+	client.aliases[key] = alias
+	output := *alias
+	return &output, nil
+}
+
+// alias returns the version the alias points to and its additional version weights.
+func (client *fakeLambda) alias(name string, alias string) (string, map[string]float64) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	config, ok := client.aliases[name+":"+alias]
+	if !ok {
+		return "", nil
+	}
+	weights := map[string]float64{}
+	if config.RoutingConfig != nil {
+		for version, weight := range config.RoutingConfig.AdditionalVersionWeights {
+			weights[version] = aws.Float64Value(weight)
+		}
+	}
+	return aws.StringValue(config.FunctionVersion), weights
+}
+
+// setAlias points the alias to the given version.
+func (client *fakeLambda) setAlias(name string, alias string, version string) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.aliases[name+":"+alias] = &lambda.AliasConfiguration{Name: aws.String(alias), FunctionVersion: aws.String(version)}
+	if number, err := strconv.Atoi(version); err == nil && number > client.versions[name] {
+		client.versions[name] = number
+	}
+}
+
+func (client *fakeLambda) PutFunctionConcurrencyWithContext(ctx aws.Context, input *lambda.PutFunctionConcurrencyInput, opts ...request.Option) (*lambda.PutFunctionConcurrencyOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.call("PutFunctionConcurrency", aws.StringValue(input.FunctionName)); err != nil {
+		return nil, err
+	}
+	client.reserved[aws.StringValue(input.FunctionName)] = aws.Int64Value(input.ReservedConcurrentExecutions)
+	return &lambda.PutFunctionConcurrencyOutput{ReservedConcurrentExecutions: input.ReservedConcurrentExecutions}, nil
+}
+
+func (client *fakeLambda) DeleteFunctionConcurrencyWithContext(ctx aws.Context, input *lambda.DeleteFunctionConcurrencyInput, opts ...request.Option) (*lambda.DeleteFunctionConcurrencyOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if err := client.call("DeleteFunctionConcurrency", aws.StringValue(input.FunctionName)); err != nil {
+		return nil, err
+	}
+	delete(client.reserved, aws.StringValue(input.FunctionName))
+	return &lambda.DeleteFunctionConcurrencyOutput{}, nil
+}
+
+func (client *fakeLambda) PutProvisionedConcurrencyConfigWithContext(ctx aws.Context, input *lambda.PutProvisionedConcurrencyConfigInput, opts ...request.Option) (*lambda.PutProvisionedConcurrencyConfigOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	key := aws.StringValue(input.FunctionName) + ":" + aws.StringValue(input.Qualifier)
+	if err := client.call("PutProvisionedConcurrencyConfig", key); err != nil {
+		return nil, err
+	}
+	client.provisioned[key] = aws.Int64Value(input.ProvisionedConcurrentExecutions)
+	return &lambda.PutProvisionedConcurrencyConfigOutput{}, nil
+}
+
+func (client *fakeLambda) GetProvisionedConcurrencyConfigWithContext(ctx aws.Context, input *lambda.GetProvisionedConcurrencyConfigInput, opts ...request.Option) (*lambda.GetProvisionedConcurrencyConfigOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	key := aws.StringValue(input.FunctionName) + ":" + aws.StringValue(input.Qualifier)
+	if err := client.call("GetProvisionedConcurrencyConfig", key); err != nil {
+		return nil, err
+	}
+	if _, ok := client.provisioned[key]; !ok {
+		return nil, awserr.New(lambda.ErrCodeProvisionedConcurrencyConfigNotFoundException, "No Provisioned Concurrency Config found", nil)
+	}
+	status := client.provisionedStatus
+	if status == "" {
+		status = lambda.ProvisionedConcurrencyStatusEnumReady
+	}
+	return &lambda.GetProvisionedConcurrencyConfigOutput{Status: aws.String(status), StatusReason: aws.String("test failure")}, nil
+}
+
+func (client *fakeLambda) DeleteProvisionedConcurrencyConfigWithContext(ctx aws.Context, input *lambda.DeleteProvisionedConcurrencyConfigInput, opts ...request.Option) (*lambda.DeleteProvisionedConcurrencyConfigOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	key := aws.StringValue(input.FunctionName) + ":" + aws.StringValue(input.Qualifier)
+	if err := client.call("DeleteProvisionedConcurrencyConfig", key); err != nil {
+		return nil, err
+	}
+	if _, ok := client.provisioned[key]; !ok {
+		return nil, awserr.New(lambda.ErrCodeProvisionedConcurrencyConfigNotFoundException, "No Provisioned Concurrency Config found", nil)
+	}
+	delete(client.provisioned, key)
+	return &lambda.DeleteProvisionedConcurrencyConfigOutput{}, nil
+}
+
+// provisionedVersions returns the sorted <function>:<version> keys with provisioned concurrency.
+func (client *fakeLambda) provisionedVersions() []string {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	var keys []string
+	for key := range client.provisioned {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (client *fakeLambda) InvokeWithContext(ctx aws.Context, input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	client.mutex.Lock()
+	err := client.call("Invoke", aws.StringValue(input.FunctionName)+":"+aws.StringValue(input.Qualifier))
+	invoke := client.invoke
+	client.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if invoke != nil {
+		return invoke(input)
+	}
+	return &lambda.InvokeOutput{StatusCode: aws.Int64(200), Payload: []byte("null")}, nil
+}
+
+func (client *fakeLambda) TagResourceWithContext(ctx aws.Context, input *lambda.TagResourceInput, opts ...request.Option) (*lambda.TagResourceOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	arn := aws.StringValue(input.Resource)
+	if err := client.call("TagResource", arn); err != nil {
+		return nil, err
+	}
+	if client.tags[arn] == nil {
+		client.tags[arn] = map[string]string{}
+	}
+	for key, value := range input.Tags {
+		client.tags[arn][key] = aws.StringValue(value)
+	}
+	return &lambda.TagResourceOutput{}, nil
+}
+
+// fakeSTS returns account as the account of the credentials.
+type fakeSTS struct {
+	stsiface.STSAPI
+	account string
+}
+
+func (client *fakeSTS) GetCallerIdentityWithContext(ctx aws.Context, input *sts.GetCallerIdentityInput, opts ...request.Option) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String(client.account)}, nil
+}
+
+// fakeLogs records the created log groups and their retention.
+type fakeLogs struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+	mutex     sync.Mutex
+	retention map[string]int64
+}
+
+func (client *fakeLogs) CreateLogGroupWithContext(ctx aws.Context, input *cloudwatchlogs.CreateLogGroupInput, opts ...request.Option) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.retention == nil {
+		client.retention = map[string]int64{}
+	}
+	if _, ok := client.retention[aws.StringValue(input.LogGroupName)]; ok {
+		return nil, awserr.New(cloudwatchlogs.ErrCodeResourceAlreadyExistsException, "The specified log group already exists", nil)
+	}
+	client.retention[aws.StringValue(input.LogGroupName)] = 0
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (client *fakeLogs) PutRetentionPolicyWithContext(ctx aws.Context, input *cloudwatchlogs.PutRetentionPolicyInput, opts ...request.Option) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.retention[aws.StringValue(input.LogGroupName)] = aws.Int64Value(input.RetentionInDays)
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+// fakeKMS decrypts ciphertexts of the form encrypted:<plaintext>.
+type fakeKMS struct {
+	kmsiface.KMSAPI
+}
+
+func (client *fakeKMS) DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	plaintext := strings.TrimPrefix(string(input.CiphertextBlob), "encrypted:")
+	if plaintext == string(input.CiphertextBlob) {
+		return nil, awserr.New(kms.ErrCodeInvalidCiphertextException, "invalid ciphertext", nil)
+	}
+	return &kms.DecryptOutput{Plaintext: []byte(plaintext)}, nil
+}
+
+// fakeSigner signs every object to the destination prefix followed by the source key, unless status is set.
+type fakeSigner struct {
+	signeriface.SignerAPI
+	mutex  sync.Mutex
+	jobs   map[string]*signer.StartSigningJobInput
+	status string
+	// bucket receives the signed objects if it is set, a signed object is the source object followed by signedSuffix.
+	bucket *s3Server
+}
+
+// signedSuffix is appended to the objects signed by a fakeSigner.
+const signedSuffix = "signed"
+
+func (client *fakeSigner) StartSigningJobWithContext(ctx aws.Context, input *signer.StartSigningJobInput, opts ...request.Option) (*signer.StartSigningJobOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.jobs == nil {
+		client.jobs = map[string]*signer.StartSigningJobInput{}
+	}
+	id := fmt.Sprintf("job-%d", len(client.jobs)+1)
+	client.jobs[id] = input
+	if client.bucket != nil && client.status == "" {
+		bucket := aws.StringValue(input.Source.S3.BucketName)
+		source, _ := client.bucket.object(bucket + "/" + aws.StringValue(input.Source.S3.Key))
+		client.bucket.mutex.Lock()
+		client.bucket.objects[bucket+"/"+aws.StringValue(input.Destination.S3.Prefix)+aws.StringValue(input.Source.S3.Key)] = append(source, signedSuffix...)
+		client.bucket.mutex.Unlock()
+	}
+	return &signer.StartSigningJobOutput{JobId: aws.String(id)}, nil
+}
+
+func (client *fakeSigner) WaitUntilSuccessfulSigningJobWithContext(ctx aws.Context, input *signer.DescribeSigningJobInput, opts ...request.WaiterOption) error {
+	if client.status != "" {
+		return awserr.New(request.WaiterResourceNotReadyErrorCode, "failed waiting for successful resource state", nil)
+	}
+	return nil
+}
+
+func (client *fakeSigner) DescribeSigningJobWithContext(ctx aws.Context, input *signer.DescribeSigningJobInput, opts ...request.Option) (*signer.DescribeSigningJobOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	job := client.jobs[aws.StringValue(input.JobId)]
+	if client.status != "" {
+		return &signer.DescribeSigningJobOutput{Status: aws.String(client.status), StatusReason: aws.String("test failure")}, nil
+	}
+	key := aws.StringValue(job.Destination.S3.Prefix) + aws.StringValue(job.Source.S3.Key)
+	return &signer.DescribeSigningJobOutput{
+		Status:       aws.String(signer.SigningStatusSucceeded),
+		SignedObject: &signer.SignedObject{S3: &signer.S3SignedObject{BucketName: job.Destination.S3.BucketName, Key: &key}},
+	}, nil
+}
+
+// fakeSSM returns the parameters of its map and counts the calls.
+type fakeSSM struct {
+	ssmiface.SSMAPI
+	mutex      sync.Mutex
+	parameters map[string]string
+	calls      int
+}
+
+func (client *fakeSSM) GetParameterWithContext(ctx aws.Context, input *ssm.GetParameterInput, opts ...request.Option) (*ssm.GetParameterOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.calls++
+	value, ok := client.parameters[aws.StringValue(input.Name)]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "parameter not found", nil)
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Name: input.Name, Value: aws.String(value)}}, nil
+}
+
+// fakeSecretsManager returns the secrets of its map and counts the calls.
+type fakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	mutex   sync.Mutex
+	secrets map[string]string
+	calls   int
+}
+
+func (client *fakeSecretsManager) GetSecretValueWithContext(ctx aws.Context, input *secretsmanager.GetSecretValueInput, opts ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.calls++
+	value, ok := client.secrets[aws.StringValue(input.SecretId)]
+	if !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "secret not found", nil)
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(value)}, nil
+}
+
+// fakeS3 is an S3 client that must not be called, tests that upload use newS3Server.
+type fakeS3 struct {
+	s3iface.S3API
+}
+
+// s3Server is an in-memory S3 bucket behind a httptest server, supporting single and multipart uploads.
+type s3Server struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header
+	parts   map[string]map[int][]byte
+	// versioned reports a version ID for every upload.
+	versioned bool
+	// deny rejects all uploads with AccessDenied.
+	deny bool
+}
+
+// newS3Server starts a s3Server and returns it with a client using path-style requests against it.
+func newS3Server(t *testing.T) (*s3Server, *s3.S3) {
+	t.Helper()
+	server := &s3Server{objects: map[string][]byte{}, headers: map[string]http.Header{}, parts: map[string]map[int][]byte{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serve))
+	t.Cleanup(server.Close)
+	sess, err := session.NewSession(&aws.Config{
+		Region:           aws.String("eu-central-1"),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("test", "test", ""),
+		MaxRetries:       aws.Int(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, s3.New(sess)
+}
+
+// object returns the stored object at bucket/key.
+func (server *s3Server) object(path string) ([]byte, http.Header) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.objects[path], server.headers[path]
+}
+
+// serve handles the S3 requests of the uploader.
+func (server *s3Server) serve(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	query := r.URL.Query()
+	if server.deny {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if server.versioned {
+		w.Header().Set("x-amz-version-id", fmt.Sprintf("v%d", len(server.objects)+1))
+	}
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := fmt.Sprintf("upload-%d", len(server.parts)+1)
+		server.parts[id] = map[int][]byte{}
+		server.headers[path] = r.Header.Clone()
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: strings.Split(path, "/")[0], Key: path, UploadId: id})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		server.parts[query.Get("uploadId")][number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := server.parts[query.Get("uploadId")]
+		var object []byte
+		for number := 1; number <= len(parts); number++ {
+			object = append(object, parts[number]...)
+		}
+		server.objects[path] = object
+		writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Key     string
+			ETag    string
+		}{Key: path, ETag: `"etag"`})
+	case r.Method == http.MethodPut:
+		server.objects[path] = body
+		server.headers[path] = r.Header.Clone()
+		w.Header().Set("ETag", `"etag"`)
+	case r.Method == http.MethodGet:
+		object, ok := server.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		w.Write(object)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// writeXML writes value as XML response.
+func writeXML(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(value)
+}
+
+// deployOne deploys a single function with the given options and fails the test on errors.
+func deployOne(t *testing.T, conf *FunctionConfig, opts Options) Result {
+	t.Helper()
+	results, err := Deploy(context.Background(), []*FunctionConfig{conf}, opts)
+	if err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	return results[0]
+}
@@ -0,0 +1,91 @@
+package deploy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// filePrefix marks config values that are read from a file at parse time.
+const filePrefix = "file:"
+
+// resolveFileRefs replaces every string value of the config of the form file:<path> with the content of the file.
+// Relative paths are resolved against the function directory, a trailing newline is removed.
+// This covers all string fields, string lists and the values of string maps, including nested ones.
+func (conf *FunctionConfig) resolveFileRefs() error {
+	if err := resolveFileRefsIn(reflect.ValueOf(conf).Elem(), conf.Path, ""); err != nil {
+		return fmt.Errorf("error while resolving file references for config at %s: %w", conf.Path, err)
+	}
+	return nil
+}
+
+// resolveFileRefsIn resolves the file references in value, fieldPath is the path of value used in errors.
+func resolveFileRefsIn(value reflect.Value, dir string, fieldPath string) error {
+	switch value.Kind() {
+	case reflect.String:
+		resolved, err := readFileRef(value.String(), dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fieldPath, err)
+		}
+		value.SetString(resolved)
+	case reflect.Ptr:
+		if !value.IsNil() {
+			return resolveFileRefsIn(value.Elem(), dir, fieldPath)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if field.PkgPath != "" || name == "-" || name == "" {
+				continue
+			}
+			if err := resolveFileRefsIn(value.Field(i), dir, joinFieldPath(fieldPath, name)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if err := resolveFileRefsIn(value.Index(i), dir, fmt.Sprintf("%s[%d]", fieldPath, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if value.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range value.MapKeys() {
+			resolved, err := readFileRef(value.MapIndex(key).String(), dir)
+			if err != nil {
+				return fmt.Errorf("%s: %w", joinFieldPath(fieldPath, key.String()), err)
+			}
+			value.SetMapIndex(key, reflect.ValueOf(resolved).Convert(value.Type().Elem()))
+		}
+	}
+	return nil
+}
+
+// readFileRef returns the content of the referenced file if s is a file reference, s otherwise.
+func readFileRef(s string, dir string) (string, error) {
+	if !strings.HasPrefix(s, filePrefix) {
+		return s, nil
+	}
+	path := strings.TrimPrefix(s, filePrefix)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error while reading %s: %w", s, err)
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// joinFieldPath appends name to the dotted field path.
+func joinFieldPath(fieldPath string, name string) string {
+	if fieldPath == "" {
+		return name
+	}
+	return fieldPath + "." + name
+}
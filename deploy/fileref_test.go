@@ -0,0 +1,42 @@
+package deploy
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseResolvesFileRefs(t *testing.T) {
+	shared := filepath.Join(t.TempDir(), "log-group")
+	writeFiles(t, filepath.Dir(shared), "log-group", "/shared/logs\n")
+	config := "name: hello\nfileName: main.go\n" +
+		"environment:\n  TOKEN: file:secrets/token\n  STAGE: prod\n" +
+		"layers:\n  - file:layer-arn\n" +
+		"loggingConfig:\n  logGroup: file:" + shared + "\n"
+
+	conf := newTestFunction(t, config,
+		"secrets/token", "s3cr3t\n",
+		"layer-arn", "arn:aws:lambda:eu-central-1:123456789012:layer:shared:3")
+
+	if !reflect.DeepEqual(conf.Environment, map[string]string{"TOKEN": "s3cr3t", "STAGE": "prod"}) {
+		t.Errorf("expected the token to be read from its file, got %v", conf.Environment)
+	}
+	if !reflect.DeepEqual(conf.Layers, []string{"arn:aws:lambda:eu-central-1:123456789012:layer:shared:3"}) {
+		t.Errorf("expected the layer to be read from its file, got %v", conf.Layers)
+	}
+	if conf.LoggingConfig.LogGroup != "/shared/logs" {
+		t.Errorf("expected the absolute reference to be read, got %s", conf.LoggingConfig.LogGroup)
+	}
+}
+
+func TestParseReportsMissingFileRef(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "main.go", testMain)
+
+	_, err := ParseFunctionConfigFromReader(strings.NewReader("name: hello\nfileName: main.go\nenvironment:\n  TOKEN: file:missing\n"), dir)
+
+	if err == nil || !strings.Contains(err.Error(), "environment.TOKEN: error while reading file:missing") {
+		t.Errorf("expected the missing file to be reported with its field, got %v", err)
+	}
+}
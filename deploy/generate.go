@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// moduleGenerator runs go generate once per module for the functions with GoGenerate.
+// Functions of the same module share the generated code, so parallel builds must not generate it at the same time.
+type moduleGenerator struct {
+	mutex   sync.Mutex
+	modules map[string]*generation
+}
+
+// generation is the go generate run of a single module.
+type generation struct {
+	once sync.Once
+	err  error
+}
+
+// newModuleGenerator returns a moduleGenerator that didn't run for any module yet.
+func newModuleGenerator() *moduleGenerator {
+	return &moduleGenerator{modules: map[string]*generation{}}
+}
+
+// generate runs go generate ./... in the root of the module the function is built in, unless it already ran for this module.
+// Concurrent calls for the same module wait until the first run is done and return its error.
+func (g *moduleGenerator) generate(ctx context.Context, conf *FunctionConfig) error {
+	gomod, err := conf.goEnv(ctx, "GOMOD")
+	if err != nil {
+		return err
+	}
+	if gomod == "" || gomod == os.DevNull {
+		return errors.New("goGenerate requires the function to be part of a module")
+	}
+
+	g.mutex.Lock()
+	run, ok := g.modules[gomod]
+	if !ok {
+		run = &generation{}
+		g.modules[gomod] = run
+	}
+	g.mutex.Unlock()
+
+	run.once.Do(func() {
+		run.err = runGoGenerate(ctx, filepath.Dir(gomod))
+	})
+	return run.err
+}
+
+// runGoGenerate runs go generate ./... in dir.
+// The generators run on the host, so the environment isn't changed to the build target of the function.
+// The combined output is logged and included in the error if the command fails.
+func runGoGenerate(ctx context.Context, dir string) error {
+	cmd := exec.CommandContext(ctx, "go", "generate", "./...")
+	cmd.Dir = dir
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		logrus.Debugf("output of go generate in %s:\n%s", dir, output)
+	}
+	if err != nil {
+		return fmt.Errorf("go generate failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	logrus.Infof("ran go generate in %s", dir)
+	return nil
+}
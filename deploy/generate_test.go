@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newGeneratedFunctions writes a module whose package gen is created by go generate with a function per name importing it.
+// generator is the script the go:generate directive of package gen runs.
+func newGeneratedFunctions(t *testing.T, generator string, names ...string) []*FunctionConfig {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"go.mod", "module example.com/app\n\ngo 1.19\n",
+		"gen/doc.go", "package gen\n\n//go:generate sh gen.sh\n",
+		"gen/gen.sh", generator)
+	var configs []*FunctionConfig
+	for _, name := range names {
+		writeFiles(t, dir, filepath.Join(name, "main.go"), "package main\n\nimport \"example.com/app/gen\"\n\nfunc main() { println(gen.Marker) }\n")
+		conf, err := ParseFunctionConfigFromReader(strings.NewReader("name: "+name+"\nfileName: main.go\ngoGenerate: true\n"), filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("error while parsing config: %v", err)
+		}
+		configs = append(configs, conf)
+	}
+	return configs
+}
+
+// markerGenerator writes the gen package with the Marker constant.
+const markerGenerator = "printf 'package gen\\n\\nconst Marker = \"generated-marker\"\\n' > gen.go\n"
+
+func TestDeployRunsGoGenerateBeforeBuild(t *testing.T) {
+	client := newFakeLambda("hello")
+	configs := newGeneratedFunctions(t, markerGenerator, "hello")
+
+	deployOne(t, configs[0], testOptions(client))
+
+	if binary := client.zipFile(t, "hello", "hello"); !bytes.Contains(binary, []byte("generated-marker")) {
+		t.Error("expected the binary to be built with the generated code")
+	}
+}
+
+func TestDeployRunsGoGenerateOncePerModule(t *testing.T) {
+	logs := captureLogs(t)
+	names := []string{"alpha", "beta", "gamma"}
+	client := newFakeLambda(names...)
+	opts := testOptions(client)
+	opts.Concurrency = len(names)
+
+	if _, err := Deploy(context.Background(), newGeneratedFunctions(t, markerGenerator, names...), opts); err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+
+	if runs := strings.Count(logs.String(), "ran go generate in"); runs != 1 {
+		t.Errorf("expected go generate to run once, got %d runs", runs)
+	}
+}
+
+func TestDeployFailsIfGoGenerateFails(t *testing.T) {
+	client := newFakeLambda("hello")
+	configs := newGeneratedFunctions(t, "echo 'generator broke' >&2\nexit 3\n", "hello")
+
+	_, err := Deploy(context.Background(), configs, testOptions(client))
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) || !strings.Contains(err.Error(), "go generate failed") || !strings.Contains(err.Error(), "generator broke") {
+		t.Errorf("expected a BuildError with the output of go generate, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestGoGenerateRequiresModule(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\ngoGenerate: true\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "goGenerate requires the function to be part of a module") {
+		t.Errorf("expected go generate to require a module, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotGitRepository is returned by ChangedFiles if the directory is not inside a git repository.
+var ErrNotGitRepository = errors.New("not a git repository")
+
+// ChangedFiles returns the absolute paths of all files that changed since the given git ref.
+// dir must be inside the git repository.
+func ChangedFiles(dir string, ref string) ([]string, error) {
+	root, err := runGit(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, ErrNotGitRepository
+	}
+	root = strings.TrimSpace(root)
+
+	output, err := runGit(dir, "diff", "--name-only", ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, filepath.Join(root, line))
+		}
+	}
+	return files, nil
+}
+
+// SelectChanged returns the configs whose directory contains at least one of the given files.
+func SelectChanged(configs []*FunctionConfig, files []string) []*FunctionConfig {
+	var selected []*FunctionConfig
+	for _, config := range configs {
+		for _, file := range files {
+			if strings.HasPrefix(file, config.Path+string(filepath.Separator)) {
+				selected = append(selected, config)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// CurrentBranch returns the name of the git branch checked out in dir, HEAD if it is detached.
+func CurrentBranch(dir string) (string, error) {
+	branch, err := runGit(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(branch), nil
+}
+
+// getBranchGuard returns the branch the function may only be deployed from.
+// The BranchGuard of the config replaces the one of the options.
+func (d *deployer) getBranchGuard(conf *FunctionConfig) string {
+	if conf.BranchGuard != "" {
+		return conf.BranchGuard
+	}
+	return d.opts.BranchGuard
+}
+
+// checkBranch returns an error if the config is not on the branch of its branch guard.
+// Nothing is checked if there is no branch guard.
+func (d *deployer) checkBranch(conf *FunctionConfig) error {
+	guard := d.getBranchGuard(conf)
+	if guard == "" {
+		return nil
+	}
+
+	branch, err := CurrentBranch(conf.Path)
+	if err != nil {
+		return fmt.Errorf("error while resolving the git branch: %w", err)
+	}
+	if branch != guard {
+		return fmt.Errorf("REFUSING TO DEPLOY lambda function %s: the checked out branch is %s, deploys are only allowed from %s",
+			conf.Name, branch, guard)
+	}
+	return nil
+}
+
+// runGit runs git with the given arguments in dir and returns its output.
+func runGit(dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", errors.New(strings.TrimSpace(stderr.String()))
+	}
+	return string(output), nil
+}
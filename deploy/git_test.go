@@ -0,0 +1,123 @@
+package deploy
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newGitRepository creates a git repository with the given files committed on branch main.
+func newGitRepository(t *testing.T, files ...string) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, dir, files...)
+	git(t, dir, "init", "--quiet", "--initial-branch=main")
+	git(t, dir, "add", "-A")
+	git(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial")
+	return dir
+}
+
+// git runs git in dir and fails the test if it fails.
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, output)
+	}
+}
+
+func TestChangedFilesSelectsChangedFunctions(t *testing.T) {
+	dir := newGitRepository(t, "hello/main.go", testMain, "world/main.go", testMain)
+	writeFiles(t, dir, "hello/main.go", testMain+"\n// changed\n")
+	hello := &FunctionConfig{Name: "hello", Path: filepath.Join(dir, "hello")}
+	world := &FunctionConfig{Name: "world", Path: filepath.Join(dir, "world")}
+
+	files, err := ChangedFiles(filepath.Join(dir, "world"), "HEAD")
+	if err != nil {
+		t.Fatalf("error while listing changed files: %v", err)
+	}
+	if len(files) != 1 || files[0] != filepath.Join(dir, "hello", "main.go") {
+		t.Fatalf("expected only hello/main.go to be changed, got %v", files)
+	}
+	if selected := SelectChanged([]*FunctionConfig{hello, world}, files); len(selected) != 1 || selected[0] != hello {
+		t.Errorf("expected only hello to be selected, got %v", selected)
+	}
+}
+
+func TestSelectChangedDoesNotMatchSiblingPrefix(t *testing.T) {
+	hello := &FunctionConfig{Name: "hello", Path: "/repo/hello"}
+
+	if selected := SelectChanged([]*FunctionConfig{hello}, []string{"/repo/hello-world/main.go"}); len(selected) != 0 {
+		t.Errorf("expected no function to be selected, got %v", selected)
+	}
+}
+
+func TestChangedFilesOutsideRepository(t *testing.T) {
+	if _, err := ChangedFiles(t.TempDir(), "HEAD"); err != ErrNotGitRepository {
+		t.Errorf("expected ErrNotGitRepository, got %v", err)
+	}
+}
+
+// newGitFunction parses the config of a function committed to a new git repository on branch main.
+func newGitFunction(t *testing.T, config string) *FunctionConfig {
+	t.Helper()
+	dir := newGitRepository(t, "main.go", testMain)
+	conf, err := ParseFunctionConfigFromReader(strings.NewReader(config), dir)
+	if err != nil {
+		t.Fatalf("error while parsing config: %v", err)
+	}
+	return conf
+}
+
+func TestCurrentBranch(t *testing.T) {
+	dir := newGitRepository(t, "main.go", testMain)
+	git(t, dir, "checkout", "--quiet", "-b", "feature/login")
+
+	if branch, err := CurrentBranch(dir); err != nil || branch != "feature/login" {
+		t.Errorf("expected branch feature/login, got %s, %v", branch, err)
+	}
+}
+
+func TestDeployRefusesMismatchedBranch(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newGitFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.BranchGuard = "release"
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, opts)
+
+	if err == nil || !strings.Contains(err.Error(), "REFUSING TO DEPLOY lambda function hello: the checked out branch is main, deploys are only allowed from release") {
+		t.Errorf("expected the branch to be refused, got %v", err)
+	}
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected no changes, got %v", mutations)
+	}
+}
+
+func TestConfigBranchGuardReplacesOptions(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newGitFunction(t, "name: hello\nfileName: main.go\nbranchGuard: main\n")
+	opts := testOptions(client)
+	opts.BranchGuard = "release"
+
+	if result := deployOne(t, conf, opts); result.Action != ActionUpdated {
+		t.Errorf("expected hello to be updated, got %s", result.Action)
+	}
+}
+
+func TestBranchGuardOutsideRepository(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nbranchGuard: main\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "error while resolving the git branch") {
+		t.Errorf("expected the branch guard to fail without a repository, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	defaultHealthCheckAttempts = 3
+	defaultHealthCheckInterval = 2 * time.Second
+	defaultHealthCheckPayload  = "{}"
+)
+
+// HealthCheck describes the invocation used to check a freshly deployed function.
+// The first invocation often hits a slow cold start, so failed invocations are retried with a doubling interval.
+type HealthCheck struct {
+	// Payload is the JSON event the function is invoked with, defaults to {}.
+	Payload string `yaml:"payload"`
+	// Attempts is the number of invocations before the check fails, defaults to 3.
+	Attempts int `yaml:"attempts"`
+	// Interval is the wait before the first retry, it doubles after every attempt. Defaults to 2s.
+	Interval time.Duration `yaml:"interval"`
+	// StabilizeDelay is waited before the first invocation, so it doesn't hit an instance of the previous code.
+	// Afterwards the checked version is polled until its last update is done. Defaults to no delay.
+	StabilizeDelay time.Duration `yaml:"stabilizeDelay"`
+}
+
+// validate checks the bounds of the HealthCheck.
+func (check *HealthCheck) validate() error {
+	if check.Attempts < 0 {
+		return errors.New("healthCheck attempts must not be negative")
+	}
+	if check.Interval < 0 {
+		return errors.New("healthCheck interval must not be negative")
+	}
+	if check.StabilizeDelay < 0 {
+		return errors.New("healthCheck stabilizeDelay must not be negative")
+	}
+	return nil
+}
+
+// checkHealth invokes the given qualifier of the function until an invocation succeeds or all attempts failed.
+// An invocation fails if the API call fails or the function returns an error.
+func (conf *FunctionConfig) checkHealth(ctx context.Context, client lambdaiface.LambdaAPI, qualifier string) error {
+	check := conf.HealthCheck
+	attempts := check.Attempts
+	if attempts == 0 {
+		attempts = defaultHealthCheckAttempts
+	}
+	interval := check.Interval
+	if interval == 0 {
+		interval = defaultHealthCheckInterval
+	}
+	payload := check.Payload
+	if payload == "" {
+		payload = defaultHealthCheckPayload
+	}
+
+	if check.StabilizeDelay > 0 {
+		if err := conf.stabilize(ctx, client, qualifier); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = invokeHealthCheck(ctx, client, conf.getUnqualifiedName(), qualifier, payload)
+		if err == nil {
+			logrus.Infof("health check of version %s of lambda function %s passed", qualifier, conf.Name)
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		logrus.Infof("health check attempt %d of %d for lambda function %s failed, retrying in %s: %v", attempt, attempts, conf.Name, interval, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+	return fmt.Errorf("health check of version %s failed after %d attempts: %w", qualifier, attempts, err)
+}
+
+// stabilize waits the StabilizeDelay of the health check and then until the last update of the qualifier is done.
+func (conf *FunctionConfig) stabilize(ctx context.Context, client lambdaiface.LambdaAPI, qualifier string) error {
+	logrus.Infof("waiting %s for version %s of lambda function %s to stabilize", conf.HealthCheck.StabilizeDelay, qualifier, conf.Name)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(conf.HealthCheck.StabilizeDelay):
+	}
+	_, err := waitForUpdate(ctx, client, conf.getUnqualifiedName()+":"+qualifier)
+	return err
+}
+
+// invokeHealthCheck invokes the function once and returns an error if the invocation or the function failed.
+func invokeHealthCheck(ctx context.Context, client lambdaiface.LambdaAPI, name string, qualifier string, payload string) error {
+	output, err := client.InvokeWithContext(ctx, &lambda.InvokeInput{
+		FunctionName: &name,
+		Qualifier:    &qualifier,
+		Payload:      []byte(payload),
+	})
+	if err != nil {
+		return err
+	}
+	if output.FunctionError != nil {
+		return fmt.Errorf("function returned %s error: %s", aws.StringValue(output.FunctionError), bytes.TrimSpace(output.Payload))
+	}
+	return nil
+}
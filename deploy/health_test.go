@@ -0,0 +1,125 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingInvocations returns an invoke function of fakeLambda whose first failures invocations return a function error.
+func failingInvocations(failures int32, qualifiers *[]string) func(input *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+	var invocations int32
+	return func(input *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+		*qualifiers = append(*qualifiers, aws.StringValue(input.Qualifier))
+		if atomic.AddInt32(&invocations, 1) <= failures {
+			return &lambda.InvokeOutput{FunctionError: aws.String("Unhandled"), Payload: []byte(`{"errorMessage":"cold"}` + "\n")}, nil
+		}
+		return &lambda.InvokeOutput{StatusCode: aws.Int64(200)}, nil
+	}
+}
+
+func TestHealthCheckRetriesBeforeShiftingAlias(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.setAlias("hello", "live", "7")
+	var qualifiers []string
+	client.invoke = failingInvocations(2, &qualifiers)
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\nhealthCheck:\n  payload: '{\"ping\": true}'\n  interval: 1ms\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if len(qualifiers) != 3 || qualifiers[2] != "8" {
+		t.Errorf("expected 3 invocations of version 8, got %v", qualifiers)
+	}
+	if version, _ := client.alias("hello", "live"); version != "8" {
+		t.Errorf("expected the alias to point to version 8, got %s", version)
+	}
+}
+
+func TestHealthCheckFailureKeepsAlias(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.setAlias("hello", "live", "7")
+	var qualifiers []string
+	client.invoke = failingInvocations(2, &qualifiers)
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\nhealthCheck:\n  attempts: 2\n  interval: 1ms\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	expected := `health check of version 8 failed after 2 attempts: function returned Unhandled error: {"errorMessage":"cold"}, alias live still points to the previous version`
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+	if version, _ := client.alias("hello", "live"); version != "7" {
+		t.Errorf("expected the alias to stay at version 7, got %s", version)
+	}
+}
+
+func TestHealthCheckInvokesLatestWithoutPublishing(t *testing.T) {
+	client := newFakeLambda("hello")
+	var qualifiers []string
+	client.invoke = failingInvocations(0, &qualifiers)
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nhealthCheck: {}\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if len(qualifiers) != 1 || qualifiers[0] != "$LATEST" {
+		t.Errorf("expected a single invocation of $LATEST, got %v", qualifiers)
+	}
+}
+
+func TestHealthCheckValidateRejectsNegativeValues(t *testing.T) {
+	tests := []struct {
+		check   HealthCheck
+		message string
+	}{
+		{HealthCheck{Attempts: -1}, "healthCheck attempts must not be negative"},
+		{HealthCheck{Interval: -time.Second}, "healthCheck interval must not be negative"},
+		{HealthCheck{StabilizeDelay: -time.Second}, "healthCheck stabilizeDelay must not be negative"},
+	}
+	for _, test := range tests {
+		if err := test.check.validate(); err == nil || err.Error() != test.message {
+			t.Errorf("expected %q, got %v", test.message, err)
+		}
+	}
+}
+
+func TestHealthCheckWaitsForVersionToStabilize(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.setAlias("hello", "live", "7")
+	var qualifiers []string
+	client.invoke = failingInvocations(0, &qualifiers)
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\nhealthCheck:\n  stabilizeDelay: 20ms\n")
+	start := time.Now()
+
+	deployOne(t, conf, testOptions(client))
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected the deploy to wait the stabilize delay, took %s", elapsed)
+	}
+	polled, invoked := -1, -1
+	for i, call := range client.calls {
+		switch call {
+		case "GetFunctionConfiguration hello:8":
+			polled = i
+		case "Invoke hello:8":
+			invoked = i
+		}
+	}
+	if polled < 0 || invoked < polled {
+		t.Errorf("expected version 8 to be polled before it is invoked, got calls %v", client.calls)
+	}
+}
+
+func TestStabilizeStopsWhenCanceled(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := &FunctionConfig{Name: "hello", HealthCheck: &HealthCheck{StabilizeDelay: time.Hour}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := conf.stabilize(ctx, client, "8"); err != context.Canceled {
+		t.Errorf("expected the cancellation error, got %v", err)
+	}
+}
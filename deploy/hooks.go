@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// artifactEnv is the environment variable holding the deploy artifact for the preDeploy hook.
+const artifactEnv = "LAMBDA_CI_ARTIFACT"
+
+// runHook runs the given hook command in the directory of the function.
+// The combined output is logged and included in the error if the command fails.
+func (conf *FunctionConfig) runHook(ctx context.Context, hook string, command []string, env ...string) error {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Dir = conf.Path
+	cmd.Env = append(os.Environ(), env...)
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		logrus.Debugf("output of %s hook for lambda function %s:\n%s", hook, conf.Name, output)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook %q failed: %w: %s", hook, strings.Join(command, " "), err, strings.TrimSpace(string(output)))
+	}
+	logrus.Infof("ran %s hook for lambda function %s", hook, conf.Name)
+
+	return nil
+}
+
+// runGuard runs the DeployIf command in the directory of the function.
+// Reports whether the command exited with zero. Errors are only returned if the command could not be run.
+func (conf *FunctionConfig) runGuard(ctx context.Context) (bool, error) {
+	cmd := exec.CommandContext(ctx, conf.DeployIf[0], conf.DeployIf[1:]...)
+	cmd.Dir = conf.Path
+
+	output, err := cmd.CombinedOutput()
+	if len(output) > 0 {
+		logrus.Debugf("output of deployIf guard for lambda function %s:\n%s", conf.Name, output)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,103 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeployRunsPostBuildHook(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\npostBuild: [sh, -c, touch post-build-ran]\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if _, err := os.Stat(filepath.Join(conf.Path, "post-build-ran")); err != nil {
+		t.Errorf("expected the hook to run in the function directory: %v", err)
+	}
+}
+
+func TestDeployFailsOnPostBuildHook(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\npostBuild: [sh, -c, \"echo broken; exit 3\"]\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) || !strings.Contains(err.Error(), "postBuild hook") || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected a BuildError with the hook output, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestDeployPassesArtifactToPreDeployHook(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\npreDeploy: [sh, -c, 'test -f \"$LAMBDA_CI_ARTIFACT\" && echo \"$LAMBDA_CI_ARTIFACT\" > artifact.txt']\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	artifact, err := ioutil.ReadFile(filepath.Join(conf.Path, "artifact.txt"))
+	if err != nil {
+		t.Fatalf("expected the hook to receive an existing artifact: %v", err)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(string(artifact)), "/hello.zip") {
+		t.Errorf("expected the zip as artifact, got %s", artifact)
+	}
+}
+
+func TestDeployFailsOnPreDeployHook(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\npreDeploy: [\"false\"]\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	var deployErr *DeployError
+	if !errors.As(err, &deployErr) || !strings.Contains(err.Error(), "preDeploy hook") {
+		t.Errorf("expected a DeployError of the hook, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestDeploySkipsFunctionIfGuardFails(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\ndeployIf: [\"false\"]\n")
+
+	result := deployOne(t, conf, testOptions(client))
+
+	if result.Action != ActionSkipped {
+		t.Errorf("expected action %s, got %s", ActionSkipped, result.Action)
+	}
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected nothing to be deployed, got %v", mutations)
+	}
+}
+
+func TestDeployRunsGuardInFunctionDirectory(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\ndeployIf: [test, -f, main.go]\n")
+
+	result := deployOne(t, conf, testOptions(client))
+
+	if result.Action != ActionUpdated {
+		t.Errorf("expected action %s, got %s", ActionUpdated, result.Action)
+	}
+}
+
+func TestDeployFailsIfGuardCanNotRun(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\ndeployIf: [lambda-ci-missing-guard-command]\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "error while running deploy guard") {
+		t.Errorf("expected a guard error, got %v", err)
+	}
+}
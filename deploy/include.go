@@ -0,0 +1,117 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is the file inside an IncludeDir listing the paths that are not zipped.
+const ignoreFileName = ".lambdaignore"
+
+// readIgnorePatterns reads the .lambdaignore file of the given directory.
+// Empty lines and lines starting with # are skipped, a missing file means nothing is ignored.
+func readIgnorePatterns(dir string) ([]string, error) {
+	file, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, strings.Trim(line, "/"))
+	}
+	return patterns, scanner.Err()
+}
+
+// isIgnored reports whether the slash separated path relative to the IncludeDir matches one of the patterns.
+// Patterns are matched against the full relative path and against the base name, so *.map matches in every directory.
+func isIgnored(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, rel); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(rel)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// zipIncludeDir adds every file below the IncludeDir of this FunctionConfig to the zip writer.
+// Entries keep their path relative to the function directory, e.g. public/css/site.css.
+// Files and directories matched by the .lambdaignore of the IncludeDir are skipped.
+func (conf *FunctionConfig) zipIncludeDir(writer *zip.Writer) error {
+	root := filepath.Join(conf.getSourceDir(), conf.IncludeDir)
+	patterns, err := readIgnorePatterns(root)
+	if err != nil {
+		return fmt.Errorf("error while reading %s: %w", ignoreFileName, err)
+	}
+
+	return filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if rel == ignoreFileName || isIgnored(rel, patterns) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name := path.Join(conf.IncludeDir, rel)
+		if name == conf.getZipEntryName() || conf.isBinaryEntry(name) || conf.isExtensionEntry(name) {
+			return fmt.Errorf("included file %s collides with the binary", name)
+		}
+		return addZipEntry(writer, file, name, info)
+	})
+}
+
+// addZipEntry writes the file at the given path into the zip writer as name.
+func addZipEntry(writer *zip.Writer, file string, name string, info os.FileInfo) error {
+	header, err := newZipHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	fileWriter, err := writer.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	source, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	_, err = io.Copy(fileWriter, source)
+	return err
+}
@@ -0,0 +1,49 @@
+package deploy
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDeployZipsIncludeDirWithoutIgnoredFiles(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nincludeDir: public\n",
+		"public/.lambdaignore", "# source maps and drafts\n*.map\n\ndrafts/\n",
+		"public/index.html", "<html></html>",
+		"public/css/site.css", "body {}",
+		"public/css/site.css.map", "{}",
+		"public/drafts/next.html", "<html></html>",
+	)
+
+	deployOne(t, conf, testOptions(client))
+
+	entries := client.zipEntries(t, "hello")
+	sort.Strings(entries)
+	expected := []string{"hello", "public/css/site.css", "public/index.html"}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries %v, got %v", expected, entries)
+	}
+}
+
+func TestDeployRejectsIncludedFileCollidingWithBinary(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nzipEntryName: bin/hello\nincludeDir: bin\n", "bin/hello", "#!/bin/sh\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "included file bin/hello collides with the binary") {
+		t.Errorf("expected a collision error, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestParseRejectsUncleanIncludeDir(t *testing.T) {
+	_, err := parseTestConfig(t, "name: hello\nfileName: main.go\nincludeDir: ../shared\n", "main.go", testMain)
+
+	expectConfigError(t, err, "includeDir ../shared must be a clean relative path")
+}
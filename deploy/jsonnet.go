@@ -0,0 +1,53 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the files FindFunctionConfigs discovers by default.
+const (
+	yamlConfigName    = ".function.yaml"
+	jsonnetConfigName = ".function.jsonnet"
+)
+
+// isConfigFileName reports whether name is the name of a function config file.
+// Besides the default names, names matching the non-empty glob pattern are config files as well.
+func isConfigFileName(name string, pattern string) (bool, error) {
+	if name == yamlConfigName || name == jsonnetConfigName {
+		return true, nil
+	}
+	if pattern == "" {
+		return false, nil
+	}
+	return filepath.Match(pattern, name)
+}
+
+// isJsonnetConfig reports whether the config file at path is evaluated with jsonnet.
+func isJsonnetConfig(path string) bool {
+	return filepath.Ext(path) == ".jsonnet"
+}
+
+// evaluateJsonnet evaluates the jsonnet file at path with the jsonnet command and returns the resulting JSON.
+// The command runs in the directory of the file, so relative imports resolve next to it.
+func evaluateJsonnet(path string) ([]byte, error) {
+	if _, err := exec.LookPath("jsonnet"); err != nil {
+		return nil, fmt.Errorf("evaluating %s requires the jsonnet command: %w", path, err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("jsonnet", filepath.Base(path))
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("error while evaluating %s: %w: %s", path, err, message)
+		}
+		return nil, fmt.Errorf("error while evaluating %s: %w", path, err)
+	}
+	return output, nil
+}
@@ -0,0 +1,120 @@
+package deploy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// installJsonnet puts an executable jsonnet shell script with the given body first in the PATH.
+func installJsonnet(t *testing.T, body string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "jsonnet"), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestIsConfigFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		expected bool
+	}{
+		{".function.yaml", "", true},
+		{".function.jsonnet", "", true},
+		{"orders.lambda.yaml", "", false},
+		{"orders.lambda.yaml", "*.lambda.yaml", true},
+		{"main.go", "*.lambda.yaml", false},
+	}
+	for _, test := range tests {
+		if match, err := isConfigFileName(test.name, test.pattern); err != nil || match != test.expected {
+			t.Errorf("expected %s with pattern %q to match %v, got %v, %v", test.name, test.pattern, test.expected, match, err)
+		}
+	}
+}
+
+func TestFindFunctionConfigsDiscoversJsonnet(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "hello/.function.jsonnet", "{}", "world/.function.yaml", "name: world\n", "lib/config.jsonnet", "{}")
+
+	files, err := FindFunctionConfigs(dir, false, "")
+
+	if err != nil || len(files) != 2 || files[0] != filepath.Join(dir, "hello", jsonnetConfigName) || files[1] != filepath.Join(dir, "world", yamlConfigName) {
+		t.Errorf("expected the jsonnet and the yaml config, got %v, %v", files, err)
+	}
+}
+
+func TestParseEvaluatesJsonnetConfig(t *testing.T) {
+	// The stand-in prints the file it is called with, so the config must be given as plain JSON
+	installJsonnet(t, `cat "$1"`)
+	dir := t.TempDir()
+	writeFiles(t, dir, "main.go", testMain, jsonnetConfigName, `{"name": "hello", "fileName": "main.go", "memorySize": 256}`)
+
+	conf, err := ParseFunctionConfig(filepath.Join(dir, jsonnetConfigName))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Name != "hello" || conf.MemorySize != 256 || conf.Path != dir {
+		t.Errorf("expected hello with 256MB in %s, got %+v", dir, conf)
+	}
+}
+
+func TestParseEvaluatesComputedJsonnetName(t *testing.T) {
+	if _, err := exec.LookPath("jsonnet"); err != nil {
+		t.Skip("jsonnet is not installed")
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, "main.go", testMain,
+		"common.libsonnet", `{ service: "orders", stage: "prod" }`,
+		jsonnetConfigName, `local common = import "common.libsonnet"; { name: common.service + "-" + common.stage, fileName: "main.go" }`)
+
+	conf, err := ParseFunctionConfig(filepath.Join(dir, jsonnetConfigName))
+
+	if err != nil || conf.Name != "orders-prod" {
+		t.Errorf("expected the computed name orders-prod, got %+v, %v", conf, err)
+	}
+}
+
+func TestParseReportsJsonnetErrors(t *testing.T) {
+	installJsonnet(t, `echo "STATIC ERROR: .function.jsonnet:1:1: unexpected end of file" >&2; exit 1`)
+	dir := t.TempDir()
+	writeFiles(t, dir, jsonnetConfigName, "{")
+
+	_, err := ParseFunctionConfig(filepath.Join(dir, jsonnetConfigName))
+
+	if err == nil || !strings.HasSuffix(err.Error(), ": STATIC ERROR: .function.jsonnet:1:1: unexpected end of file") {
+		t.Errorf("expected the jsonnet error, got %v", err)
+	}
+}
+
+func TestParseKeepsJsonnetExitError(t *testing.T) {
+	installJsonnet(t, "exit 3")
+	dir := t.TempDir()
+	writeFiles(t, dir, jsonnetConfigName, "{}")
+
+	_, err := ParseFunctionConfig(filepath.Join(dir, jsonnetConfigName))
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 || !strings.HasSuffix(err.Error(), ": exit status 3") {
+		t.Errorf("expected the exit status of jsonnet, got %v", err)
+	}
+}
+
+func TestParseRequiresJsonnetCommand(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	dir := t.TempDir()
+	writeFiles(t, dir, jsonnetConfigName, "{}")
+
+	_, err := ParseFunctionConfig(filepath.Join(dir, jsonnetConfigName))
+
+	if err == nil || !strings.Contains(err.Error(), "requires the jsonnet command") {
+		t.Errorf("expected the missing jsonnet command to be reported, got %v", err)
+	}
+}
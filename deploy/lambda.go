@@ -0,0 +1,629 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+const (
+	updatePollMinInterval = 1 * time.Second
+	updatePollMaxInterval = 5 * time.Second
+	updateWaitTimeout     = 5 * time.Minute
+
+	defaultProvisionedConcurrencyTimeout = 10 * time.Minute
+
+	conflictRetryAttempts = 5
+)
+
+// errPollTimeout is returned by pollWithBackoff when the timeout elapsed.
+var errPollTimeout = errors.New("timed out while polling")
+
+// updateLambda takes the built and zipped go file and updates the corresponding Lambda function.
+// For image based functions the configured image URI is deployed instead.
+// With Options.OnlyChangedConfig the code update is skipped if the zip matches the live code.
+// The code hash reported for an uploaded zip must match the zip, with Options.SigningKey its checksum is signed.
+// Afterwards the handler and all other declared configuration fields are reconciled with the live function,
+// the configuration is only updated if at least one of them differs.
+// The deployed version and code hash are recorded in result.
+func (d *deployer) updateLambda(ctx context.Context, conf *FunctionConfig, result *Result) error {
+	client := d.lambda
+
+	var lambdaInfo *lambda.FunctionConfiguration
+	codeUnchanged := false
+	if d.opts.OnlyChangedConfig && conf.ImageUri == "" {
+		info, err := d.getLiveConfig(ctx, conf)
+		if err != nil {
+			return err
+		}
+		codeSha256, err := fileCodeSha256(conf.getZipOutputPath())
+		if err != nil {
+			return err
+		}
+		if codeSha256 == aws.StringValue(info.CodeSha256) {
+			logrus.Infof("code of lambda function %s is unchanged, skipped the code update", conf.Name)
+			lambdaInfo = info
+			codeUnchanged = true
+		}
+	}
+	if lambdaInfo == nil {
+		var err error
+		if lambdaInfo, err = d.updateCode(ctx, conf); err != nil {
+			return err
+		}
+		// Signing changes the zip, so the hash of a signed zip can't be compared with the local one
+		if conf.ImageUri == "" && conf.SigningProfile == "" {
+			hexSha256, err := conf.verifyCodeSha256(aws.StringValue(lambdaInfo.CodeSha256))
+			if err != nil {
+				return err
+			}
+			if d.opts.SigningKey != nil {
+				if err := d.signChecksum(conf, hexSha256); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	result.Version = aws.StringValue(lambdaInfo.Version)
+	result.CodeSha256 = aws.StringValue(lambdaInfo.CodeSha256)
+
+	if err := d.tagManaged(ctx, conf, aws.StringValue(lambdaInfo.FunctionArn)); err != nil {
+		return err
+	}
+
+	if err := d.waitForLiveUpdate(ctx, conf); err != nil {
+		return err
+	}
+	info, err := d.getLiveConfig(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	configInput, changes, err := d.planConfiguration(conf, info)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) > 0 {
+		if err := d.updateConfiguration(ctx, conf, configInput); err != nil {
+			return err
+		}
+		logrus.Infof("updated %s for lambda %s", strings.Join(changes, ", "), aws.StringValue(lambdaInfo.FunctionName))
+	}
+
+	if err := conf.updateReservedConcurrency(ctx, client); err != nil {
+		return err
+	}
+	if err := d.updateLogRetention(ctx, conf); err != nil {
+		return err
+	}
+
+	if conf.Alias != "" && codeUnchanged && len(changes) == 0 && d.opts.KeepAliasIfUnchanged {
+		alias, err := conf.getAlias(ctx, client)
+		if err != nil {
+			return err
+		}
+		// A missing alias is created as usual
+		if alias != nil {
+			logrus.Infof("lambda function %s is unchanged, alias %s stays at version %s", conf.Name, conf.Alias, aws.StringValue(alias.FunctionVersion))
+			result.Version = aws.StringValue(alias.FunctionVersion)
+			return nil
+		}
+	}
+
+	if conf.Alias != "" {
+		version, err := conf.publishAlias(ctx, client)
+		if err != nil {
+			return err
+		}
+		result.Version = version
+	} else if conf.Publish {
+		versionInfo, err := conf.publishVersion(ctx, client)
+		if err != nil {
+			return err
+		}
+		result.Version = aws.StringValue(versionInfo.Version)
+		if conf.HealthCheck != nil {
+			if err := conf.checkHealth(ctx, client, result.Version); err != nil {
+				return err
+			}
+		}
+	} else if conf.HealthCheck != nil {
+		if err := conf.checkHealth(ctx, client, "$LATEST"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateCode uploads the zip or the image URI of the function.
+func (d *deployer) updateCode(ctx context.Context, conf *FunctionConfig) (*lambda.FunctionConfiguration, error) {
+	// The code update receives the name as configured, including a qualifier
+	input := &lambda.UpdateFunctionCodeInput{
+		FunctionName: &conf.Name,
+	}
+	if conf.Architecture != "" {
+		input.Architectures = []*string{&conf.Architecture}
+	}
+	if conf.ImageUri != "" {
+		input.ImageUri = &conf.ImageUri
+	} else if d.opts.ArtifactBucket != "" {
+		key, version, err := d.stageArtifact(ctx, conf)
+		if err != nil {
+			return nil, fmt.Errorf("error while staging zip: %w", err)
+		}
+		if conf.SigningProfile != "" {
+			if key, err = d.signArtifact(ctx, conf, key, version); err != nil {
+				return nil, fmt.Errorf("error while signing zip: %w", err)
+			}
+		}
+		input.S3Bucket = &d.opts.ArtifactBucket
+		input.S3Key = &key
+	} else if conf.SigningProfile != "" {
+		return nil, errors.New("signingProfile requires an artifact bucket, see --artifact-bucket")
+	} else {
+		data, err := ioutil.ReadFile(conf.getZipOutputPath())
+		if err != nil {
+			return nil, err
+		}
+		input.ZipFile = data
+	}
+
+	lambdaInfo, err := d.lambda.UpdateFunctionCodeWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("updated lambda function %s", *lambdaInfo.FunctionName)
+	return lambdaInfo, nil
+}
+
+// planConfiguration compares the handler and all declared configuration fields with the live configuration.
+// Returns the update for all differing fields and their names, the update must only be sent if any field differs.
+func (d *deployer) planConfiguration(conf *FunctionConfig, info *lambda.FunctionConfiguration) (*lambda.UpdateFunctionConfigurationInput, []string, error) {
+	configInput := &lambda.UpdateFunctionConfigurationInput{
+		FunctionName: aws.String(conf.getUnqualifiedName()),
+	}
+	changes := conf.reconcileConfiguration(info, configInput)
+
+	// Image based functions have no handler
+	handlerCheck := d.opts.HandlerCheck
+	if !conf.managesHandler() {
+		handlerCheck = HandlerCheckOff
+	}
+	if conf.ImageUri == "" && handlerCheck != HandlerCheckOff {
+		// Check if the handler name is still correct of if it must be updated
+		handler := handlerForRuntime(conf)
+		if strings.Compare(aws.StringValue(info.Handler), handler) != 0 {
+			if handlerCheck == HandlerCheckWarn {
+				if err := d.warn("handler of lambda %s is %s, expected %s", conf.Name, aws.StringValue(info.Handler), handler); err != nil {
+					return nil, nil, err
+				}
+			} else {
+				configInput.Handler = &handler
+				changes = append(changes, "handler name")
+			}
+		}
+	}
+	return configInput, changes, nil
+}
+
+// updateConfiguration updates the function configuration and waits until the update is done.
+func (d *deployer) updateConfiguration(ctx context.Context, conf *FunctionConfig, input *lambda.UpdateFunctionConfigurationInput) error {
+	conf.liveConfig = nil
+	err := retryOnConflict(ctx, func() error {
+		_, err := d.lambda.UpdateFunctionConfigurationWithContext(ctx, input)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return d.waitForLiveUpdate(ctx, conf)
+}
+
+// checkCompatibility compares the declared package type, runtime and architecture with the live function.
+// A mismatch is an error unless Options.Force is set, in which case only a warning is logged.
+func (d *deployer) checkCompatibility(ctx context.Context, conf *FunctionConfig) error {
+	if conf.PackageType == "" && conf.Runtime == "" && conf.Architecture == "" {
+		return nil
+	}
+
+	info, err := d.getLiveConfig(ctx, conf)
+	if err != nil {
+		return err
+	}
+
+	var mismatches []string
+	if conf.PackageType != "" && conf.PackageType != aws.StringValue(info.PackageType) {
+		mismatches = append(mismatches, fmt.Sprintf("package type is %s but config declares %s", aws.StringValue(info.PackageType), conf.PackageType))
+	}
+	if conf.Runtime != "" && conf.Runtime != aws.StringValue(info.Runtime) {
+		mismatches = append(mismatches, fmt.Sprintf("runtime is %s but config declares %s", aws.StringValue(info.Runtime), conf.Runtime))
+	}
+	if conf.Architecture != "" {
+		liveArchitecture := lambda.ArchitectureX8664
+		if len(info.Architectures) > 0 {
+			liveArchitecture = aws.StringValue(info.Architectures[0])
+		}
+		if conf.Architecture != liveArchitecture {
+			mismatches = append(mismatches, fmt.Sprintf("architecture is %s but config declares %s", liveArchitecture, conf.Architecture))
+		}
+	}
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("lambda function %s is incompatible with its config: %s", conf.Name, strings.Join(mismatches, ", "))
+	if d.opts.Force {
+		return d.warn("%s", message)
+	}
+	return fmt.Errorf("%s (use --force to deploy anyway)", message)
+}
+
+// deprecatedRuntimes contains the runtimes AWS deprecated for Go functions.
+var deprecatedRuntimes = map[string]bool{
+	lambda.RuntimeGo1X: true,
+}
+
+// checkDeprecatedRuntime warns if the config or the live function uses a deprecated runtime.
+// The live configuration it fetches also provides the runtime that the zip entry name depends on if none is declared,
+// so packaging without AWS calls requires a declared runtime.
+func (d *deployer) checkDeprecatedRuntime(ctx context.Context, conf *FunctionConfig) error {
+	runtime := conf.Runtime
+	if runtime == "" && d.opts.OutputDir != "" && !d.opts.planning {
+		// The zip entry name of functions without a declared runtime depends on the live runtime
+		if conf.Bootstrap == "" && conf.ZipEntryName == "" {
+			return fmt.Errorf("lambda function %s must declare its runtime to be packaged", conf.Name)
+		}
+		return nil
+	}
+	if runtime == "" {
+		info, err := d.getLiveConfig(ctx, conf)
+		if err != nil {
+			return err
+		}
+		runtime = aws.StringValue(info.Runtime)
+	}
+	if !deprecatedRuntimes[runtime] {
+		return nil
+	}
+	return d.warn("lambda function %s uses the deprecated runtime %s, migrate to %s", conf.Name, runtime, lambda.RuntimeProvidedAl2023)
+}
+
+// updateReservedConcurrency sets or removes the reserved concurrency of the function, if the config declares it.
+func (conf *FunctionConfig) updateReservedConcurrency(ctx context.Context, client lambdaiface.LambdaAPI) error {
+	if conf.ReservedConcurrency != nil {
+		err := retryOnConflict(ctx, func() error {
+			_, err := client.PutFunctionConcurrencyWithContext(ctx, &lambda.PutFunctionConcurrencyInput{
+				FunctionName:                 aws.String(conf.getUnqualifiedName()),
+				ReservedConcurrentExecutions: conf.ReservedConcurrency,
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error while reserving concurrency: %w", err)
+		}
+		logrus.Infof("reserved %d concurrent executions for lambda function %s", *conf.ReservedConcurrency, conf.Name)
+	} else if conf.RemoveReservedConcurrency {
+		err := retryOnConflict(ctx, func() error {
+			_, err := client.DeleteFunctionConcurrencyWithContext(ctx, &lambda.DeleteFunctionConcurrencyInput{
+				FunctionName: aws.String(conf.getUnqualifiedName()),
+			})
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error while removing reserved concurrency: %w", err)
+		}
+		logrus.Infof("removed reserved concurrency of lambda function %s", conf.Name)
+	}
+	return nil
+}
+
+// publishVersion publishes a new version of the function from $LATEST.
+// For Edge functions the version ARN CloudFront has to be associated with is logged.
+func (conf *FunctionConfig) publishVersion(ctx context.Context, client lambdaiface.LambdaAPI) (*lambda.FunctionConfiguration, error) {
+	var versionInfo *lambda.FunctionConfiguration
+	err := retryOnConflict(ctx, func() error {
+		var err error
+		versionInfo, err = client.PublishVersionWithContext(ctx, &lambda.PublishVersionInput{
+			FunctionName: aws.String(conf.getUnqualifiedName()),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("published version %s of lambda function %s", aws.StringValue(versionInfo.Version), conf.Name)
+	if conf.Edge {
+		logrus.Infof("associate CloudFront with %s to use the new version of Lambda@Edge function %s", aws.StringValue(versionInfo.FunctionArn), conf.Name)
+	}
+	return versionInfo, nil
+}
+
+// publishAlias publishes a new version of the function and points the configured alias to it.
+// If provisioned concurrency is configured, the alias is only shifted once the new version is warmed up,
+// afterwards the provisioned concurrency of the versions the alias no longer routes to is removed.
+// If a health check is configured, the alias is only shifted once the new version passed it.
+// Returns the published version.
+func (conf *FunctionConfig) publishAlias(ctx context.Context, client lambdaiface.LambdaAPI) (string, error) {
+	versionInfo, err := conf.publishVersion(ctx, client)
+	if err != nil {
+		return "", err
+	}
+
+	var previousVersions []string
+	if conf.ProvisionedConcurrency > 0 {
+		alias, err := conf.getAlias(ctx, client)
+		if err != nil {
+			return "", err
+		}
+		previousVersions = getAliasVersions(alias)
+		if err := conf.warmUpVersion(ctx, client, *versionInfo.Version); err != nil {
+			return "", err
+		}
+	}
+
+	if conf.HealthCheck != nil {
+		if err := conf.checkHealth(ctx, client, *versionInfo.Version); err != nil {
+			return "", fmt.Errorf("%w, alias %s still points to the previous version", err, conf.Alias)
+		}
+	}
+
+	aliasInput := &lambda.UpdateAliasInput{
+		FunctionName:    aws.String(conf.getUnqualifiedName()),
+		Name:            &conf.Alias,
+		FunctionVersion: versionInfo.Version,
+		// Shifting all traffic ends a previous canary as well
+		RoutingConfig: &lambda.AliasRoutingConfiguration{AdditionalVersionWeights: map[string]*float64{}},
+	}
+	if conf.CanaryWeight > 0 {
+		if err := conf.routeCanary(ctx, client, aliasInput); err != nil {
+			return "", err
+		}
+	}
+
+	err = retryOnConflict(ctx, func() error {
+		_, err := client.UpdateAliasWithContext(ctx, aliasInput)
+		return err
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+		err = retryOnConflict(ctx, func() error {
+			_, err := client.CreateAliasWithContext(ctx, &lambda.CreateAliasInput{
+				FunctionName:    aws.String(conf.getUnqualifiedName()),
+				Name:            &conf.Alias,
+				FunctionVersion: versionInfo.Version,
+			})
+			return err
+		})
+	}
+	if err != nil {
+		return "", err
+	}
+	if aws.StringValue(aliasInput.FunctionVersion) != *versionInfo.Version {
+		logrus.Infof("routed %g of alias %s of lambda function %s to version %s, version %s keeps the rest",
+			conf.CanaryWeight, conf.Alias, conf.Name, *versionInfo.Version, aws.StringValue(aliasInput.FunctionVersion))
+	} else {
+		logrus.Infof("pointed alias %s of lambda function %s to version %s", conf.Alias, conf.Name, *versionInfo.Version)
+	}
+
+	routed := append(getAliasVersions(&lambda.AliasConfiguration{FunctionVersion: aliasInput.FunctionVersion, RoutingConfig: aliasInput.RoutingConfig}), *versionInfo.Version)
+	for _, version := range previousVersions {
+		if !contains(routed, version) {
+			conf.coolDownVersion(ctx, client, version)
+		}
+	}
+
+	return *versionInfo.Version, nil
+}
+
+// getAliasVersions returns the version the alias points to and the versions it routes additional traffic to.
+func getAliasVersions(alias *lambda.AliasConfiguration) []string {
+	if alias == nil {
+		return nil
+	}
+	versions := []string{aws.StringValue(alias.FunctionVersion)}
+	if alias.RoutingConfig != nil {
+		for version := range alias.RoutingConfig.AdditionalVersionWeights {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// routeCanary changes the alias update into a canary: the alias stays on its current version
+// and only CanaryWeight of the traffic is routed to the new version of input.
+// New aliases and unchanged versions receive all traffic, there is no previous version to keep.
+func (conf *FunctionConfig) routeCanary(ctx context.Context, client lambdaiface.LambdaAPI, input *lambda.UpdateAliasInput) error {
+	alias, err := conf.getAlias(ctx, client)
+	if err != nil || alias == nil {
+		return err
+	}
+
+	previous := aws.StringValue(alias.FunctionVersion)
+	version := aws.StringValue(input.FunctionVersion)
+	if previous == version {
+		return nil
+	}
+	input.FunctionVersion = &previous
+	input.RoutingConfig.AdditionalVersionWeights = map[string]*float64{version: aws.Float64(conf.CanaryWeight)}
+	return nil
+}
+
+// getAlias returns the configured alias of the function, nil if it doesn't exist yet.
+func (conf *FunctionConfig) getAlias(ctx context.Context, client lambdaiface.LambdaAPI) (*lambda.AliasConfiguration, error) {
+	alias, err := client.GetAliasWithContext(ctx, &lambda.GetAliasInput{
+		FunctionName: aws.String(conf.getUnqualifiedName()),
+		Name:         &conf.Alias,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == lambda.ErrCodeResourceNotFoundException {
+		return nil, nil
+	}
+	return alias, err
+}
+
+// coolDownVersion removes the provisioned concurrency of a version that no longer receives traffic.
+// Versions without provisioned concurrency are ignored. A failed removal is only logged, the alias already moved on.
+func (conf *FunctionConfig) coolDownVersion(ctx context.Context, client lambdaiface.LambdaAPI, version string) {
+	err := retryOnConflict(ctx, func() error {
+		_, err := client.DeleteProvisionedConcurrencyConfigWithContext(ctx, &lambda.DeleteProvisionedConcurrencyConfigInput{
+			FunctionName: aws.String(conf.getUnqualifiedName()),
+			Qualifier:    &version,
+		})
+		return err
+	})
+	if aerr, ok := err.(awserr.Error); ok && (aerr.Code() == lambda.ErrCodeProvisionedConcurrencyConfigNotFoundException || aerr.Code() == lambda.ErrCodeResourceNotFoundException) {
+		return
+	}
+	if err != nil {
+		logrus.WithError(err).Warnf("error while removing provisioned concurrency of version %s of lambda function %s", version, conf.Name)
+		return
+	}
+	logrus.Infof("removed provisioned concurrency of version %s of lambda function %s", version, conf.Name)
+}
+
+// warmUpVersion configures provisioned concurrency on the given version and waits until it is ready.
+func (conf *FunctionConfig) warmUpVersion(ctx context.Context, client lambdaiface.LambdaAPI, version string) error {
+	err := retryOnConflict(ctx, func() error {
+		_, err := client.PutProvisionedConcurrencyConfigWithContext(ctx, &lambda.PutProvisionedConcurrencyConfigInput{
+			FunctionName:                    aws.String(conf.getUnqualifiedName()),
+			Qualifier:                       &version,
+			ProvisionedConcurrentExecutions: &conf.ProvisionedConcurrency,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	timeout := conf.ProvisionedConcurrencyTimeout
+	if timeout == 0 {
+		timeout = defaultProvisionedConcurrencyTimeout
+	}
+	err = pollWithBackoff(ctx, timeout, func() (bool, error) {
+		info, err := client.GetProvisionedConcurrencyConfigWithContext(ctx, &lambda.GetProvisionedConcurrencyConfigInput{
+			FunctionName: aws.String(conf.getUnqualifiedName()),
+			Qualifier:    &version,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		switch aws.StringValue(info.Status) {
+		case lambda.ProvisionedConcurrencyStatusEnumReady:
+			return true, nil
+		case lambda.ProvisionedConcurrencyStatusEnumFailed:
+			return false, fmt.Errorf("provisioned concurrency for version %s of lambda function %s failed: %s", version, conf.Name, aws.StringValue(info.StatusReason))
+		}
+		return false, nil
+	})
+	if errors.Is(err, errPollTimeout) {
+		return fmt.Errorf("timed out after %s waiting for provisioned concurrency of lambda function %s", timeout, conf.Name)
+	}
+	if err != nil {
+		return err
+	}
+	logrus.Infof("provisioned concurrency for version %s of lambda function %s is ready", version, conf.Name)
+
+	return nil
+}
+
+// waitForUpdate polls the configuration of the given function until its last update is no longer in progress.
+// Returns the configuration after the update.
+func waitForUpdate(ctx context.Context, client lambdaiface.LambdaAPI, name string) (*lambda.FunctionConfiguration, error) {
+	var info *lambda.FunctionConfiguration
+	err := pollWithBackoff(ctx, updateWaitTimeout, func() (bool, error) {
+		var err error
+		info, err = client.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+			FunctionName: &name,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		switch aws.StringValue(info.LastUpdateStatus) {
+		case lambda.LastUpdateStatusSuccessful, "":
+			return true, nil
+		case lambda.LastUpdateStatusFailed:
+			return false, fmt.Errorf("update of lambda function %s failed: %s", name, aws.StringValue(info.LastUpdateStatusReason))
+		}
+		return false, nil
+	})
+	if errors.Is(err, errPollTimeout) {
+		return nil, fmt.Errorf("timed out after %s waiting for update of lambda function %s", updateWaitTimeout, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// pollWithBackoff calls check until it reports done, returns an error or the timeout elapses.
+// Polls are spread with a jittered backoff so parallel deploys don't query the API in lockstep.
+func pollWithBackoff(ctx context.Context, timeout time.Duration, check func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	interval := updatePollMinInterval
+
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+
+		wait := jitter(interval)
+		if time.Now().Add(wait).After(deadline) {
+			return errPollTimeout
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		interval *= 2
+		if interval > updatePollMaxInterval {
+			interval = updatePollMaxInterval
+		}
+	}
+}
+
+// retryOnConflict calls fn until it doesn't fail with a ResourceConflictException.
+// Lambda returns this error for configuration, concurrency, version and alias changes while another update
+// of the function is still in progress.
+// Any other error is returned immediately.
+func retryOnConflict(ctx context.Context, fn func() error) error {
+	interval := updatePollMinInterval
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		aerr, ok := err.(awserr.Error)
+		if !ok || aerr.Code() != lambda.ErrCodeResourceConflictException || attempt == conflictRetryAttempts {
+			return err
+		}
+
+		wait := jitter(interval)
+		logrus.Debugf("function update in progress, retrying in %s", wait)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		interval *= 2
+		if interval > updatePollMaxInterval {
+			interval = updatePollMaxInterval
+		}
+	}
+}
+
+// jitter randomizes the given duration to a value between half and the full duration.
+func jitter(duration time.Duration) time.Duration {
+	return duration/2 + time.Duration(rand.Int63n(int64(duration/2)+1))
+}
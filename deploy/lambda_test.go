@@ -0,0 +1,403 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJitterStaysWithinHalfAndFullDuration(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		wait := jitter(time.Second)
+		if wait < 500*time.Millisecond || wait > time.Second {
+			t.Fatalf("expected a wait between 500ms and 1s, got %s", wait)
+		}
+	}
+}
+
+func TestWaitForUpdatePollsUntilSuccessful(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.updateStatuses = []string{lambda.LastUpdateStatusInProgress}
+
+	if _, err := waitForUpdate(context.Background(), client, "hello"); err != nil {
+		t.Fatalf("expected the update to finish, got %v", err)
+	}
+	if count := client.count("GetFunctionConfiguration"); count != 2 {
+		t.Errorf("expected 2 polls, got %d", count)
+	}
+}
+
+func TestWaitForUpdateReportsFailedUpdate(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.updateStatuses = []string{lambda.LastUpdateStatusFailed}
+
+	_, err := waitForUpdate(context.Background(), client, "hello")
+	if err == nil || !strings.Contains(err.Error(), "update of lambda function hello failed") {
+		t.Errorf("expected a failed update, got %v", err)
+	}
+}
+
+func TestPollWithBackoffTimesOut(t *testing.T) {
+	err := pollWithBackoff(context.Background(), 10*time.Millisecond, func() (bool, error) {
+		return false, nil
+	})
+	if err != errPollTimeout {
+		t.Errorf("expected errPollTimeout, got %v", err)
+	}
+}
+
+func TestPollWithBackoffStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := pollWithBackoff(ctx, time.Minute, func() (bool, error) {
+		return false, nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPublishAliasWarmsUpNewVersionAndCoolsDownPrevious(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.setAlias("hello", "live", "7")
+	client.provisioned["hello:7"] = 5
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\nprovisionedConcurrency: 5\n")
+
+	result := deployOne(t, conf, testOptions(client))
+
+	if result.Version != "8" {
+		t.Errorf("expected version 8, got %s", result.Version)
+	}
+	if version, _ := client.alias("hello", "live"); version != "8" {
+		t.Errorf("expected the alias to point to version 8, got %s", version)
+	}
+	if versions := client.provisionedVersions(); len(versions) != 1 || versions[0] != "hello:8" {
+		t.Errorf("expected provisioned concurrency only on hello:8, got %v", versions)
+	}
+}
+
+func TestPublishAliasKeepsAliasIfWarmUpFails(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.setAlias("hello", "live", "7")
+	client.provisionedStatus = lambda.ProvisionedConcurrencyStatusEnumFailed
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\nprovisionedConcurrency: 5\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "provisioned concurrency for version 8 of lambda function hello failed") {
+		t.Errorf("expected a failed warm up, got %v", err)
+	}
+	if version, _ := client.alias("hello", "live"); version != "7" {
+		t.Errorf("expected the alias to stay at version 7, got %s", version)
+	}
+}
+
+func TestDeployLeavesHandlerIfNotManaged(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].Handler = aws.String("main")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nmanageHandler: false\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if handler := aws.StringValue(client.function("hello").Handler); handler != "main" {
+		t.Errorf("expected the handler to stay main, got %s", handler)
+	}
+	if count := client.count("UpdateFunctionConfiguration"); count != 0 {
+		t.Errorf("expected no configuration update, got %d", count)
+	}
+}
+
+func TestDeployRejectsIncompatibleFunction(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nruntime: provided.al2023\narchitecture: arm64\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	for _, message := range []string{"runtime is go1.x but config declares provided.al2023", "architecture is x86_64 but config declares arm64", "use --force"} {
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected an error containing %q, got %v", message, err)
+		}
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestDeployForcesIncompatibleFunction(t *testing.T) {
+	logs := captureLogs(t)
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\narchitecture: arm64\n")
+	opts := testOptions(client)
+	opts.Force = true
+
+	deployOne(t, conf, opts)
+
+	if !strings.Contains(logs.String(), "lambda function hello is incompatible with its config") {
+		t.Errorf("expected a compatibility warning, got logs %s", logs)
+	}
+	if architecture := aws.StringValue(client.function("hello").Architectures[0]); architecture != lambda.ArchitectureArm64 {
+		t.Errorf("expected the code to be deployed as arm64, got %s", architecture)
+	}
+}
+
+func TestRetryOnConflictReturnsOtherErrorsImmediately(t *testing.T) {
+	calls := 0
+	err := retryOnConflict(context.Background(), func() error {
+		calls++
+		return notFound("Function not found")
+	})
+
+	if err == nil || calls != 1 {
+		t.Errorf("expected the error after 1 call, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryOnConflictStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := retryOnConflict(ctx, func() error {
+		calls++
+		return conflict()
+	})
+
+	if err != context.Canceled || calls != 1 {
+		t.Errorf("expected context.Canceled after 1 call, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDeployRetriesAliasUpdateOnConflict(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.setAlias("hello", "live", "1")
+	client.fail("UpdateAlias", conflict())
+	client.fail("PutFunctionConcurrency", conflict())
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\nreservedConcurrency: 10\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if version, _ := client.alias("hello", "live"); version != "2" {
+		t.Errorf("expected the alias to point to version 2, got %s", version)
+	}
+	if count := client.count("UpdateAlias"); count != 2 {
+		t.Errorf("expected 2 alias updates, got %d", count)
+	}
+	if reserved := client.reserved["hello"]; reserved != 10 {
+		t.Errorf("expected 10 reserved executions, got %d", reserved)
+	}
+}
+
+func TestDeployOnlyWarnsAboutHandlerInWarnMode(t *testing.T) {
+	logs := captureLogs(t)
+	client := newFakeLambda("hello")
+	client.functions["hello"].Handler = aws.String("main")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.HandlerCheck = HandlerCheckWarn
+
+	deployOne(t, conf, opts)
+
+	if !strings.Contains(logs.String(), "handler of lambda hello is main, expected hello") {
+		t.Errorf("expected a handler warning, got logs %s", logs)
+	}
+	if count := client.count("UpdateFunctionConfiguration"); count != 0 {
+		t.Errorf("expected no configuration update, got %d", count)
+	}
+}
+
+func TestDeployRejectsUnknownHandlerCheck(t *testing.T) {
+	opts := testOptions(newFakeLambda())
+	opts.HandlerCheck = "fix"
+
+	if _, err := Deploy(context.Background(), nil, opts); err == nil {
+		t.Error("expected an error for an unknown handler check mode")
+	}
+}
+
+func TestCheckDeprecatedRuntime(t *testing.T) {
+	tests := []struct {
+		name        string
+		liveRuntime string
+		config      string
+		deprecated  bool
+	}{
+		{"live go1.x", lambda.RuntimeGo1X, "name: hello\nfileName: main.go\n", true},
+		{"live provided.al2023", lambda.RuntimeProvidedAl2023, "name: hello\nfileName: main.go\n", false},
+		{"declared go1.x", lambda.RuntimeProvidedAl2023, "name: hello\nfileName: main.go\nruntime: go1.x\n", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			client := newFakeLambda("hello")
+			client.functions["hello"].Runtime = aws.String(test.liveRuntime)
+			conf := newTestFunction(t, test.config)
+			d, err := newDeployer(testOptions(client))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := d.checkDeprecatedRuntime(context.Background(), conf); err != nil {
+				t.Fatalf("expected only a warning, got %v", err)
+			}
+
+			warned := strings.Contains(logs.String(), "uses the deprecated runtime go1.x, migrate to provided.al2023")
+			if warned != test.deprecated {
+				t.Errorf("expected warning %t, got logs %s", test.deprecated, logs)
+			}
+		})
+	}
+}
+
+func TestDeployRemovesReservedConcurrencyOnlyIfRequested(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		deletes  int
+		reserved bool
+	}{
+		{"remove", "name: hello\nfileName: main.go\nremoveReservedConcurrency: true\n", 1, false},
+		{"not declared", "name: hello\nfileName: main.go\n", 0, true},
+		{"explicitly kept", "name: hello\nfileName: main.go\nremoveReservedConcurrency: false\n", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeLambda("hello")
+			client.reserved["hello"] = 5
+			conf := newTestFunction(t, test.config)
+
+			deployOne(t, conf, testOptions(client))
+
+			if deletes := client.count("DeleteFunctionConcurrency"); deletes != test.deletes {
+				t.Errorf("expected %d deletes, got %d", test.deletes, deletes)
+			}
+			if _, reserved := client.reserved["hello"]; reserved != test.reserved {
+				t.Errorf("expected reserved concurrency %v, got %v", test.reserved, reserved)
+			}
+			if puts := client.count("PutFunctionConcurrency"); puts != 0 {
+				t.Errorf("expected no reserved concurrency to be set, got %d calls", puts)
+			}
+		})
+	}
+}
+
+func TestParseRejectsReservedConcurrencyWithRemoval(t *testing.T) {
+	_, err := ParseFunctionConfigFromReader(strings.NewReader("name: hello\nfileName: main.go\nreservedConcurrency: 5\nremoveReservedConcurrency: true\n"), t.TempDir())
+
+	expectConfigError(t, err, "reservedConcurrency can't be used with removeReservedConcurrency")
+}
+
+func TestOnlyChangedConfigSkipsUnchangedCode(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	opts := testOptions(client)
+	opts.OnlyChangedConfig = true
+	deployOne(t, conf, opts)
+	liveCode := aws.StringValue(client.functions["hello"].CodeSha256)
+	client.calls = nil
+	conf.MemorySize = 512
+
+	result := deployOne(t, conf, opts)
+
+	if mutations := client.mutations(); !reflect.DeepEqual(mutations, []string{"UpdateFunctionConfiguration hello"}) {
+		t.Errorf("expected only the configuration update, got %v", mutations)
+	}
+	if memory := aws.Int64Value(client.functions["hello"].MemorySize); memory != 512 {
+		t.Errorf("expected memory size 512, got %d", memory)
+	}
+	if result.Action != ActionUpdated || result.CodeSha256 != liveCode {
+		t.Errorf("expected an update with the live code %s, got %+v", liveCode, result)
+	}
+}
+
+func TestOnlyChangedConfigUploadsChangedCode(t *testing.T) {
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	opts.OnlyChangedConfig = true
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\nmemorySize: 512\n"), opts)
+
+	if mutations := client.mutations(); !reflect.DeepEqual(mutations, []string{"UpdateFunctionCode hello", "UpdateFunctionConfiguration hello"}) {
+		t.Errorf("expected the code and the configuration update, got %v", mutations)
+	}
+}
+
+func TestKeepAliasIfUnchangedSkipsPublishing(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\n")
+	opts := testOptions(client)
+	opts.OnlyChangedConfig = true
+	opts.KeepAliasIfUnchanged = true
+	deployOne(t, conf, opts)
+	client.calls = nil
+
+	result := deployOne(t, conf, opts)
+
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected no new version and no alias update, got %v", mutations)
+	}
+	if version, _ := client.alias("hello", "live"); version != "1" || result.Version != "1" {
+		t.Errorf("expected the alias to stay at version 1, got %s with result version %s", version, result.Version)
+	}
+}
+
+func TestKeepAliasIfUnchangedPublishesChangedConfiguration(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\n")
+	opts := testOptions(client)
+	opts.OnlyChangedConfig = true
+	opts.KeepAliasIfUnchanged = true
+	deployOne(t, conf, opts)
+	conf.Timeout = 30
+
+	result := deployOne(t, conf, opts)
+
+	if version, _ := client.alias("hello", "live"); version != "2" || result.Version != "2" {
+		t.Errorf("expected the alias to point to the new version 2, got %s with result version %s", version, result.Version)
+	}
+}
+
+func TestKeepAliasIfUnchangedRequiresOnlyChangedConfig(t *testing.T) {
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	opts.KeepAliasIfUnchanged = true
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\n")}, opts)
+
+	if err == nil || err.Error() != "keeping unchanged aliases requires OnlyChangedConfig" {
+		t.Errorf("expected the option to be rejected, got %v", err)
+	}
+}
+
+func TestDeployAppliesHandlerRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{"default", "", "hello"},
+		{"name", "handlerRule: name\n", "hello"},
+		{"name with zip entry", "handlerRule: name\nzipEntryName: app\n", "app"},
+		{"bootstrap", "handlerRule: bootstrap\n", "bootstrap"},
+		{"explicit", "handlerRule: explicit\nhandler: main.handler\n", "main.handler"},
+		{"none", "handlerRule: none\n", "main"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeLambda("hello")
+			client.functions["hello"].Handler = aws.String("main")
+
+			deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"+test.config), testOptions(client))
+
+			if handler := aws.StringValue(client.functions["hello"].Handler); handler != test.expected {
+				t.Errorf("expected handler %s, got %s", test.expected, handler)
+			}
+			if updates := client.count("UpdateFunctionConfiguration"); (updates == 0) != (test.expected == "main") {
+				t.Errorf("expected the configuration to be updated only for a changed handler, got %d updates", updates)
+			}
+		})
+	}
+}
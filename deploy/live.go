@@ -0,0 +1,36 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+)
+
+// getLiveConfig returns the live configuration of the function.
+// The configuration is fetched at most once and reused until the function is mutated.
+func (d *deployer) getLiveConfig(ctx context.Context, conf *FunctionConfig) (*lambda.FunctionConfiguration, error) {
+	if conf.liveConfig != nil {
+		return conf.liveConfig, nil
+	}
+
+	info, err := d.lambda.GetFunctionConfigurationWithContext(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(conf.getUnqualifiedName()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	conf.liveConfig = info
+	return info, nil
+}
+
+// waitForLiveUpdate waits until the last update of the function is done.
+// The configuration polled after the update replaces the cached live configuration.
+func (d *deployer) waitForLiveUpdate(ctx context.Context, conf *FunctionConfig) error {
+	conf.liveConfig = nil
+	info, err := waitForUpdate(ctx, d.lambda, conf.getUnqualifiedName())
+	if err != nil {
+		return err
+	}
+	conf.liveConfig = info
+	return nil
+}
@@ -0,0 +1,39 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"testing"
+)
+
+func TestGetLiveConfigIsCached(t *testing.T) {
+	client := newFakeLambda("hello")
+	d := &deployer{lambda: client}
+	conf := &FunctionConfig{Name: "hello:PROD"}
+
+	for i := 0; i < 3; i++ {
+		info, err := d.getLiveConfig(context.Background(), conf)
+		if err != nil {
+			t.Fatalf("error while getting live config: %v", err)
+		}
+		if aws.StringValue(info.FunctionName) != "hello" {
+			t.Errorf("expected the config of hello, got %s", aws.StringValue(info.FunctionName))
+		}
+	}
+	if count := client.count("GetFunctionConfiguration"); count != 1 {
+		t.Errorf("expected 1 call, got %d", count)
+	}
+}
+
+func TestDeployFetchesLiveConfigOnceBeforeUpdate(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].Handler = aws.String("main")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\narchitecture: x86_64\nmemorySize: 256\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	// One call for the compatibility and runtime checks, one after the code and one after the configuration update
+	if count := client.count("GetFunctionConfiguration"); count != 3 {
+		t.Errorf("expected 3 calls, got %d", count)
+	}
+}
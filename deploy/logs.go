@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/sirupsen/logrus"
+)
+
+// logRetentionDays contains the retention periods CloudWatch Logs accepts.
+var logRetentionDays = []int64{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+// validateLogRetention checks that logRetentionDays is one of the periods CloudWatch Logs accepts.
+func (conf *FunctionConfig) validateLogRetention() error {
+	if conf.LogRetentionDays == 0 {
+		return nil
+	}
+	for _, days := range logRetentionDays {
+		if conf.LogRetentionDays == days {
+			return nil
+		}
+	}
+	return fmt.Errorf("logRetentionDays %d must be one of %v", conf.LogRetentionDays, logRetentionDays)
+}
+
+// getLogGroupName returns the log group the function writes to, the one of the loggingConfig or the default one.
+func (conf *FunctionConfig) getLogGroupName() string {
+	if conf.LoggingConfig != nil && conf.LoggingConfig.LogGroup != "" {
+		return conf.LoggingConfig.LogGroup
+	}
+	return "/aws/lambda/" + conf.getFunctionName()
+}
+
+// updateLogRetention creates the log group of the function if it is missing and sets its retention period.
+// Nothing is done if the config declares no logRetentionDays.
+func (d *deployer) updateLogRetention(ctx context.Context, conf *FunctionConfig) error {
+	if conf.LogRetentionDays == 0 {
+		return nil
+	}
+
+	logGroup := conf.getLogGroupName()
+	_, err := d.logs.CreateLogGroupWithContext(ctx, &cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: &logGroup,
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == cloudwatchlogs.ErrCodeResourceAlreadyExistsException {
+		err = nil
+	} else if err == nil {
+		logrus.Infof("created log group %s for lambda function %s", logGroup, conf.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("error while creating log group %s: %w", logGroup, err)
+	}
+
+	_, err = d.logs.PutRetentionPolicyWithContext(ctx, &cloudwatchlogs.PutRetentionPolicyInput{
+		LogGroupName:    &logGroup,
+		RetentionInDays: aws.Int64(conf.LogRetentionDays),
+	})
+	if err != nil {
+		return fmt.Errorf("error while setting retention of log group %s: %w", logGroup, err)
+	}
+	logrus.Infof("set retention of log group %s to %d days", logGroup, conf.LogRetentionDays)
+	return nil
+}
@@ -0,0 +1,67 @@
+package deploy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeployCreatesLogGroupWithRetention(t *testing.T) {
+	logs := captureLogs(t)
+	client := newFakeLambda("hello")
+	groups := &fakeLogs{}
+	opts := testOptions(client)
+	opts.CloudWatchLogs = groups
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\nlogRetentionDays: 14\n"), opts)
+
+	if !reflect.DeepEqual(groups.retention, map[string]int64{"/aws/lambda/hello": 14}) {
+		t.Errorf("expected the log group with a retention of 14 days, got %v", groups.retention)
+	}
+	if !strings.Contains(logs.String(), "created log group /aws/lambda/hello for lambda function hello") {
+		t.Errorf("expected the log group to be created, got logs %s", logs.String())
+	}
+}
+
+func TestDeployUpdatesRetentionOfExistingLogGroup(t *testing.T) {
+	logs := captureLogs(t)
+	client := newFakeLambda("hello")
+	groups := &fakeLogs{retention: map[string]int64{"/custom/hello": 7}}
+	opts := testOptions(client)
+	opts.CloudWatchLogs = groups
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\nlogRetentionDays: 30\nloggingConfig:\n  logGroup: /custom/hello\n"), opts)
+
+	if !reflect.DeepEqual(groups.retention, map[string]int64{"/custom/hello": 30}) {
+		t.Errorf("expected the retention of the existing log group to be 30 days, got %v", groups.retention)
+	}
+	if strings.Contains(logs.String(), "created log group") {
+		t.Errorf("expected the existing log group to be reused, got logs %s", logs.String())
+	}
+}
+
+func TestDeployLeavesLogGroupWithoutRetention(t *testing.T) {
+	client := newFakeLambda("hello")
+	groups := &fakeLogs{}
+	opts := testOptions(client)
+	opts.CloudWatchLogs = groups
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+	if len(groups.retention) != 0 {
+		t.Errorf("expected no log group changes, got %v", groups.retention)
+	}
+}
+
+func TestValidateLogRetention(t *testing.T) {
+	for _, days := range []int64{0, 1, 365, 3653} {
+		if err := (&FunctionConfig{LogRetentionDays: days}).validateLogRetention(); err != nil {
+			t.Errorf("expected %d days to be valid, got %v", days, err)
+		}
+	}
+	for _, days := range []int64{2, 366, 4000} {
+		if err := (&FunctionConfig{LogRetentionDays: days}).validateLogRetention(); err == nil || !strings.HasPrefix(err.Error(), "logRetentionDays") {
+			t.Errorf("expected %d days to be rejected, got %v", days, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Pipeline steps reported to a MetricsRecorder.
+const (
+	StepBuild  = "build"
+	StepZip    = "zip"
+	StepUpload = "upload"
+)
+
+// MetricsRecorder receives the duration of every pipeline step.
+type MetricsRecorder interface {
+	// RecordDuration is called after a step of the given function finished, successful or not.
+	RecordDuration(function string, step string, duration time.Duration)
+}
+
+// noopRecorder discards all metrics.
+type noopRecorder struct{}
+
+func (noopRecorder) RecordDuration(string, string, time.Duration) {}
+
+// StatsdRecorder pushes step durations as statsd timings over UDP.
+type StatsdRecorder struct {
+	conn net.Conn
+}
+
+// NewStatsdRecorder creates a StatsdRecorder sending to the given host:port address.
+func NewStatsdRecorder(address string) (*StatsdRecorder, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &StatsdRecorder{conn: conn}, nil
+}
+
+// RecordDuration sends the duration as lambda_ci.<step>.<function> timing.
+// Sending is best effort, errors are ignored like statsd clients usually do.
+func (r *StatsdRecorder) RecordDuration(function string, step string, duration time.Duration) {
+	name := strings.NewReplacer(".", "_", ":", "_", "|", "_").Replace(function)
+	_, _ = fmt.Fprintf(r.conn, "lambda_ci.%s.%s:%d|ms", step, name, duration.Milliseconds())
+}
+
+// Close closes the underlying connection.
+func (r *StatsdRecorder) Close() error {
+	return r.conn.Close()
+}
+
+// measure runs fn and records its duration for the given function and step.
+func (d *deployer) measure(function string, step string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	d.opts.Metrics.RecordDuration(function, step, time.Since(start))
+	return err
+}
@@ -0,0 +1,67 @@
+package deploy
+
+import (
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stepRecorder records the steps reported for every function.
+type stepRecorder struct {
+	mutex sync.Mutex
+	steps map[string][]string
+}
+
+func (r *stepRecorder) RecordDuration(function string, step string, duration time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.steps == nil {
+		r.steps = map[string][]string{}
+	}
+	r.steps[function] = append(r.steps[function], step)
+}
+
+func TestDeployRecordsStepDurations(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello:PROD\nfileName: main.go\n")
+	recorder := &stepRecorder{}
+	opts := testOptions(client)
+	opts.Metrics = recorder
+
+	deployOne(t, conf, opts)
+
+	steps := recorder.steps["hello"]
+	sort.Strings(steps)
+	if len(steps) != 3 || steps[0] != StepBuild || steps[1] != StepUpload || steps[2] != StepZip {
+		t.Errorf("expected the build, zip and upload steps of hello, got %v", recorder.steps)
+	}
+}
+
+func TestStatsdRecorderSendsTimings(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	recorder, err := NewStatsdRecorder(listener.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("error while creating recorder: %v", err)
+	}
+	defer recorder.Close()
+
+	recorder.RecordDuration("orders.api|v2", StepBuild, 1500*time.Millisecond)
+
+	buffer := make([]byte, 512)
+	if err := listener.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	n, _, err := listener.ReadFrom(buffer)
+	if err != nil {
+		t.Fatalf("expected a timing to be sent: %v", err)
+	}
+	if packet := string(buffer[:n]); packet != "lambda_ci.build.orders_api_v2:1500|ms" {
+		t.Errorf("expected the sanitized timing, got %s", packet)
+	}
+}
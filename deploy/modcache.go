@@ -0,0 +1,69 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// moduleWarmer downloads the dependencies of every module once before its first build.
+// Parallel builds of the same module would otherwise all download the missing modules into the cache at once.
+type moduleWarmer struct {
+	mutex   sync.Mutex
+	modules map[string]*sync.Once
+}
+
+// newModuleWarmer returns a moduleWarmer that didn't warm up any module yet.
+func newModuleWarmer() *moduleWarmer {
+	return &moduleWarmer{modules: map[string]*sync.Once{}}
+}
+
+// warm runs go mod download for the module the function is built in, unless it already ran for this module.
+// Concurrent calls for the same module wait until the first download is done.
+// A failed warm-up is only logged, the build reports the actual error.
+func (w *moduleWarmer) warm(ctx context.Context, conf *FunctionConfig) {
+	gomod, err := conf.goEnv(ctx, "GOMOD")
+	if err != nil || gomod == "" || gomod == os.DevNull {
+		return
+	}
+	// Functions with Replace directives download the modules of their own go.mod copy
+	if conf.replaceModFile != "" {
+		gomod = conf.replaceModFile
+	}
+
+	w.mutex.Lock()
+	once, ok := w.modules[gomod]
+	if !ok {
+		once = &sync.Once{}
+		w.modules[gomod] = once
+	}
+	w.mutex.Unlock()
+
+	once.Do(func() {
+		logrus.Debugf("downloading the dependencies of module %s", gomod)
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "go", "mod", "download")
+		cmd.Dir = conf.getSourceDir()
+		cmd.Env = conf.getBuildEnv()
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			logrus.Warnf("error while downloading the dependencies of module %s: %s", gomod, strings.TrimSpace(stderr.String()))
+		}
+	})
+}
+
+// goEnv returns the value of the go env variable in the build environment of this FunctionConfig.
+func (conf *FunctionConfig) goEnv(ctx context.Context, name string) (string, error) {
+	cmd := exec.CommandContext(ctx, "go", "env", name)
+	cmd.Dir = conf.getSourceDir()
+	cmd.Env = conf.getBuildEnv()
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
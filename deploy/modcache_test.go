@@ -0,0 +1,75 @@
+package deploy
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newModuleFunctions writes a module depending on a local library with a function per name and parses their configs.
+func newModuleFunctions(t *testing.T, names ...string) []*FunctionConfig {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"lib/go.mod", "module example.com/lib\n\ngo 1.19\n",
+		"lib/lib.go", "package lib\n\nfunc Name() string { return \"lib\" }\n",
+		"app/go.mod", "module example.com/app\n\ngo 1.19\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ../lib\n")
+	var configs []*FunctionConfig
+	for _, name := range names {
+		writeFiles(t, dir, filepath.Join("app", name, "main.go"),
+			"package main\n\nimport \"example.com/lib\"\n\nfunc main() { println(lib.Name()) }\n")
+		conf, err := ParseFunctionConfigFromReader(strings.NewReader("name: "+name+"\nfileName: main.go\n"), filepath.Join(dir, "app", name))
+		if err != nil {
+			t.Fatalf("error while parsing config: %v", err)
+		}
+		configs = append(configs, conf)
+	}
+	return configs
+}
+
+func TestDeployWarmsModuleOnceForParallelBuilds(t *testing.T) {
+	names := []string{"alpha", "beta", "gamma", "delta"}
+	client := newFakeLambda(names...)
+	configs := newModuleFunctions(t, names...)
+	logs := captureLogs(t)
+	logrus.SetLevel(logrus.DebugLevel)
+	t.Cleanup(func() { logrus.SetLevel(logrus.InfoLevel) })
+	opts := testOptions(client)
+	opts.Concurrency = len(names)
+
+	results, err := Deploy(context.Background(), configs, opts)
+
+	if err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+	for i, result := range results {
+		if result.Action != ActionUpdated {
+			t.Errorf("expected %s to be updated, got %s", names[i], result.Action)
+		}
+	}
+	if warmups := strings.Count(logs.String(), "downloading the dependencies of module"); warmups != 1 {
+		t.Errorf("expected the module to be warmed up once, got %d warm-ups", warmups)
+	}
+}
+
+func TestModuleWarmerSkipsFunctionsOutsideModules(t *testing.T) {
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	warmer := newModuleWarmer()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			warmer.warm(context.Background(), conf)
+		}()
+	}
+	wg.Wait()
+
+	if len(warmer.modules) != 0 {
+		t.Errorf("expected no module to be warmed up, got %v", warmer.modules)
+	}
+}
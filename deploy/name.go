@@ -0,0 +1,138 @@
+package deploy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// buildNameUnsafe matches the characters that are replaced in build names.
+	buildNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9-_]`)
+	// functionNamePattern matches a function name with an optional version or alias qualifier.
+	functionNamePattern = regexp.MustCompile(`^([a-zA-Z0-9-_]{1,64})(:(\$LATEST|[a-zA-Z0-9-_]+))?$`)
+	// functionArnPattern matches a function ARN with an optional version or alias qualifier.
+	functionArnPattern = regexp.MustCompile(`^arn:aws[a-zA-Z-]*:lambda:[a-z]{2}(-gov)?-[a-z]+-\d:\d{12}:function:([a-zA-Z0-9-_]{1,64})(:(\$LATEST|[a-zA-Z0-9-_]+))?$`)
+)
+
+// splitFunctionName splits a function name, name:qualifier or function ARN into the bare function name and the qualifier.
+// ok is false if name has none of these forms.
+func splitFunctionName(name string) (bare string, qualifier string, ok bool) {
+	if match := functionNamePattern.FindStringSubmatch(name); match != nil {
+		return match[1], match[3], true
+	}
+	if match := functionArnPattern.FindStringSubmatch(name); match != nil {
+		return match[2], match[4], true
+	}
+	return "", "", false
+}
+
+// DecorateName adds prefix and suffix to the bare function name, e.g. for stage names like dev- or -prod.
+// An ARN prefix and a qualifier are kept. The namePrefix and nameSuffix of the config replace the given ones.
+// dependsOn entries and fn-arn: environment references are decorated the same way, they name functions of the same deploy.
+// It must be called once before the config is deployed, all AWS calls and derived names use the decorated name.
+func (conf *FunctionConfig) DecorateName(prefix string, suffix string) error {
+	if conf.NamePrefix != "" {
+		prefix = conf.NamePrefix
+	}
+	if conf.NameSuffix != "" {
+		suffix = conf.NameSuffix
+	}
+	if prefix == "" && suffix == "" {
+		return nil
+	}
+
+	name, err := decorateFunctionName(conf.Name, prefix, suffix)
+	if err != nil {
+		return err
+	}
+	dependencies := make([]string, len(conf.DependsOn))
+	for i, dependency := range conf.DependsOn {
+		if dependencies[i], err = decorateFunctionName(dependency, prefix, suffix); err != nil {
+			return fmt.Errorf("dependsOn entry %s: %w", dependency, err)
+		}
+	}
+	environment := make(map[string]string, len(conf.Environment))
+	for key, value := range conf.Environment {
+		if strings.HasPrefix(value, functionArnPrefix) {
+			reference, err := decorateFunctionName(strings.TrimPrefix(value, functionArnPrefix), prefix, suffix)
+			if err != nil {
+				return fmt.Errorf("environment variable %s: %w", key, err)
+			}
+			value = functionArnPrefix + reference
+		}
+		environment[key] = value
+	}
+	conf.Name = name
+	conf.DependsOn = dependencies
+	if conf.Environment != nil {
+		conf.Environment = environment
+	}
+	return nil
+}
+
+// isFunctionNameAffix reports whether s only contains characters allowed in function names.
+func isFunctionNameAffix(s string) bool {
+	return !buildNameUnsafe.MatchString(s)
+}
+
+// decorateFunctionName adds prefix and suffix to the bare function name of a function name, name:qualifier or function ARN.
+func decorateFunctionName(name string, prefix string, suffix string) (string, error) {
+	bare, _, ok := splitFunctionName(name)
+	if !ok {
+		return "", fmt.Errorf("name %s must be a function name, name:qualifier or function ARN", name)
+	}
+	start := 0
+	if index := strings.Index(name, ":function:"); strings.HasPrefix(name, "arn:") && index >= 0 {
+		start = index + len(":function:")
+	}
+	decorated := name[:start] + prefix + bare + suffix + name[start+len(bare):]
+	if _, _, ok := splitFunctionName(decorated); !ok {
+		return "", fmt.Errorf("decorated name %s must be a valid function name of at most 64 characters", decorated)
+	}
+	return decorated, nil
+}
+
+// getFunctionName returns the bare function name without ARN prefix and qualifier.
+// It is used for metrics and the debug archive, local artifacts use getBuildName.
+func (conf *FunctionConfig) getFunctionName() string {
+	if bare, _, ok := splitFunctionName(conf.Name); ok {
+		return bare
+	}
+	return conf.Name
+}
+
+// getBuildName returns the name of the built binary and zip file.
+// It is the bare function name with every character unsafe in file names replaced by an underscore,
+// so configs constructed without validation never produce paths outside the build directory.
+func (conf *FunctionConfig) getBuildName() string {
+	return buildNameUnsafe.ReplaceAllString(conf.getFunctionName(), "_")
+}
+
+// getNameRegion returns the region of the function ARN in Name, otherwise the declared region.
+// It is empty for functions deployed to the default region.
+func (conf *FunctionConfig) getNameRegion() string {
+	if functionArnPattern.MatchString(conf.Name) {
+		return strings.Split(conf.Name, ":")[3]
+	}
+	return conf.getRegion()
+}
+
+// getPlanZipName returns the name of the zip of the function in the artifact directory of a plan.
+// Functions of the same name in different regions are different functions, the region keeps their zips apart.
+// The region is made safe for file names like the build name.
+func (conf *FunctionConfig) getPlanZipName() string {
+	if region := conf.getNameRegion(); region != "" {
+		return conf.getBuildName() + "." + buildNameUnsafe.ReplaceAllString(region, "_") + ".zip"
+	}
+	return conf.getBuildName() + ".zip"
+}
+
+// getUnqualifiedName returns the configured name or ARN without the qualifier.
+// It is passed to the API calls that operate on the unpublished function, like configuration updates and aliases.
+func (conf *FunctionConfig) getUnqualifiedName() string {
+	if _, qualifier, ok := splitFunctionName(conf.Name); ok && qualifier != "" {
+		return conf.Name[:len(conf.Name)-len(qualifier)-1]
+	}
+	return conf.Name
+}
@@ -0,0 +1,147 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"strings"
+	"testing"
+)
+
+func TestSplitFunctionName(t *testing.T) {
+	arn := "arn:aws:lambda:eu-central-1:123456789012:function:hello"
+	tests := []struct {
+		name        string
+		bare        string
+		qualifier   string
+		unqualified string
+		ok          bool
+	}{
+		{"hello", "hello", "", "hello", true},
+		{"hello:PROD", "hello", "PROD", "hello", true},
+		{"hello:$LATEST", "hello", "$LATEST", "hello", true},
+		{arn, "hello", "", arn, true},
+		{arn + ":7", "hello", "7", arn, true},
+		{"arn:aws-cn:lambda:cn-north-1:123456789012:function:hello", "hello", "", "arn:aws-cn:lambda:cn-north-1:123456789012:function:hello", true},
+		{"hello world", "", "", "hello world", false},
+		{"hello:PROD:7", "", "", "hello:PROD:7", false},
+		{"arn:aws:lambda:eu-central-1:123:function:hello", "", "", "arn:aws:lambda:eu-central-1:123:function:hello", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bare, qualifier, ok := splitFunctionName(test.name)
+			if bare != test.bare || qualifier != test.qualifier || ok != test.ok {
+				t.Errorf("expected (%s, %s, %t), got (%s, %s, %t)", test.bare, test.qualifier, test.ok, bare, qualifier, ok)
+			}
+			if unqualified := (&FunctionConfig{Name: test.name}).getUnqualifiedName(); unqualified != test.unqualified {
+				t.Errorf("expected unqualified name %s, got %s", test.unqualified, unqualified)
+			}
+		})
+	}
+}
+
+func TestParseRejectsInvalidName(t *testing.T) {
+	_, err := parseTestConfig(t, "name: hello world\nfileName: main.go\n", "main.go", testMain)
+
+	expectConfigError(t, err, "name hello world must be a function name, name:qualifier or function ARN")
+}
+
+func TestDeployFunctionByArn(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: arn:aws:lambda:eu-central-1:123456789012:function:hello:PROD\nfileName: main.go\n")
+
+	results, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+	if results[0].Action != ActionUpdated || client.zipEntries(t, "hello")[0] != "hello" {
+		t.Errorf("expected the binary hello to be deployed, got %v", results)
+	}
+	if handler := aws.StringValue(client.function("hello").Handler); handler != "hello" {
+		t.Errorf("expected handler hello, got %s", handler)
+	}
+}
+
+func TestGetBuildNameIsSafeForPaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		buildName string
+	}{
+		{"hello", "hello"},
+		{"orders-api_v2:PROD", "orders-api_v2"},
+		{"arn:aws:lambda:eu-central-1:123456789012:function:hello:7", "hello"},
+		{"../../etc/passwd", "______etc_passwd"},
+		{"hello world", "hello_world"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := &FunctionConfig{Name: test.name, buildDir: "/tmp/build"}
+
+			if buildName := conf.getBuildName(); buildName != test.buildName {
+				t.Errorf("expected build name %s, got %s", test.buildName, buildName)
+			}
+			if output := conf.getZipOutputPath(); output != "/tmp/build/"+test.buildName+".zip" {
+				t.Errorf("expected the zip in the build directory, got %s", output)
+			}
+		})
+	}
+}
+
+func TestDecorateFunctionName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"hello", "dev-hello-prod"},
+		{"hello:PROD", "dev-hello-prod:PROD"},
+		{"arn:aws:lambda:eu-central-1:123456789012:function:hello:7", "arn:aws:lambda:eu-central-1:123456789012:function:dev-hello-prod:7"},
+	}
+	for _, test := range tests {
+		if decorated, err := decorateFunctionName(test.name, "dev-", "-prod"); err != nil || decorated != test.expected {
+			t.Errorf("expected %s to be decorated as %s, got %s, %v", test.name, test.expected, decorated, err)
+		}
+	}
+	if _, err := decorateFunctionName(strings.Repeat("a", 60), "dev-", "-prod"); err == nil || !strings.Contains(err.Error(), "at most 64 characters") {
+		t.Errorf("expected a too long decorated name to be rejected, got %v", err)
+	}
+}
+
+func TestDecorateNameDecoratesReferences(t *testing.T) {
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nnameSuffix: -canary\ndependsOn: [db]\nenvironment:\n  WORKER: fn-arn:worker\n  STAGE: prod\n")
+
+	if err := conf.DecorateName("dev-", "-prod"); err != nil {
+		t.Fatal(err)
+	}
+
+	if conf.Name != "dev-hello-canary" || conf.DependsOn[0] != "dev-db-canary" {
+		t.Errorf("expected the config suffix to replace the given one, got %s depending on %v", conf.Name, conf.DependsOn)
+	}
+	if conf.Environment["WORKER"] != "fn-arn:dev-worker-canary" || conf.Environment["STAGE"] != "prod" {
+		t.Errorf("expected only the fn-arn reference to be decorated, got %v", conf.Environment)
+	}
+}
+
+func TestDeployUsesDecoratedNameEverywhere(t *testing.T) {
+	client := newFakeLambda("dev-hello-prod")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nalias: live\n")
+	if err := conf.DecorateName("dev-", "-prod"); err != nil {
+		t.Fatal(err)
+	}
+
+	result := deployOne(t, conf, testOptions(client))
+
+	if result.Name != "dev-hello-prod" {
+		t.Errorf("expected the result of dev-hello-prod, got %s", result.Name)
+	}
+	for _, call := range client.calls {
+		if !strings.HasSuffix(call, " dev-hello-prod") && !strings.Contains(call, "dev-hello-prod:") && !strings.Contains(call, "function:dev-hello-prod") {
+			t.Errorf("expected every call to use the decorated name, got %s", call)
+		}
+	}
+	if entries := client.zipEntries(t, "dev-hello-prod"); len(entries) != 1 || entries[0] != "dev-hello-prod" {
+		t.Errorf("expected the binary to be named after the decorated name, got %v", entries)
+	}
+	if handler := aws.StringValue(client.functions["dev-hello-prod"].Handler); handler != "dev-hello-prod" {
+		t.Errorf("expected the handler dev-hello-prod, got %s", handler)
+	}
+}
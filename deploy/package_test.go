@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackageWritesZipWithoutAwsCalls(t *testing.T) {
+	client := newFakeLambda()
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nruntime: provided.al2023\n")
+	opts := testOptions(client)
+	opts.OutputDir = filepath.Join(t.TempDir(), "dist")
+
+	result := deployOne(t, conf, opts)
+
+	if result.Action != ActionPackaged {
+		t.Errorf("expected action %s, got %s", ActionPackaged, result.Action)
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected no AWS calls, got %v", client.calls)
+	}
+	reader, err := zip.OpenReader(filepath.Join(opts.OutputDir, "hello.zip"))
+	if err != nil {
+		t.Fatalf("expected the packaged zip, got %v", err)
+	}
+	defer reader.Close()
+	if len(reader.File) != 1 || reader.File[0].Name != "bootstrap" {
+		t.Errorf("expected the single entry bootstrap, got %v", reader.File)
+	}
+	if info, err := os.Stat(filepath.Join(opts.OutputDir, "hello.zip")); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("expected the packaged zip to be readable by everyone, got %v, %v", info, err)
+	}
+}
+
+func TestPackageRequiresDeclaredRuntime(t *testing.T) {
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	opts.OutputDir = t.TempDir()
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\n")}, opts)
+
+	if err == nil || !strings.Contains(err.Error(), "lambda function hello must declare its runtime to be packaged") {
+		t.Errorf("expected the runtime to be required, got %v", err)
+	}
+}
+
+func TestPackageSkipsImageFunctions(t *testing.T) {
+	client := newFakeLambda()
+	conf := newTestFunction(t, "name: hello\nimageUri: 123456789012.dkr.ecr.eu-central-1.amazonaws.com/hello:v2\npackageType: Image\n")
+	opts := testOptions(client)
+	opts.OutputDir = t.TempDir()
+
+	if result := deployOne(t, conf, opts); result.Action != ActionSkipped {
+		t.Errorf("expected the image function to be skipped, got %s", result.Action)
+	}
+}
+
+func TestPackageWithZipEntryNameNeedsNoRuntime(t *testing.T) {
+	client := newFakeLambda()
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nzipEntryName: bootstrap\n")
+	opts := testOptions(client)
+	opts.OutputDir = t.TempDir()
+
+	if result := deployOne(t, conf, opts); result.Action != ActionPackaged {
+		t.Errorf("expected action %s, got %s", ActionPackaged, result.Action)
+	}
+	if len(client.calls) != 0 {
+		t.Errorf("expected no AWS calls, got %v", client.calls)
+	}
+}
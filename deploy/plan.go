@@ -0,0 +1,284 @@
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrStalePlan is returned by ApplyPlan for functions whose config, live state or planned zip changed since the plan.
+var ErrStalePlan = errors.New("plan is stale")
+
+// Plan describes the changes CreatePlan computed for a set of functions.
+// It is applied with ApplyPlan, which executes exactly these changes or refuses if anything changed in between.
+type Plan struct {
+	CreatedAt time.Time `json:"createdAt"`
+	// ArtifactDir contains the zip of every planned function, ApplyPlan deploys these zips without rebuilding.
+	ArtifactDir string            `json:"artifactDir"`
+	Functions   []PlannedFunction `json:"functions"`
+}
+
+// PlannedFunction describes the planned changes of a single function.
+type PlannedFunction struct {
+	Name string `json:"name"`
+	// Region is the region declared by the config or its ARN, empty for the default region.
+	Region string `json:"region,omitempty"`
+	// ConfigSha256 is the hash of the config the plan was computed from.
+	ConfigSha256 string `json:"configSha256"`
+	// RevisionId is the revision of the live function at planning time.
+	RevisionId string `json:"revisionId"`
+	// LiveCodeSha256 and CodeSha256 are the base64 encoded hashes of the live and the planned code.
+	// CodeSha256 is empty for image based functions.
+	LiveCodeSha256 string `json:"liveCodeSha256"`
+	CodeSha256     string `json:"codeSha256,omitempty"`
+	// Changes lists the configuration fields that differ from the live function.
+	Changes []string `json:"changes"`
+}
+
+// CodeChanged reports whether the planned code differs from the live code.
+// Image based functions always deploy their image URI.
+func (planned *PlannedFunction) CodeChanged() bool {
+	return planned.CodeSha256 == "" || planned.CodeSha256 != planned.LiveCodeSha256
+}
+
+// HasChanges reports whether applying the plan changes the function at all.
+func (planned *PlannedFunction) HasChanges() bool {
+	return planned.CodeChanged() || len(planned.Changes) > 0
+}
+
+// ReadPlan reads a plan written with WritePlan.
+func ReadPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("malformed plan %s: %w", path, err)
+	}
+	return &plan, nil
+}
+
+// WritePlan writes the plan as JSON to path.
+func WritePlan(path string, plan *Plan) error {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// CreatePlan builds the zip of every function into artifactDir and compares the functions with their live state.
+// Nothing is deployed. Functions skipped by their deployIf guard are not part of the plan.
+func CreatePlan(ctx context.Context, configs []*FunctionConfig, opts Options, artifactDir string) (*Plan, error) {
+	artifactDir, err := filepath.Abs(artifactDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Zips of a previous plan must not be mistaken for functions that are skipped now
+	for _, conf := range configs {
+		if err := os.Remove(filepath.Join(artifactDir, conf.getPlanZipName())); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	buildOpts := opts
+	buildOpts.OutputDir = artifactDir
+	buildOpts.planning = true
+	if _, err := Deploy(ctx, configs, buildOpts); err != nil {
+		return nil, err
+	}
+
+	d, err := newDeployer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{CreatedAt: time.Now().UTC(), ArtifactDir: artifactDir, Functions: []PlannedFunction{}}
+	for _, conf := range configs {
+		zipPath := filepath.Join(artifactDir, conf.getPlanZipName())
+		if _, err := os.Stat(zipPath); conf.ImageUri == "" && os.IsNotExist(err) {
+			logrus.Infof("lambda function %s was skipped and is not part of the plan", conf.Name)
+			continue
+		}
+
+		planned, err := d.planFunction(ctx, conf, zipPath)
+		if err != nil {
+			return nil, fmt.Errorf("error while planning config at %s: %w", conf.Path, err)
+		}
+		plan.Functions = append(plan.Functions, *planned)
+	}
+	return plan, nil
+}
+
+// planFunction compares the function with its live state.
+func (d *deployer) planFunction(ctx context.Context, conf *FunctionConfig, zipPath string) (*PlannedFunction, error) {
+	fd, err := d.forFunction(conf)
+	if err != nil {
+		return nil, err
+	}
+	if err := fd.checkAccount(ctx, conf); err != nil {
+		return nil, err
+	}
+	if err := conf.resolveEnvironment(ctx, fd.resolver); err != nil {
+		return nil, err
+	}
+	if err := fd.checkCompatibility(ctx, conf); err != nil {
+		return nil, err
+	}
+	info, err := fd.getLiveConfig(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	_, changes, err := fd.planConfiguration(conf, info)
+	if err != nil {
+		return nil, err
+	}
+
+	planned := &PlannedFunction{
+		Name:           conf.Name,
+		Region:         conf.getNameRegion(),
+		ConfigSha256:   conf.sourceSha256,
+		RevisionId:     aws.StringValue(info.RevisionId),
+		LiveCodeSha256: aws.StringValue(info.CodeSha256),
+		Changes:        changes,
+	}
+	if planned.Changes == nil {
+		planned.Changes = []string{}
+	}
+	if conf.ImageUri == "" {
+		if planned.CodeSha256, err = fileCodeSha256(zipPath); err != nil {
+			return nil, err
+		}
+	}
+	return planned, nil
+}
+
+// ApplyPlan deploys the planned functions with the zips of the plan, nothing is rebuilt.
+// Functions without changes are skipped. A function fails with ErrStalePlan if its config,
+// its live revision, its planned zip or its configuration changes differ from the plan.
+// Configs that are not part of the plan are skipped.
+func ApplyPlan(ctx context.Context, configs []*FunctionConfig, plan *Plan, opts Options) ([]Result, error) {
+	// Functions of the same name in different regions are planned separately
+	planned := map[string]*PlannedFunction{}
+	for i := range plan.Functions {
+		planned[plan.Functions[i].Region+"/"+plan.Functions[i].Name] = &plan.Functions[i]
+	}
+
+	var selected []*FunctionConfig
+	var skipped []Result
+	for _, conf := range configs {
+		key := conf.getNameRegion() + "/" + conf.Name
+		function, ok := planned[key]
+		if !ok {
+			logrus.Warnf("lambda function %s is not part of the plan, skipping it", conf.Name)
+			skipped = append(skipped, Result{Name: conf.Name, Action: ActionSkipped})
+			continue
+		}
+		delete(planned, key)
+		conf.plan = function
+		conf.plannedZip = filepath.Join(plan.ArtifactDir, conf.getPlanZipName())
+		// The live state cached while planning must not hide changes made since
+		conf.liveConfig = nil
+		selected = append(selected, conf)
+	}
+	if len(planned) > 0 {
+		missing := make([]string, 0, len(planned))
+		for _, function := range planned {
+			missing = append(missing, function.Name)
+		}
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: the configs of the planned lambda functions %s were not found", ErrStalePlan, strings.Join(missing, ", "))
+	}
+
+	results, err := Deploy(ctx, selected, opts)
+	return append(results, skipped...), err
+}
+
+// checkPlan returns ErrStalePlan if the function changed since its plan was created.
+func (d *deployer) checkPlan(ctx context.Context, conf *FunctionConfig) error {
+	if conf.sourceSha256 != conf.plan.ConfigSha256 {
+		return fmt.Errorf("%w: the config changed since the plan", ErrStalePlan)
+	}
+	info, err := d.getLiveConfig(ctx, conf)
+	if err != nil {
+		return err
+	}
+	if revision := aws.StringValue(info.RevisionId); revision != conf.plan.RevisionId {
+		return fmt.Errorf("%w: the live function is at revision %s, the plan was created at %s", ErrStalePlan, revision, conf.plan.RevisionId)
+	}
+	if conf.ImageUri == "" {
+		codeSha256, err := fileCodeSha256(conf.plannedZip)
+		if err != nil {
+			return err
+		}
+		if codeSha256 != conf.plan.CodeSha256 {
+			return fmt.Errorf("%w: the planned zip %s was modified", ErrStalePlan, conf.plannedZip)
+		}
+	}
+	_, changes, err := d.planConfiguration(conf, info)
+	if err != nil {
+		return err
+	}
+	if strings.Join(changes, ", ") != strings.Join(conf.plan.Changes, ", ") {
+		return fmt.Errorf("%w: the configuration changes are %s, the plan has %s", ErrStalePlan,
+			strings.Join(changes, ", "), strings.Join(conf.plan.Changes, ", "))
+	}
+	return nil
+}
+
+// copyPlannedZip copies the planned zip to the zip output path, so it is deployed like a freshly built one.
+func (conf *FunctionConfig) copyPlannedZip() error {
+	source, err := os.Open(conf.plannedZip)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.Create(conf.getZipOutputPath())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
+
+// fileCodeSha256 returns the SHA-256 of the file at path in the base64 encoding Lambda reports as CodeSha256.
+func fileCodeSha256(path string) (string, error) {
+	sum, err := fileSha256(path)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sum), nil
+}
+
+// fileSha256 returns the SHA-256 of the file at path.
+func fileSha256(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,181 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// createTestPlan plans the function against the fake client and fails the test on errors.
+func createTestPlan(t *testing.T, conf *FunctionConfig, client *fakeLambda) *Plan {
+	t.Helper()
+	client.functions["hello"].RevisionId = aws.String("revision-1")
+	plan, err := CreatePlan(context.Background(), []*FunctionConfig{conf}, testOptions(client), t.TempDir())
+	if err != nil {
+		t.Fatalf("error while planning: %v", err)
+	}
+	if len(plan.Functions) != 1 {
+		t.Fatalf("expected 1 planned function, got %d", len(plan.Functions))
+	}
+	return plan
+}
+
+func TestApplyExecutesPlannedChanges(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nmemorySize: 512\n")
+
+	plan := createTestPlan(t, conf, client)
+
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected planning to make no changes, got %v", mutations)
+	}
+	planned := plan.Functions[0]
+	if planned.Name != "hello" || planned.RevisionId != "revision-1" || !planned.CodeChanged() {
+		t.Errorf("expected a code change of hello at revision-1, got %+v", planned)
+	}
+	if !reflect.DeepEqual(planned.Changes, []string{"memory size"}) {
+		t.Errorf("expected the memory size change, got %v", planned.Changes)
+	}
+
+	results, err := ApplyPlan(context.Background(), []*FunctionConfig{conf}, plan, testOptions(client))
+
+	if err != nil {
+		t.Fatalf("error while applying: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ActionUpdated {
+		t.Fatalf("expected hello to be updated, got %+v", results)
+	}
+	if live := aws.StringValue(client.functions["hello"].CodeSha256); live != planned.CodeSha256 {
+		t.Errorf("expected the planned code %s to be deployed, got %s", planned.CodeSha256, live)
+	}
+	if memory := aws.Int64Value(client.functions["hello"].MemorySize); memory != 512 {
+		t.Errorf("expected memory size 512, got %d", memory)
+	}
+}
+
+func TestApplyRefusesStalePlan(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(t *testing.T, conf *FunctionConfig, client *fakeLambda, plan *Plan)
+	}{
+		{"live revision", func(t *testing.T, conf *FunctionConfig, client *fakeLambda, plan *Plan) {
+			client.functions["hello"].RevisionId = aws.String("revision-2")
+		}},
+		{"planned zip", func(t *testing.T, conf *FunctionConfig, client *fakeLambda, plan *Plan) {
+			if err := ioutil.WriteFile(filepath.Join(plan.ArtifactDir, conf.getBuildName()+".zip"), []byte("tampered"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"config", func(t *testing.T, conf *FunctionConfig, client *fakeLambda, plan *Plan) {
+			conf.sourceSha256 = "changed"
+		}},
+		{"configuration changes", func(t *testing.T, conf *FunctionConfig, client *fakeLambda, plan *Plan) {
+			client.functions["hello"].MemorySize = aws.Int64(512)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeLambda("hello")
+			conf := newTestFunction(t, "name: hello\nfileName: main.go\nmemorySize: 512\n")
+			plan := createTestPlan(t, conf, client)
+			test.change(t, conf, client, plan)
+
+			_, err := ApplyPlan(context.Background(), []*FunctionConfig{conf}, plan, testOptions(client))
+
+			if !errors.Is(err, ErrStalePlan) {
+				t.Errorf("expected a stale plan error, got %v", err)
+			}
+			if mutations := client.mutations(); len(mutations) != 0 {
+				t.Errorf("expected no changes, got %v", mutations)
+			}
+		})
+	}
+}
+
+func TestApplyRefusesPlanWithMissingConfigs(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	plan := createTestPlan(t, conf, client)
+
+	_, err := ApplyPlan(context.Background(), nil, plan, testOptions(client))
+
+	if !errors.Is(err, ErrStalePlan) || err.Error() != "plan is stale: the configs of the planned lambda functions hello were not found" {
+		t.Errorf("expected a stale plan error for hello, got %v", err)
+	}
+}
+
+func TestApplySkipsFunctionsWithoutChanges(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\n")
+	plan := createTestPlan(t, conf, client)
+	client.functions["hello"].CodeSha256 = aws.String(plan.Functions[0].CodeSha256)
+	plan.Functions[0].LiveCodeSha256 = plan.Functions[0].CodeSha256
+
+	results, err := ApplyPlan(context.Background(), []*FunctionConfig{conf}, plan, testOptions(client))
+
+	if err != nil || len(results) != 1 || results[0].Action != ActionSkipped {
+		t.Errorf("expected hello to be skipped, got %+v, %v", results, err)
+	}
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected no changes, got %v", mutations)
+	}
+}
+
+func TestReadPlanReadsWrittenPlan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	plan := &Plan{ArtifactDir: "/tmp/plan", Functions: []PlannedFunction{{Name: "hello", CodeSha256: "abc", Changes: []string{"timeout"}}}}
+	if err := WritePlan(path, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadPlan(path)
+
+	if err != nil || !reflect.DeepEqual(read, plan) {
+		t.Errorf("expected the written plan, got %+v, %v", read, err)
+	}
+	if err := ioutil.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadPlan(path); err == nil {
+		t.Error("expected an error for a malformed plan")
+	}
+}
+
+func TestPlanKeepsZipsOfFunctionsInDifferentRegionsApart(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].RevisionId = aws.String("revision-1")
+	configs := []*FunctionConfig{
+		newTestFunction(t, "name: hello\nfileName: main.go\n", "main.go", "package main\n\nfunc main() { println(\"eu\") }\n"),
+		newTestFunction(t, "name: hello\nfileName: main.go\nregion: us-east-1\n", "main.go", "package main\n\nfunc main() { println(\"us\") }\n"),
+	}
+	artifactDir := t.TempDir()
+
+	plan, err := CreatePlan(context.Background(), configs, testOptions(client), artifactDir)
+	if err != nil {
+		t.Fatalf("error while planning: %v", err)
+	}
+
+	if len(plan.Functions) != 2 || plan.Functions[0].Region != "" || plan.Functions[1].Region != "us-east-1" {
+		t.Fatalf("expected hello in the default region and in us-east-1, got %+v", plan.Functions)
+	}
+	if plan.Functions[0].CodeSha256 == plan.Functions[1].CodeSha256 {
+		t.Error("expected each function to be planned with its own zip")
+	}
+	for _, name := range []string{"hello.zip", "hello.us-east-1.zip"} {
+		if _, err := ioutil.ReadFile(filepath.Join(artifactDir, name)); err != nil {
+			t.Errorf("expected the planned zip %s, got %v", name, err)
+		}
+	}
+
+	results, err := ApplyPlan(context.Background(), configs, plan, testOptions(client))
+	if err != nil {
+		t.Fatalf("error while applying: %v", err)
+	}
+	if len(results) != 2 || results[0].CodeSha256 != plan.Functions[0].CodeSha256 || results[1].CodeSha256 != plan.Functions[1].CodeSha256 {
+		t.Errorf("expected each function to be deployed with its planned zip, got %+v", results)
+	}
+}
@@ -0,0 +1,93 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// buildReportName is the name of the build duration report in Options.BuildProfileDir.
+const buildReportName = "build-report.txt"
+
+// buildProfile sums up the build durations of every function and passes all durations on to the next recorder.
+type buildProfile struct {
+	next      MetricsRecorder
+	mutex     sync.Mutex
+	durations map[string]time.Duration
+}
+
+// newBuildProfile creates a buildProfile passing all durations on to next.
+func newBuildProfile(next MetricsRecorder) *buildProfile {
+	return &buildProfile{next: next, durations: map[string]time.Duration{}}
+}
+
+// RecordDuration adds build durations to the profile and passes every duration on.
+func (p *buildProfile) RecordDuration(function string, step string, duration time.Duration) {
+	if step == StepBuild {
+		p.mutex.Lock()
+		p.durations[function] += duration
+		p.mutex.Unlock()
+	}
+	p.next.RecordDuration(function, step, duration)
+}
+
+// writeReport writes the build durations of all functions to the report in dir, the slowest build first.
+func (p *buildProfile) writeReport(dir string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	functions := make([]string, 0, len(p.durations))
+	var total time.Duration
+	for function, duration := range p.durations {
+		functions = append(functions, function)
+		total += duration
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if p.durations[functions[i]] != p.durations[functions[j]] {
+			return p.durations[functions[i]] > p.durations[functions[j]]
+		}
+		return functions[i] < functions[j]
+	})
+
+	file, err := os.Create(filepath.Join(dir, buildReportName))
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(file, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "FUNCTION\tBUILD\tTRACE")
+	for _, function := range functions {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", function, p.durations[function].Round(time.Millisecond), getBuildTraceName(function))
+	}
+	fmt.Fprintf(writer, "total\t%s\n", total.Round(time.Millisecond))
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// getBuildTraceName returns the name of the go build -x trace of the function in Options.BuildProfileDir.
+func getBuildTraceName(function string) string {
+	return buildNameUnsafe.ReplaceAllString(function, "_") + ".trace"
+}
+
+// openBuildTrace creates the trace file of the function in dir, every go build of the function writes its -x output to it.
+func (conf *FunctionConfig) openBuildTrace(dir string) error {
+	file, err := os.Create(filepath.Join(dir, getBuildTraceName(conf.getFunctionName())))
+	if err != nil {
+		return err
+	}
+	conf.buildTrace = file
+	return nil
+}
+
+// closeBuildTrace closes the trace file of the function.
+func (conf *FunctionConfig) closeBuildTrace() error {
+	err := conf.buildTrace.Close()
+	conf.buildTrace = nil
+	return err
+}
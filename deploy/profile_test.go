@@ -0,0 +1,69 @@
+package deploy
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeployWritesBuildProfile(t *testing.T) {
+	client := newFakeLambda("alpha", "beta")
+	configs := []*FunctionConfig{
+		newTestFunction(t, "name: alpha\nfileName: main.go\n"),
+		newTestFunction(t, "name: beta\nfileName: main.go\n"),
+	}
+	opts := testOptions(client)
+	opts.BuildProfileDir = filepath.Join(t.TempDir(), "profile")
+
+	if _, err := Deploy(context.Background(), configs, opts); err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+
+	report, err := ioutil.ReadFile(filepath.Join(opts.BuildProfileDir, buildReportName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "FUNCTION") || !strings.HasPrefix(lines[3], "total") {
+		t.Fatalf("expected a header, a line per function and the total, got\n%s", report)
+	}
+	for _, name := range []string{"alpha", "beta"} {
+		if !strings.Contains(string(report), name+".trace") {
+			t.Errorf("expected the report to list %s, got\n%s", name, report)
+		}
+		trace, err := ioutil.ReadFile(filepath.Join(opts.BuildProfileDir, name+".trace"))
+		if err != nil || !strings.Contains(string(trace), "WORK=") {
+			t.Errorf("expected the go build -x output of %s, got %q, %v", name, trace, err)
+		}
+	}
+}
+
+func TestBuildProfileReportsSlowestBuildFirst(t *testing.T) {
+	recorder := &stepRecorder{}
+	profile := newBuildProfile(recorder)
+	profile.RecordDuration("fast", StepBuild, 100*time.Millisecond)
+	profile.RecordDuration("slow", StepBuild, 2*time.Second)
+	profile.RecordDuration("slow", StepBuild, 500*time.Millisecond)
+	profile.RecordDuration("fast", StepUpload, time.Minute)
+	dir := t.TempDir()
+
+	if err := profile.writeReport(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	report, _ := ioutil.ReadFile(filepath.Join(dir, buildReportName))
+	expected := "FUNCTION  BUILD  TRACE\n" +
+		"slow      2.5s   slow.trace\n" +
+		"fast      100ms  fast.trace\n" +
+		"total     2.6s\n"
+	if string(report) != expected {
+		t.Errorf("expected report\n%s\ngot\n%s", expected, report)
+	}
+	if !reflect.DeepEqual(recorder.steps, map[string][]string{"fast": {StepBuild, StepUpload}, "slow": {StepBuild, StepBuild}}) {
+		t.Errorf("expected all durations to be passed on, got %v", recorder.steps)
+	}
+}
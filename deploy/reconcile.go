@@ -0,0 +1,118 @@
+package deploy
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"strings"
+)
+
+// reconcileConfiguration adds every managed field that differs from the live configuration to input.
+// Fields that are not declared in the config are left untouched.
+// Returns the names of the changed fields, input must only be sent if any field changed.
+func (conf *FunctionConfig) reconcileConfiguration(info *lambda.FunctionConfiguration, input *lambda.UpdateFunctionConfigurationInput) []string {
+	var changes []string
+
+	if conf.MemorySize != 0 && conf.MemorySize != aws.Int64Value(info.MemorySize) {
+		input.MemorySize = &conf.MemorySize
+		changes = append(changes, "memory size")
+	}
+	if conf.Timeout != 0 && conf.Timeout != aws.Int64Value(info.Timeout) {
+		input.Timeout = &conf.Timeout
+		changes = append(changes, "timeout")
+	}
+	if layers := conf.getLayers(info.Layers); layers != nil && !equalLayers(layers, info.Layers) {
+		input.Layers = aws.StringSlice(layers)
+		changes = append(changes, "layers")
+	}
+	if conf.resolvedEnvironment != nil && !equalEnvironment(conf.resolvedEnvironment, info.Environment) {
+		input.Environment = &lambda.Environment{Variables: aws.StringMap(conf.resolvedEnvironment)}
+		changes = append(changes, "environment")
+	}
+	if conf.LoggingConfig != nil && !conf.LoggingConfig.matches(info.LoggingConfig) {
+		input.LoggingConfig = conf.LoggingConfig.toLambda()
+		changes = append(changes, "logging config")
+	}
+
+	return changes
+}
+
+// getLayers returns the layer ARNs the function should have, nil if the layers aren't managed.
+// With LayersModeMerge the live layers are kept in their order, a declared version of a live layer replaces
+// the live version in place and the other declared layers are appended.
+func (conf *FunctionConfig) getLayers(live []*lambda.Layer) []string {
+	if conf.Layers == nil || conf.LayersMode != LayersModeMerge {
+		return conf.Layers
+	}
+
+	declared := map[string]string{}
+	for _, layer := range conf.Layers {
+		declared[getLayerName(layer)] = layer
+	}
+	layers := []string{}
+	merged := map[string]bool{}
+	for _, layer := range live {
+		name := getLayerName(aws.StringValue(layer.Arn))
+		if arn, ok := declared[name]; ok {
+			layers = append(layers, arn)
+			merged[name] = true
+		} else {
+			layers = append(layers, aws.StringValue(layer.Arn))
+		}
+	}
+	for _, layer := range conf.Layers {
+		if !merged[getLayerName(layer)] {
+			layers = append(layers, layer)
+		}
+	}
+	return layers
+}
+
+// getLayerName returns the layer version ARN without its version, which identifies the layer.
+func getLayerName(arn string) string {
+	if index := strings.LastIndex(arn, ":"); index >= 0 && strings.Contains(arn, ":layer:") {
+		return arn[:index]
+	}
+	return arn
+}
+
+// equalLayers reports whether the live layers are exactly the given layer ARNs in the same order.
+func equalLayers(layers []string, live []*lambda.Layer) bool {
+	if len(layers) != len(live) {
+		return false
+	}
+	for i, layer := range live {
+		if aws.StringValue(layer.Arn) != layers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equalEnvironment reports whether the live environment holds exactly the given variables.
+func equalEnvironment(variables map[string]string, live *lambda.EnvironmentResponse) bool {
+	var liveVariables map[string]*string
+	if live != nil {
+		liveVariables = live.Variables
+	}
+	if len(variables) != len(liveVariables) {
+		return false
+	}
+	for key, value := range variables {
+		liveValue, ok := liveVariables[key]
+		if !ok || aws.StringValue(liveValue) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether every declared field of the LoggingConfig equals the live logging config.
+func (conf *LoggingConfig) matches(live *lambda.LoggingConfig) bool {
+	if live == nil {
+		live = &lambda.LoggingConfig{}
+	}
+	return (conf.LogFormat == "" || conf.LogFormat == aws.StringValue(live.LogFormat)) &&
+		(conf.ApplicationLogLevel == "" || conf.ApplicationLogLevel == aws.StringValue(live.ApplicationLogLevel)) &&
+		(conf.SystemLogLevel == "" || conf.SystemLogLevel == aws.StringValue(live.SystemLogLevel)) &&
+		(conf.LogGroup == "" || conf.LogGroup == aws.StringValue(live.LogGroup))
+}
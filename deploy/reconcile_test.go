@@ -0,0 +1,129 @@
+package deploy
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"reflect"
+	"testing"
+)
+
+func TestDeployAppliesLoggingConfig(t *testing.T) {
+	client := newFakeLambda("hello")
+	config := "name: hello\nfileName: main.go\nloggingConfig:\n  logFormat: JSON\n  applicationLogLevel: DEBUG\n  logGroup: /custom/hello\n"
+
+	deployOne(t, newTestFunction(t, config), testOptions(client))
+
+	live := client.function("hello").LoggingConfig
+	if aws.StringValue(live.LogFormat) != "JSON" || aws.StringValue(live.ApplicationLogLevel) != "DEBUG" || aws.StringValue(live.LogGroup) != "/custom/hello" {
+		t.Errorf("expected the declared logging config, got %v", live)
+	}
+	if live.SystemLogLevel != nil {
+		t.Errorf("expected the undeclared system log level to be left alone, got %s", aws.StringValue(live.SystemLogLevel))
+	}
+
+	deployOne(t, newTestFunction(t, config), testOptions(client))
+
+	if count := client.count("UpdateFunctionConfiguration"); count != 1 {
+		t.Errorf("expected only the first deploy to update the logging config, got %d updates", count)
+	}
+}
+
+func TestParseRejectsLogLevelsWithoutJSON(t *testing.T) {
+	_, err := parseTestConfig(t, "name: hello\nfileName: main.go\nloggingConfig:\n  logFormat: Text\n  systemLogLevel: WARN\n", "main.go", testMain)
+
+	expectConfigError(t, err, "loggingConfig log levels require logFormat JSON")
+}
+
+func TestDeployReconcilesConfiguration(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].MemorySize = aws.Int64(128)
+	client.functions["hello"].Timeout = aws.Int64(3)
+	client.functions["hello"].Environment = &lambda.EnvironmentResponse{Variables: aws.StringMap(map[string]string{"STAGE": "dev", "OLD": "1"})}
+	layer := "arn:aws:lambda:eu-central-1:123456789012:layer:otel:4"
+	config := "name: hello\nfileName: main.go\nmemorySize: 512\ntimeout: 30\nenvironment:\n  STAGE: prod\nlayers:\n  - " + layer + "\n"
+
+	deployOne(t, newTestFunction(t, config), testOptions(client))
+
+	live := client.function("hello")
+	if aws.Int64Value(live.MemorySize) != 512 || aws.Int64Value(live.Timeout) != 30 {
+		t.Errorf("expected memory size 512 and timeout 30, got %d and %d", aws.Int64Value(live.MemorySize), aws.Int64Value(live.Timeout))
+	}
+	if variables := aws.StringValueMap(live.Environment.Variables); !reflect.DeepEqual(variables, map[string]string{"STAGE": "prod"}) {
+		t.Errorf("expected the environment to be replaced, got %v", variables)
+	}
+	if len(live.Layers) != 1 || aws.StringValue(live.Layers[0].Arn) != layer {
+		t.Errorf("expected the layer %s, got %v", layer, live.Layers)
+	}
+
+	deployOne(t, newTestFunction(t, config), testOptions(client))
+
+	if count := client.count("UpdateFunctionConfiguration"); count != 1 {
+		t.Errorf("expected only the first deploy to update the configuration, got %d updates", count)
+	}
+}
+
+func TestReconcileLeavesUndeclaredFields(t *testing.T) {
+	conf := &FunctionConfig{Timeout: 30}
+	info := &lambda.FunctionConfiguration{MemorySize: aws.Int64(1024), Timeout: aws.Int64(3)}
+	input := &lambda.UpdateFunctionConfigurationInput{}
+
+	changes := conf.reconcileConfiguration(info, input)
+
+	if !reflect.DeepEqual(changes, []string{"timeout"}) {
+		t.Errorf("expected only the timeout to change, got %v", changes)
+	}
+	if input.MemorySize != nil || input.Environment != nil || input.Layers != nil || input.LoggingConfig != nil {
+		t.Errorf("expected the undeclared fields to be left alone, got %v", input)
+	}
+}
+
+// layerArn returns the ARN of version of the test layer name.
+func layerArn(name string, version string) string {
+	return "arn:aws:lambda:eu-central-1:123456789012:layer:" + name + ":" + version
+}
+
+func TestDeployAppliesLayersMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected []string
+	}{
+		{"replace by default", "layers:\n  - " + layerArn("otel", "5") + "\n  - " + layerArn("extra", "1") + "\n", []string{layerArn("otel", "5"), layerArn("extra", "1")}},
+		{"replace", "layersMode: replace\nlayers:\n  - " + layerArn("extra", "1") + "\n", []string{layerArn("extra", "1")}},
+		{"replace with no layers", "layersMode: replace\nlayers: []\n", nil},
+		{"merge", "layersMode: merge\nlayers:\n  - " + layerArn("extra", "1") + "\n  - " + layerArn("otel", "5") + "\n", []string{layerArn("otel", "5"), layerArn("shared", "2"), layerArn("extra", "1")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeLambda("hello")
+			client.functions["hello"].Layers = []*lambda.Layer{{Arn: aws.String(layerArn("otel", "4"))}, {Arn: aws.String(layerArn("shared", "2"))}}
+
+			deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"+test.config), testOptions(client))
+
+			var layers []string
+			for _, layer := range client.function("hello").Layers {
+				layers = append(layers, aws.StringValue(layer.Arn))
+			}
+			if !reflect.DeepEqual(layers, test.expected) {
+				t.Errorf("expected the layers %v, got %v", test.expected, layers)
+			}
+		})
+	}
+}
+
+func TestDeployMergesLiveLayersWithoutUpdate(t *testing.T) {
+	client := newFakeLambda("hello")
+	client.functions["hello"].Layers = []*lambda.Layer{{Arn: aws.String(layerArn("otel", "4"))}, {Arn: aws.String(layerArn("shared", "2"))}}
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\nlayersMode: merge\nlayers:\n  - "+layerArn("shared", "2")+"\n"), testOptions(client))
+
+	if count := client.count("UpdateFunctionConfiguration"); count != 0 {
+		t.Errorf("expected the merged layers to match the live layers, got %d updates", count)
+	}
+}
+
+func TestParseRejectsUnknownLayersMode(t *testing.T) {
+	_, err := parseTestConfig(t, "name: hello\nfileName: main.go\nlayersMode: append\nlayers: []\n", "main.go", testMain)
+
+	expectConfigError(t, err, "layersMode")
+}
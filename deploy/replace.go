@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// replaceModFileName is the name of the temporary copy of go.mod the Replace directives are added to.
+const replaceModFileName = "replace.mod"
+
+// validateReplace checks that every Replace entry has a module path and a replacement.
+func (conf *FunctionConfig) validateReplace() error {
+	for module, replacement := range conf.Replace {
+		if module == "" || replacement == "" {
+			return fmt.Errorf("replace entry %q: %q needs a module path and a replacement", module, replacement)
+		}
+	}
+	if len(conf.Replace) > 0 && strings.Contains(conf.GoEnv["GOFLAGS"], "-modfile") {
+		return errors.New("replace can't be used with -modfile in goEnv GOFLAGS")
+	}
+	return nil
+}
+
+// getReplaceModFilePath returns the path of the temporary go.mod with the Replace directives.
+func (conf *FunctionConfig) getReplaceModFilePath() string {
+	return filepath.Join(conf.buildDir, replaceModFileName)
+}
+
+// createReplaceModFile copies the go.mod and go.sum of the module the function is built in to the build directory
+// and adds the Replace directives to the copy. The builds use the copy through -modfile, the module itself is never edited.
+// Relative replacements are resolved against the function directory.
+func (conf *FunctionConfig) createReplaceModFile(ctx context.Context) error {
+	gomod, err := conf.goEnv(ctx, "GOMOD")
+	if err != nil {
+		return err
+	}
+	if gomod == "" || gomod == os.DevNull {
+		return errors.New("replace requires the function to be inside a Go module")
+	}
+
+	modFile := conf.getReplaceModFilePath()
+	if err := copyFile(gomod, modFile); err != nil {
+		return err
+	}
+	sumFile := strings.TrimSuffix(modFile, ".mod") + ".sum"
+	if err := copyFile(strings.TrimSuffix(gomod, ".mod")+".sum", sumFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	modules := make([]string, 0, len(conf.Replace))
+	for module := range conf.Replace {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	args := []string{"mod", "edit", "-modfile=" + modFile}
+	for _, module := range modules {
+		replacement := conf.Replace[module]
+		if strings.HasPrefix(replacement, "./") || strings.HasPrefix(replacement, "../") {
+			replacement = filepath.Join(conf.Path, replacement)
+		}
+		args = append(args, fmt.Sprintf("-replace=%s=%s", module, replacement))
+	}
+
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = conf.getSourceDir()
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	conf.replaceModFile = modFile
+	return nil
+}
+
+// copyFile copies the file at source to target.
+func copyFile(source string, target string) error {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(target, data, 0644)
+}
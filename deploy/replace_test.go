@@ -0,0 +1,85 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newReplacedFunction writes a function in a module whose go.mod replaces example.com/lib with lib-v1,
+// lib-v2 is a second copy of the library. It returns the directory of the module and the parsed config.
+func newReplacedFunction(t *testing.T, config string) (string, *FunctionConfig) {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir,
+		"lib-v1/go.mod", "module example.com/lib\n\ngo 1.19\n",
+		"lib-v1/lib.go", "package lib\n\nvar Marker = \"library-marker-v1\"\n",
+		"lib-v2/go.mod", "module example.com/lib\n\ngo 1.19\n",
+		"lib-v2/lib.go", "package lib\n\nvar Marker = \"library-marker-v2\"\n",
+		"app/go.mod", "module example.com/app\n\ngo 1.19\n\nrequire example.com/lib v0.0.0\n\nreplace example.com/lib => ../lib-v1\n",
+		"app/hello/main.go", "package main\n\nimport \"example.com/lib\"\n\nfunc main() { println(lib.Marker) }\n")
+	conf, err := ParseFunctionConfigFromReader(strings.NewReader(config), filepath.Join(dir, "app", "hello"))
+	if err != nil {
+		t.Fatalf("error while parsing config: %v", err)
+	}
+	return dir, conf
+}
+
+func TestDeployHonorsReplaceOfModule(t *testing.T) {
+	client := newFakeLambda("hello")
+	_, conf := newReplacedFunction(t, "name: hello\nfileName: main.go\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if binary := client.zipFile(t, "hello", "hello"); !bytes.Contains(binary, []byte("library-marker-v1")) {
+		t.Error("expected the binary to be built with the replacement of go.mod")
+	}
+}
+
+func TestDeployBuildsWithReplaceOfConfig(t *testing.T) {
+	client := newFakeLambda("hello")
+	dir, conf := newReplacedFunction(t, "name: hello\nfileName: main.go\nreplace:\n  example.com/lib: ../../lib-v2\n")
+	gomod, _ := ioutil.ReadFile(filepath.Join(dir, "app", "go.mod"))
+
+	deployOne(t, conf, testOptions(client))
+
+	binary := client.zipFile(t, "hello", "hello")
+	if !bytes.Contains(binary, []byte("library-marker-v2")) || bytes.Contains(binary, []byte("library-marker-v1")) {
+		t.Error("expected the binary to be built with the replacement of the config")
+	}
+	if after, _ := ioutil.ReadFile(filepath.Join(dir, "app", "go.mod")); !bytes.Equal(after, gomod) {
+		t.Errorf("expected go.mod to be left untouched, got\n%s", after)
+	}
+}
+
+func TestDeployRejectsReplaceOutsideModule(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nreplace:\n  example.com/lib: ../lib\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "replace requires the function to be inside a Go module") {
+		t.Errorf("expected replace to require a module, got %v", err)
+	}
+}
+
+func TestValidateReplace(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    FunctionConfig
+		message string
+	}{
+		{"empty replacement", FunctionConfig{Replace: map[string]string{"example.com/lib": ""}}, `replace entry "example.com/lib": "" needs a module path and a replacement`},
+		{"modfile", FunctionConfig{Replace: map[string]string{"example.com/lib": "../lib"}, GoEnv: map[string]string{"GOFLAGS": "-modfile=dev.mod"}}, "replace can't be used with -modfile in goEnv GOFLAGS"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.conf.validateReplace(); err == nil || err.Error() != test.message {
+				t.Errorf("expected %q, got %v", test.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Prefixes of environment variable values that are resolved at deploy time.
+const (
+	ssmPrefix            = "ssm:"
+	secretsManagerPrefix = "secretsmanager:"
+	functionArnPrefix    = "fn-arn:"
+)
+
+// valueResolver resolves references to SSM parameters, Secrets Manager secrets and the ARNs of other functions.
+// Resolved values are cached, so each reference is only fetched once per Deploy and scope.
+type valueResolver struct {
+	ssm            ssmiface.SSMAPI
+	secretsManager secretsmanageriface.SecretsManagerAPI
+	lambda         lambdaiface.LambdaAPI
+
+	// scope identifies the region and credentials of the clients, the same reference can resolve differently per scope.
+	scope string
+	cache *resolvedValues
+}
+
+// resolvedValues caches the resolved references of all resolvers of a Deploy, keyed by scope and reference.
+type resolvedValues struct {
+	mutex  sync.Mutex
+	values map[string]string
+}
+
+// resolve returns the value for the given environment variable value.
+// Values without a known prefix are returned unchanged.
+func (r *valueResolver) resolve(ctx context.Context, value string) (string, error) {
+	if !strings.HasPrefix(value, ssmPrefix) && !strings.HasPrefix(value, secretsManagerPrefix) && !strings.HasPrefix(value, functionArnPrefix) {
+		return value, nil
+	}
+
+	key := r.scope + "\x00" + value
+	r.cache.mutex.Lock()
+	cached, ok := r.cache.values[key]
+	r.cache.mutex.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	var resolved string
+	var err error
+	switch {
+	case strings.HasPrefix(value, ssmPrefix):
+		resolved, err = r.resolveParameter(ctx, strings.TrimPrefix(value, ssmPrefix))
+	case strings.HasPrefix(value, secretsManagerPrefix):
+		resolved, err = r.resolveSecret(ctx, strings.TrimPrefix(value, secretsManagerPrefix))
+	default:
+		resolved, err = r.resolveFunctionArn(ctx, strings.TrimPrefix(value, functionArnPrefix))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error while resolving %s: %w", value, err)
+	}
+
+	r.cache.mutex.Lock()
+	r.cache.values[key] = resolved
+	r.cache.mutex.Unlock()
+	return resolved, nil
+}
+
+// resolveParameter fetches the decrypted value of the SSM parameter with the given name.
+func (r *valueResolver) resolveParameter(ctx context.Context, name string) (string, error) {
+	output, err := r.ssm.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           &name,
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.Parameter.Value), nil
+}
+
+// resolveSecret fetches the string value of the secret with the given ARN or name.
+func (r *valueResolver) resolveSecret(ctx context.Context, id string) (string, error) {
+	output, err := r.secretsManager.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &id,
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no string value", id)
+	}
+	return *output.SecretString, nil
+}
+
+// resolveFunctionArn fetches the ARN of the function with the given name.
+func (r *valueResolver) resolveFunctionArn(ctx context.Context, name string) (string, error) {
+	output, err := r.lambda.GetFunctionWithContext(ctx, &lambda.GetFunctionInput{
+		FunctionName: &name,
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.Configuration.FunctionArn), nil
+}
+
+// getFunctionArnReferences returns the names of the functions whose ARN is resolved into the environment.
+func (conf *FunctionConfig) getFunctionArnReferences() []string {
+	var names []string
+	for _, value := range conf.Environment {
+		if strings.HasPrefix(value, functionArnPrefix) {
+			names = append(names, strings.TrimPrefix(value, functionArnPrefix))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveEnvironment resolves all references in the environment of the FunctionConfig.
+func (conf *FunctionConfig) resolveEnvironment(ctx context.Context, resolver *valueResolver) error {
+	if conf.Environment == nil {
+		return nil
+	}
+
+	conf.resolvedEnvironment = make(map[string]string, len(conf.Environment))
+	for key, value := range conf.Environment {
+		resolved, err := resolver.resolve(ctx, value)
+		if err != nil {
+			return fmt.Errorf("environment variable %s: %w", key, err)
+		}
+		conf.resolvedEnvironment[key] = resolved
+	}
+	return nil
+}
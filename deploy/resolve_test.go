@@ -0,0 +1,94 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"strings"
+	"testing"
+)
+
+func TestDeployResolvesEnvironmentReferences(t *testing.T) {
+	client := newFakeLambda("alpha", "beta")
+	ssmClient := &fakeSSM{parameters: map[string]string{"/shared/table": "orders"}}
+	secrets := &fakeSecretsManager{secrets: map[string]string{"api-key": "s3cr3t"}}
+	opts := testOptions(client)
+	opts.SSM = ssmClient
+	opts.SecretsManager = secrets
+	environment := "environment:\n  TABLE: ssm:/shared/table\n  API_KEY: secretsmanager:api-key\n  STAGE: dev\n"
+	configs := []*FunctionConfig{
+		newTestFunction(t, "name: alpha\nfileName: main.go\n"+environment),
+		newTestFunction(t, "name: beta\nfileName: main.go\n"+environment),
+	}
+
+	if _, err := Deploy(context.Background(), configs, opts); err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+
+	for _, name := range []string{"alpha", "beta"} {
+		variables := aws.StringValueMap(client.function(name).Environment.Variables)
+		if variables["TABLE"] != "orders" || variables["API_KEY"] != "s3cr3t" || variables["STAGE"] != "dev" {
+			t.Errorf("expected the resolved environment for %s, got %v", name, variables)
+		}
+	}
+	if ssmClient.calls != 1 || secrets.calls != 1 {
+		t.Errorf("expected every reference to be fetched once, got %d SSM and %d Secrets Manager calls", ssmClient.calls, secrets.calls)
+	}
+}
+
+func TestDeployFailsOnMissingParameter(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nenvironment:\n  TABLE: ssm:/missing\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "environment variable TABLE: error while resolving ssm:/missing") {
+		t.Errorf("expected a resolve error naming the variable, got %v", err)
+	}
+	if count := client.count("UpdateFunctionCode"); count != 0 {
+		t.Errorf("expected no code update, got %d", count)
+	}
+}
+
+func TestDeployResolvesFunctionArnAfterDependencyDeployed(t *testing.T) {
+	client := newFakeLambda("caller", "worker")
+	configs := []*FunctionConfig{
+		newTestFunction(t, "name: caller\nfileName: main.go\nenvironment:\n  WORKER_ARN: fn-arn:worker\n"),
+		newTestFunction(t, "name: worker\nfileName: main.go\n"),
+	}
+	opts := testOptions(client)
+	opts.Concurrency = 2
+
+	if _, err := Deploy(context.Background(), configs, opts); err != nil {
+		t.Fatalf("error while deploying: %v", err)
+	}
+
+	expected := "arn:aws:lambda:eu-central-1:" + testAccount + ":function:worker"
+	if arn := aws.StringValue(client.function("caller").Environment.Variables["WORKER_ARN"]); arn != expected {
+		t.Errorf("expected the ARN %s, got %s", expected, arn)
+	}
+	workerUpdated, resolved := -1, -1
+	for i, call := range client.calls {
+		if call == "UpdateFunctionCode worker" {
+			workerUpdated = i
+		} else if call == "GetFunction worker" {
+			resolved = i
+		}
+	}
+	if workerUpdated == -1 || resolved < workerUpdated {
+		t.Errorf("expected the ARN to be resolved after worker was deployed, got calls %v", client.calls)
+	}
+}
+
+func TestDeployFailsOnMissingReferencedFunction(t *testing.T) {
+	client := newFakeLambda("caller")
+	conf := newTestFunction(t, "name: caller\nfileName: main.go\nenvironment:\n  WORKER_ARN: fn-arn:worker\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "error while resolving fn-arn:worker") {
+		t.Errorf("expected a resolve error for the missing function, got %v", err)
+	}
+	if mutations := client.mutations(); len(mutations) != 0 {
+		t.Errorf("expected no changes, got %v", mutations)
+	}
+}
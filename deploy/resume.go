@@ -0,0 +1,56 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/sirupsen/logrus"
+)
+
+// getResumedResult returns the result of the function in Options.Resume if it was deployed successfully.
+// Functions skipped by a resumed run keep their code hash, so they are still resumed by the next run.
+func (d *deployer) getResumedResult(conf *FunctionConfig) *Result {
+	for i := range d.opts.Resume {
+		previous := &d.opts.Resume[i]
+		if previous.Name != conf.Name || (previous.Region != "" && previous.Region != d.region) || previous.CodeSha256 == "" {
+			continue
+		}
+		if previous.Action == ActionUpdated || previous.Action == ActionSkipped {
+			return previous
+		}
+	}
+	return nil
+}
+
+// resume reports whether the function was already deployed by the run of Options.Resume.
+// It was if the live code still has the hash recorded in that run and the newly built zip has it as well,
+// the version and hash are then copied to result. Signed zips differ from the deployed code, they are always deployed again.
+// Image based functions aren't built, only their live code is compared.
+func (d *deployer) resume(ctx context.Context, conf *FunctionConfig, result *Result) (bool, error) {
+	previous := d.getResumedResult(conf)
+	if previous == nil {
+		return false, nil
+	}
+
+	live, err := d.getLiveConfig(ctx, conf)
+	if err != nil {
+		return false, err
+	}
+	if aws.StringValue(live.CodeSha256) != previous.CodeSha256 {
+		logrus.Infof("redeploying lambda function %s, its code changed since the resumed run", conf.Name)
+		return false, nil
+	}
+	if conf.ImageUri == "" {
+		codeSha256, err := fileCodeSha256(conf.getZipOutputPath())
+		if err != nil {
+			return false, err
+		}
+		if codeSha256 != previous.CodeSha256 {
+			logrus.Infof("redeploying lambda function %s, its local code changed since the resumed run", conf.Name)
+			return false, nil
+		}
+	}
+
+	result.Version = previous.Version
+	result.CodeSha256 = previous.CodeSha256
+	return true, nil
+}
@@ -0,0 +1,156 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeResumeFunctions writes a function directory for each name below a new directory and returns it.
+func writeResumeFunctions(t *testing.T, names ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		writeFiles(t, dir, filepath.Join(name, "main.go"), testMain)
+	}
+	return dir
+}
+
+// parseResumeFunctions returns fresh configs of the functions written by writeResumeFunctions, like a new run reads them.
+func parseResumeFunctions(t *testing.T, dir string, names ...string) []*FunctionConfig {
+	t.Helper()
+	var configs []*FunctionConfig
+	for _, name := range names {
+		conf, err := ParseFunctionConfigFromReader(strings.NewReader("name: "+name+"\nfileName: main.go\n"), filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("error while parsing config: %v", err)
+		}
+		configs = append(configs, conf)
+	}
+	return configs
+}
+
+// codeUpdates returns the functions whose code was updated, in call order.
+func (client *fakeLambda) codeUpdates() []string {
+	var names []string
+	for _, mutation := range client.mutations() {
+		if strings.HasPrefix(mutation, "UpdateFunctionCode ") {
+			names = append(names, strings.TrimPrefix(mutation, "UpdateFunctionCode "))
+		}
+	}
+	return names
+}
+
+func TestDeployResumesPartiallyFailedRun(t *testing.T) {
+	names := []string{"alpha", "beta", "gamma"}
+	dir := writeResumeFunctions(t, names...)
+	client := newFakeLambda(names...)
+	client.fail("UpdateFunctionCode", nil, errors.New("throttled"))
+
+	previous, err := Deploy(context.Background(), parseResumeFunctions(t, dir, names...), testOptions(client))
+	if err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	if len(previous) != 2 || previous[0].Action != ActionUpdated || previous[1].Action != ActionFailed {
+		t.Fatalf("expected alpha to be updated and beta to fail, got %+v", previous)
+	}
+
+	logs := captureLogs(t)
+	opts := testOptions(client)
+	opts.Resume = previous
+	results, err := Deploy(context.Background(), parseResumeFunctions(t, dir, names...), opts)
+	if err != nil {
+		t.Fatalf("error while resuming: %v", err)
+	}
+
+	if updates := client.codeUpdates(); !reflect.DeepEqual(updates, []string{"alpha", "beta", "beta", "gamma"}) {
+		t.Errorf("expected only beta and gamma to be deployed again, got code updates %v", updates)
+	}
+	if results[0].Action != ActionSkipped || results[0].CodeSha256 != previous[0].CodeSha256 || results[0].Version != previous[0].Version {
+		t.Errorf("expected alpha to be skipped with its previous result, got %+v", results[0])
+	}
+	if results[1].Action != ActionUpdated || results[2].Action != ActionUpdated {
+		t.Errorf("expected beta and gamma to be updated, got %+v", results[1:])
+	}
+	if !strings.Contains(logs.String(), "skipped lambda function alpha, it was already deployed by the resumed run") {
+		t.Errorf("expected the resumed function to be logged, got %s", logs)
+	}
+}
+
+func TestDeployRedeploysResumedFunctionWithChangedCode(t *testing.T) {
+	dir := writeResumeFunctions(t, "hello")
+	client := newFakeLambda("hello")
+	previous := deployOne(t, parseResumeFunctions(t, dir, "hello")[0], testOptions(client))
+	client.functions["hello"].CodeSha256 = aws.String("changed")
+
+	logs := captureLogs(t)
+	opts := testOptions(client)
+	opts.Resume = []Result{previous}
+	result := deployOne(t, parseResumeFunctions(t, dir, "hello")[0], opts)
+
+	if result.Action != ActionUpdated || client.count("UpdateFunctionCode") != 2 {
+		t.Errorf("expected the function to be deployed again, got %s with %d code updates", result.Action, client.count("UpdateFunctionCode"))
+	}
+	if !strings.Contains(logs.String(), "redeploying lambda function hello, its code changed since the resumed run") {
+		t.Errorf("expected the changed code to be logged, got %s", logs)
+	}
+}
+
+func TestDeployRedeploysResumedFunctionWithChangedLocalCode(t *testing.T) {
+	names := []string{"alpha", "beta"}
+	dir := writeResumeFunctions(t, names...)
+	client := newFakeLambda(names...)
+	client.fail("UpdateFunctionCode", nil, errors.New("throttled"))
+	previous, err := Deploy(context.Background(), parseResumeFunctions(t, dir, names...), testOptions(client))
+	if err == nil {
+		t.Fatal("expected the first run to fail")
+	}
+	writeFiles(t, dir, "alpha/main.go", "package main\n\nfunc main() { println(\"fixed\") }\n")
+
+	logs := captureLogs(t)
+	opts := testOptions(client)
+	opts.Resume = previous
+	results, err := Deploy(context.Background(), parseResumeFunctions(t, dir, names...), opts)
+	if err != nil {
+		t.Fatalf("error while resuming: %v", err)
+	}
+
+	if updates := client.codeUpdates(); !reflect.DeepEqual(updates, []string{"alpha", "beta", "alpha", "beta"}) {
+		t.Errorf("expected the changed alpha to be deployed again, got code updates %v", updates)
+	}
+	if results[0].Action != ActionUpdated || results[0].CodeSha256 == previous[0].CodeSha256 {
+		t.Errorf("expected alpha to be updated with new code, got %+v", results[0])
+	}
+	if !strings.Contains(logs.String(), "redeploying lambda function alpha, its local code changed since the resumed run") {
+		t.Errorf("expected the changed local code to be logged, got %s", logs)
+	}
+}
+
+func TestGetResumedResult(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous Result
+		resumed  bool
+	}{
+		{"updated", Result{Name: "hello", Action: ActionUpdated, CodeSha256: "sum"}, true},
+		{"skipped", Result{Name: "hello", Action: ActionSkipped, CodeSha256: "sum"}, true},
+		{"same region", Result{Name: "hello", Region: "eu-central-1", Action: ActionUpdated, CodeSha256: "sum"}, true},
+		{"failed", Result{Name: "hello", Action: ActionFailed, CodeSha256: "sum"}, false},
+		{"without code hash", Result{Name: "hello", Action: ActionSkipped}, false},
+		{"other function", Result{Name: "other", Action: ActionUpdated, CodeSha256: "sum"}, false},
+		{"other region", Result{Name: "hello", Region: "us-east-1", Action: ActionUpdated, CodeSha256: "sum"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &deployer{opts: Options{Resume: []Result{test.previous}}, region: "eu-central-1"}
+
+			if resumed := d.getResumedResult(&FunctionConfig{Name: "hello"}) != nil; resumed != test.resumed {
+				t.Errorf("expected resumed to be %t, got %t", test.resumed, resumed)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package deploy
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/sirupsen/logrus"
+)
+
+// addRetryHandlers logs every retry of the AWS SDK at debug level and marks errors of exhausted retries.
+// When all retries are used up, the attempt count is added to the error message,
+// so throttling can be told apart from requests that failed right away.
+func addRetryHandlers(handlers *request.Handlers) {
+	handlers.AfterRetry.PushBackNamed(request.NamedHandler{
+		Name: "lambda-ci.RetryHandler",
+		Fn: func(r *request.Request) {
+			if r.Error == nil {
+				logrus.Debugf("retrying %s (attempt %d of %d) after %s backoff", r.Operation.Name, r.RetryCount+1, r.MaxRetries()+1, r.RetryDelay)
+				return
+			}
+			if aws.BoolValue(r.Retryable) && r.RetryCount > 0 && r.RetryCount >= r.MaxRetries() {
+				r.Error = retriesExhaustedError(r.Error, r.RetryCount+1)
+			}
+		},
+	})
+}
+
+// retriesExhaustedError adds the number of attempts to the message of err.
+// The error code and status code are preserved, so callers can still inspect them.
+func retriesExhaustedError(err error, attempts int) error {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return fmt.Errorf("%w (retries exhausted after %d attempts)", err, attempts)
+	}
+
+	message := fmt.Sprintf("%s (retries exhausted after %d attempts)", aerr.Message(), attempts)
+	wrapped := awserr.New(aerr.Code(), message, aerr.OrigErr())
+	if failure, ok := err.(awserr.RequestFailure); ok {
+		return awserr.NewRequestFailure(wrapped, failure.StatusCode(), failure.RequestID())
+	}
+	return wrapped
+}
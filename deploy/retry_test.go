@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRetriesExhaustedErrorKeepsCodes(t *testing.T) {
+	err := awserr.NewRequestFailure(awserr.New("ThrottlingException", "Rate exceeded", nil), 429, "request-id")
+
+	wrapped := retriesExhaustedError(err, 4)
+
+	failure, ok := wrapped.(awserr.RequestFailure)
+	if !ok {
+		t.Fatalf("expected a RequestFailure, got %T", wrapped)
+	}
+	if failure.Code() != "ThrottlingException" || failure.StatusCode() != 429 || failure.RequestID() != "request-id" {
+		t.Errorf("expected the codes to be kept, got %s %d %s", failure.Code(), failure.StatusCode(), failure.RequestID())
+	}
+	if !strings.Contains(failure.Message(), "Rate exceeded (retries exhausted after 4 attempts)") {
+		t.Errorf("expected the attempts in the message, got %s", failure.Message())
+	}
+}
+
+func TestRetriesExhaustedErrorWrapsOtherErrors(t *testing.T) {
+	err := errors.New("connection reset")
+
+	if wrapped := retriesExhaustedError(err, 2); !errors.Is(wrapped, err) {
+		t.Errorf("expected the error to be wrapped, got %v", wrapped)
+	}
+}
+
+func TestDeployerReportsExhaustedRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("X-Amzn-Errortype", "ServiceException")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message":"internal failure"}`))
+	}))
+	defer server.Close()
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("eu-central-1"),
+		Endpoint:    aws.String(server.URL),
+		Credentials: credentials.NewStaticCredentials("test", "test", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	maxRetries := 2
+	d, err := newDeployer(Options{Session: sess, MaxRetries: &maxRetries})
+	if err != nil {
+		t.Fatalf("error while creating deployer: %v", err)
+	}
+
+	_, err = d.lambda.GetFunctionConfigurationWithContext(context.Background(), &lambda.GetFunctionConfigurationInput{FunctionName: aws.String("hello")})
+
+	if err == nil || !strings.Contains(err.Error(), "retries exhausted after 3 attempts") {
+		t.Errorf("expected exhausted retries, got %v", err)
+	}
+	if requests := atomic.LoadInt32(&requests); requests != 3 {
+		t.Errorf("expected 3 requests, got %d", requests)
+	}
+}
@@ -0,0 +1,278 @@
+package deploy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// functionSchemaJSON is the JSON Schema of .function.yaml files.
+// It can also be used by editors for completion and validation.
+//
+//go:embed function.schema.json
+var functionSchemaJSON []byte
+
+// functionSchema is the parsed functionSchemaJSON.
+var functionSchema = mustParseSchema(functionSchemaJSON)
+
+// schema is the subset of JSON Schema used by function.schema.json.
+type schema struct {
+	Type                 string             `json:"type"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	Enum                 []string           `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum"`
+	MinLength            *int               `json:"minLength"`
+	Pattern              string             `json:"pattern"`
+
+	// pattern is the compiled Pattern, nil if no pattern is set.
+	pattern *regexp.Regexp
+	// additional is the parsed AdditionalProperties, nil if additional properties are forbidden.
+	additional *schema
+	// allowAdditional reports whether properties not listed in Properties are allowed.
+	allowAdditional bool
+}
+
+// mustParseSchema parses the given JSON Schema and panics if it is invalid.
+func mustParseSchema(data []byte) *schema {
+	var root schema
+	if err := json.Unmarshal(data, &root); err != nil {
+		panic(fmt.Sprintf("invalid function schema: %v", err))
+	}
+	root.prepare()
+	return &root
+}
+
+// prepare resolves the additionalProperties of the schema and all its children.
+func (s *schema) prepare() {
+	switch raw := strings.TrimSpace(string(s.AdditionalProperties)); raw {
+	case "", "true":
+		s.allowAdditional = true
+	case "false":
+	default:
+		s.allowAdditional = true
+		s.additional = &schema{}
+		if err := json.Unmarshal(s.AdditionalProperties, s.additional); err != nil {
+			panic(fmt.Sprintf("invalid function schema: %v", err))
+		}
+		s.additional.prepare()
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			panic(fmt.Sprintf("invalid function schema: %v", err))
+		}
+		s.pattern = pattern
+	}
+	for _, property := range s.Properties {
+		property.prepare()
+	}
+	if s.Items != nil {
+		s.Items.prepare()
+	}
+}
+
+// validateSchema validates the raw YAML of a function config against the function schema.
+// All violations are reported at once with the path of the offending field.
+func validateSchema(data []byte) error {
+	var document interface{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	if document == nil {
+		document = map[interface{}]interface{}{}
+	}
+
+	problems := functionSchema.validate(document, "")
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// validate returns every violation of the schema by value, prefixed with the path of the value.
+// Empty YAML values are decoded as nil and always valid, they leave the field unset.
+func (s *schema) validate(value interface{}, path string) []string {
+	if value == nil {
+		return nil
+	}
+	problem := func(format string, args ...interface{}) []string {
+		name := path
+		if name == "" {
+			name = "config"
+		}
+		return []string{name + ": " + fmt.Sprintf(format, args...)}
+	}
+
+	switch s.Type {
+	case "object":
+		fields, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return problem("must be an object")
+		}
+		return s.validateObject(fields, path)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return problem("must be a list")
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case "integer":
+		number, ok := toInteger(value)
+		if !ok {
+			return problem("must be an integer")
+		}
+		if s.Minimum != nil && float64(number) < *s.Minimum {
+			return problem("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && float64(number) > *s.Maximum {
+			return problem("must be <= %v", *s.Maximum)
+		}
+		if s.ExclusiveMaximum != nil && float64(number) >= *s.ExclusiveMaximum {
+			return problem("must be < %v", *s.ExclusiveMaximum)
+		}
+	case "number":
+		number, ok := toNumber(value)
+		if !ok {
+			return problem("must be a number")
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			return problem("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			return problem("must be <= %v", *s.Maximum)
+		}
+		if s.ExclusiveMaximum != nil && number >= *s.ExclusiveMaximum {
+			return problem("must be < %v", *s.ExclusiveMaximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return problem("must be true or false")
+		}
+	case "string":
+		// Every scalar is accepted, YAML decodes numbers and booleans into string fields as well
+		switch value.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return problem("must be a string")
+		}
+		text := fmt.Sprint(value)
+		if s.MinLength != nil && len(text) < *s.MinLength {
+			return problem("must not be empty")
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, text) {
+			return problem("must be one of %s", strings.Join(s.Enum, ", "))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(text) {
+			return problem("must match %s", s.Pattern)
+		}
+	}
+	return nil
+}
+
+// validateObject validates the fields of an object, including required and unknown fields.
+func (s *schema) validateObject(fields map[interface{}]interface{}, path string) []string {
+	prefix := path
+	if prefix != "" {
+		prefix += "."
+	}
+
+	var problems []string
+	for _, name := range s.Required {
+		if fields[name] == nil {
+			problems = append(problems, prefix+name+": is required")
+		}
+	}
+	for key, field := range fields {
+		name := fmt.Sprint(key)
+		property, ok := s.Properties[name]
+		if !ok {
+			property = s.additional
+		}
+		if property == nil {
+			if !s.allowAdditional {
+				problems = append(problems, prefix+name+": unknown field"+s.suggest(name))
+			}
+			continue
+		}
+		problems = append(problems, property.validate(field, prefix+name)...)
+	}
+	return problems
+}
+
+// suggest returns a hint naming the property closest to the unknown field, if any is close enough to be a typo.
+func (s *schema) suggest(name string) string {
+	best, bestDistance := "", 3
+	for property := range s.Properties {
+		if distance := editDistance(strings.ToLower(name), strings.ToLower(property)); distance < bestDistance ||
+			(distance == bestDistance && best != "" && property < best) {
+			best, bestDistance = property, distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", best)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// toNumber converts the integer and float types produced by the YAML decoder.
+func toNumber(value interface{}) (float64, bool) {
+	if number, ok := value.(float64); ok {
+		return number, true
+	}
+	number, ok := toInteger(value)
+	return float64(number), ok
+}
+
+// toInteger converts the integer types produced by the YAML decoder.
+func toInteger(value interface{}) (int64, bool) {
+	switch number := value.(type) {
+	case int:
+		return int64(number), true
+	case int64:
+		return number, true
+	case uint64:
+		return int64(number), true
+	}
+	return 0, false
+}
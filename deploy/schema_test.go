@@ -0,0 +1,100 @@
+package deploy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchemaReportsAllViolations(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{"missing name", "fileName: main.go\n", "name: is required"},
+		{"wrong type", "name: hello\nmemorySize: large\n", "memorySize: must be an integer"},
+		{"below minimum", "name: hello\nmemorySize: 64\n", "memorySize: must be >= 128"},
+		{"above maximum", "name: hello\ntimeout: 901\n", "timeout: must be <= 900"},
+		{"exclusive maximum", "name: hello\ncanaryWeight: 1\n", "canaryWeight: must be < 1"},
+		{"enum", "name: hello\narchitecture: x86\n", "architecture: must be one of x86_64, arm64"},
+		{"pattern", "name: hello\nsourceChecksum: abc\n", "sourceChecksum: must match ^[0-9a-fA-F]{64}$"},
+		{"list item", "name: hello\nlayers: [[a]]\n", "layers[0]: must be a string"},
+		{"nested object", "name: hello\nhealthCheck:\n  attempts: many\n", "healthCheck.attempts: must be an integer"},
+		{"not an object", "- name: hello\n", "config: must be an object"},
+		{"several violations", "name: hello\nmemorySize: 64\ntimeout: 0\n", "memorySize: must be >= 128; timeout: must be >= 1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateSchema([]byte(test.config))
+
+			if err == nil || err.Error() != test.message {
+				t.Errorf("expected %q, got %v", test.message, err)
+			}
+		})
+	}
+}
+
+func TestValidateSchemaAcceptsEmptyValues(t *testing.T) {
+	if err := validateSchema([]byte("name: hello\nfileName: main.go\nmemorySize:\nenvironment:\n  STAGE: prod\n")); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+}
+
+func TestSchemaDeclaresEveryConfigField(t *testing.T) {
+	configType := reflect.TypeOf(FunctionConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if _, ok := functionSchema.Properties[name]; !ok {
+			t.Errorf("expected the schema to declare %s", name)
+		}
+	}
+}
+
+func TestValidateSchemaSuggestsMisspelledFields(t *testing.T) {
+	tests := []struct {
+		config  string
+		message string
+	}{
+		{"name: hello\nfilename: main.go\n", "filename: unknown field, did you mean fileName?"},
+		{"name: hello\nmemroySize: 512\n", "memroySize: unknown field, did you mean memorySize?"},
+		{"name: hello\nhealthCheck:\n  atempts: 3\n", "healthCheck.atempts: unknown field, did you mean attempts?"},
+		{"name: hello\ncompletelyUnrelated: true\n", "completelyUnrelated: unknown field"},
+	}
+	for _, test := range tests {
+		t.Run(test.message, func(t *testing.T) {
+			err := validateSchema([]byte(test.config))
+
+			if err == nil || err.Error() != test.message {
+				t.Errorf("expected %q, got %v", test.message, err)
+			}
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"alias", "alias", 0},
+		{"alais", "alias", 2},
+		{"runtim", "runtime", 1},
+		{"", "name", 4},
+	}
+	for _, test := range tests {
+		if distance := editDistance(test.a, test.b); distance != test.distance {
+			t.Errorf("expected distance %d between %q and %q, got %d", test.distance, test.a, test.b, distance)
+		}
+	}
+}
+
+func TestParseRejectsDuplicateKeys(t *testing.T) {
+	_, err := parseTestConfig(t, "name: hello\nfileName: main.go\nname: world\n", "main.go", testMain)
+
+	expectConfigError(t, err, "field name already set in type deploy.FunctionConfig")
+}
@@ -0,0 +1,57 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/signer"
+	"github.com/sirupsen/logrus"
+)
+
+// signArtifact signs the zip staged at key in Options.ArtifactBucket with the AWS Signer profile of the function
+// and returns the key of the signed zip. Signer requires the exact version of the source object,
+// so the artifact bucket must be versioned. The signed zip is written next to the staged one.
+func (d *deployer) signArtifact(ctx context.Context, conf *FunctionConfig, key string, version string) (string, error) {
+	if version == "" {
+		return "", fmt.Errorf("staged object s3://%s/%s has no version, code signing requires a versioned artifact bucket", d.opts.ArtifactBucket, key)
+	}
+
+	job, err := d.signer.StartSigningJobWithContext(ctx, &signer.StartSigningJobInput{
+		ProfileName: &conf.SigningProfile,
+		Source: &signer.Source{S3: &signer.S3Source{
+			BucketName: &d.opts.ArtifactBucket,
+			Key:        &key,
+			Version:    &version,
+		}},
+		Destination: &signer.Destination{S3: &signer.S3Destination{
+			BucketName: &d.opts.ArtifactBucket,
+			Prefix:     aws.String(conf.getBuildName() + "/signed-"),
+		}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error while starting signing job: %w", err)
+	}
+	logrus.Infof("started signing job %s for lambda function %s", aws.StringValue(job.JobId), conf.Name)
+
+	describeInput := &signer.DescribeSigningJobInput{JobId: job.JobId}
+	waitErr := d.signer.WaitUntilSuccessfulSigningJobWithContext(ctx, describeInput)
+	// The job status explains a failed wait better than the waiter
+	info, err := d.signer.DescribeSigningJobWithContext(ctx, describeInput)
+	if err != nil {
+		return "", fmt.Errorf("error while describing signing job %s: %w", aws.StringValue(job.JobId), err)
+	}
+	if aws.StringValue(info.Status) != signer.SigningStatusSucceeded {
+		if waitErr == nil {
+			waitErr = errors.New(aws.StringValue(info.StatusReason))
+		}
+		return "", fmt.Errorf("signing job %s is %s: %s: %w", aws.StringValue(job.JobId), aws.StringValue(info.Status), aws.StringValue(info.StatusReason), waitErr)
+	}
+	if info.SignedObject == nil || info.SignedObject.S3 == nil {
+		return "", fmt.Errorf("signing job %s reported no signed object", aws.StringValue(job.JobId))
+	}
+
+	signedKey := aws.StringValue(info.SignedObject.S3.Key)
+	logrus.Infof("signed lambda function %s with profile %s at s3://%s/%s", conf.Name, conf.SigningProfile, d.opts.ArtifactBucket, signedKey)
+	return signedKey, nil
+}
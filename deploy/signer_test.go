@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/signer"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// signingOptions returns Options staging to a versioned bucket of the returned server and signing with signingClient.
+func signingOptions(t *testing.T, client *fakeLambda, signingClient *fakeSigner) (Options, *s3Server) {
+	server, s3Client := newS3Server(t)
+	server.versioned = true
+	client.staged = server
+	signingClient.bucket = server
+	opts := testOptions(client)
+	opts.S3 = s3Client
+	opts.Signer = signingClient
+	opts.ArtifactBucket = "artifacts"
+	return opts, server
+}
+
+func TestDeploySignsStagedZip(t *testing.T) {
+	client := newFakeLambda("hello")
+	signingClient := &fakeSigner{}
+	opts, _ := signingOptions(t, client, signingClient)
+	opts.KeepArtifacts = true
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nsigningProfile: release\n")
+
+	deployOne(t, conf, opts)
+	defer os.RemoveAll(conf.buildDir)
+
+	zip, err := ioutil.ReadFile(conf.getZipOutputPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(client.zips["hello"], append(zip, signedSuffix...)) {
+		t.Errorf("expected the signed zip to be deployed, got %d bytes", len(client.zips["hello"]))
+	}
+	sum := sha256.Sum256(zip)
+	job := signingClient.jobs["job-1"]
+	if job == nil || aws.StringValue(job.ProfileName) != "release" || aws.StringValue(job.Source.S3.Version) != "v1" ||
+		aws.StringValue(job.Source.S3.Key) != "hello/"+hex.EncodeToString(sum[:])+".zip" {
+		t.Errorf("expected a signing job for the staged version of the zip, got %+v", job)
+	}
+}
+
+func TestDeploySigningFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		versioned bool
+		message   string
+	}{
+		{"unversioned bucket", "", false, "code signing requires a versioned artifact bucket"},
+		{"failed job", signer.SigningStatusFailed, true, "signing job job-1 is Failed: test failure"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeLambda("hello")
+			opts, server := signingOptions(t, client, &fakeSigner{status: test.status})
+			server.versioned = test.versioned
+
+			_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\nsigningProfile: release\n")}, opts)
+
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("expected %q, got %v", test.message, err)
+			}
+			if count := client.count("UpdateFunctionCode"); count != 0 {
+				t.Errorf("expected no code update, got %d", count)
+			}
+		})
+	}
+}
+
+func TestDeploySigningRequiresArtifactBucket(t *testing.T) {
+	client := newFakeLambda("hello")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\nsigningProfile: release\n")}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "signingProfile requires an artifact bucket") {
+		t.Errorf("expected signing to require an artifact bucket, got %v", err)
+	}
+}
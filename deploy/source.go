@@ -0,0 +1,238 @@
+package deploy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// sourceArchiveName is the name of the downloaded source archive in the build directory.
+const sourceArchiveName = "source-archive"
+
+// sourceDirName is the name of the directory in the build directory the source archive is extracted to.
+const sourceDirName = "source"
+
+// isRemoteSource reports whether source is an http or https URL.
+func isRemoteSource(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// getArchiveType returns the archive type of the source by its extension: zip, tar or tgz.
+// Query and fragment of URLs are ignored, an empty string is returned for unknown extensions.
+func getArchiveType(source string) string {
+	if isRemoteSource(source) {
+		if parsed, err := url.Parse(source); err == nil {
+			source = parsed.Path
+		}
+	}
+	switch {
+	case strings.HasSuffix(source, ".zip"):
+		return "zip"
+	case strings.HasSuffix(source, ".tar"):
+		return "tar"
+	case strings.HasSuffix(source, ".tar.gz"), strings.HasSuffix(source, ".tgz"):
+		return "tgz"
+	}
+	return ""
+}
+
+// validateSource checks that Source is a directory, an archive or an archive URL and that SourceChecksum fits it.
+func (conf *FunctionConfig) validateSource() error {
+	if conf.Source == "" {
+		if conf.SourceChecksum != "" {
+			return errors.New("sourceChecksum requires source to be set")
+		}
+		return nil
+	}
+	if conf.ImageUri != "" {
+		return errors.New("source can't be used with imageUri")
+	}
+	if isRemoteSource(conf.Source) && getArchiveType(conf.Source) == "" {
+		return fmt.Errorf("source %s must be a .zip, .tar, .tar.gz or .tgz archive", conf.Source)
+	}
+	if conf.SourceChecksum != "" {
+		if getArchiveType(conf.Source) == "" {
+			return errors.New("sourceChecksum can only be used with a source archive")
+		}
+		if sum, err := hex.DecodeString(conf.SourceChecksum); err != nil || len(sum) != 32 {
+			return fmt.Errorf("sourceChecksum %s must be a hex encoded SHA-256", conf.SourceChecksum)
+		}
+	}
+	return nil
+}
+
+// getSourceDir returns the directory the function is built from, relative file names of the config are resolved against it.
+// It is the function directory unless a Source was fetched.
+func (conf *FunctionConfig) getSourceDir() string {
+	if conf.sourceDir != "" {
+		return conf.sourceDir
+	}
+	return conf.Path
+}
+
+// fetchSource makes the Source of the function available as its source directory.
+// Local directories are used as they are, archives are downloaded if they are remote, checked against the
+// SourceChecksum and extracted into the build directory. Archives with a single top-level directory,
+// like the tarballs of git hosts, are built from that directory.
+// The source files are checked for a main function once they are available.
+func (d *deployer) fetchSource(ctx context.Context, conf *FunctionConfig) error {
+	archive := conf.Source
+	if isRemoteSource(conf.Source) {
+		archive = filepath.Join(conf.buildDir, sourceArchiveName)
+		if err := d.download(ctx, conf.Source, archive); err != nil {
+			return fmt.Errorf("error while downloading %s: %w", conf.Source, err)
+		}
+		logrus.Infof("downloaded source of lambda function %s from %s", conf.Name, conf.Source)
+	} else if !filepath.IsAbs(archive) {
+		archive = filepath.Join(conf.Path, archive)
+	}
+
+	archiveType := getArchiveType(conf.Source)
+	if archiveType == "" {
+		conf.sourceDir = archive
+		return conf.validateFetchedSource()
+	}
+
+	if conf.SourceChecksum != "" {
+		sum, err := fileSha256(archive)
+		if err != nil {
+			return err
+		}
+		if hex.EncodeToString(sum) != strings.ToLower(conf.SourceChecksum) {
+			return fmt.Errorf("SHA-256 %s of source %s doesn't match sourceChecksum %s", hex.EncodeToString(sum), conf.Source, conf.SourceChecksum)
+		}
+	}
+
+	dir := filepath.Join(conf.buildDir, sourceDirName)
+	if err := extractArchive(archive, archiveType, dir); err != nil {
+		return fmt.Errorf("error while extracting %s: %w", conf.Source, err)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		dir = filepath.Join(dir, entries[0].Name())
+	}
+	conf.sourceDir = dir
+	logrus.Debugf("extracted source of lambda function %s to %s", conf.Name, dir)
+	return conf.validateFetchedSource()
+}
+
+// validateFetchedSource runs the checks of the source files, which are skipped while parsing configs with a Source.
+func (conf *FunctionConfig) validateFetchedSource() error {
+	if len(conf.getSourceFileNames()) > 0 {
+		return conf.validateMainFile()
+	}
+	return nil
+}
+
+// extractArchive extracts the archive of the given type into dir.
+// Entries escaping dir are rejected, only regular files and directories are extracted.
+func extractArchive(archive string, archiveType string, dir string) error {
+	if archiveType == "zip" {
+		return extractZip(archive, dir)
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if archiveType == "tgz" {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if _, err := getExtractPath(dir, header.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(dir, header.Name, os.FileMode(header.Mode), tarReader); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractZip extracts the zip archive into dir.
+func extractZip(archive string, dir string) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, entry := range reader.File {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		content, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(dir, entry.Name, entry.Mode(), content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractFile writes the content of the archive entry name below dir.
+func extractFile(dir string, name string, mode os.FileMode, content io.Reader) error {
+	target, err := getExtractPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// getExtractPath returns the path the archive entry name is extracted to, entries must stay below dir.
+func getExtractPath(dir string, name string) (string, error) {
+	clean := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("archive entry %s escapes the source directory", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,197 @@
+package deploy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// sourceMain is the main file of the source archives, the binary contains its marker.
+const sourceMain = "package main\n\nfunc main() { println(\"source-marker\") }\n"
+
+// newTarball returns a gzipped tarball with the given name and content pairs.
+func newTarball(t *testing.T, files ...string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for i := 0; i+1 < len(files); i += 2 {
+		header := &tar.Header{Name: files[i], Mode: 0644, Size: int64(len(files[i+1])), Typeflag: tar.TypeReg}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(files[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// newArchiveServer serves archive at every path and counts the downloads.
+func newArchiveServer(t *testing.T, archive []byte) (*httptest.Server, *int32) {
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		w.Write(archive)
+	}))
+	t.Cleanup(server.Close)
+	return server, &downloads
+}
+
+// checksum returns the hex encoded SHA-256 of content.
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDeployBuildsRemoteTarball(t *testing.T) {
+	archive := newTarball(t, "repo-main/main.go", sourceMain, "repo-main/README.md", "readme")
+	server, downloads := newArchiveServer(t, archive)
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nsource: "+server.URL+"/repo.tar.gz?ref=main\nsourceChecksum: "+checksum(archive)+"\n")
+
+	deployOne(t, conf, testOptions(client))
+
+	if count := atomic.LoadInt32(downloads); count != 1 {
+		t.Errorf("expected the source to be downloaded once, got %d downloads", count)
+	}
+	if binary := client.zipFile(t, "hello", "hello"); !bytes.Contains(binary, []byte("source-marker")) {
+		t.Error("expected the binary to be built from the source archive")
+	}
+	if dir := conf.getSourceDir(); filepath.Base(dir) != "repo-main" {
+		t.Errorf("expected the build to use the top-level directory of the archive, got %s", dir)
+	}
+}
+
+func TestDeployBuildsLocalZip(t *testing.T) {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	entry, err := zipWriter.Create("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.Write([]byte(sourceMain))
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nsource: source.zip\n")
+	if err := ioutil.WriteFile(filepath.Join(conf.Path, "source.zip"), buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	deployOne(t, conf, testOptions(client))
+
+	if binary := client.zipFile(t, "hello", "hello"); !bytes.Contains(binary, []byte("source-marker")) {
+		t.Error("expected the binary to be built from the zip source")
+	}
+}
+
+func TestDeployBuildsLocalSourceDir(t *testing.T) {
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nsource: app\n", "app/main.go", sourceMain)
+
+	deployOne(t, conf, testOptions(client))
+
+	if binary := client.zipFile(t, "hello", "hello"); !bytes.Contains(binary, []byte("source-marker")) {
+		t.Error("expected the binary to be built from the source directory")
+	}
+}
+
+func TestDeployRejectsBadSourceArchive(t *testing.T) {
+	tests := []struct {
+		name     string
+		archive  []byte
+		checksum string
+		message  string
+	}{
+		{"checksum mismatch", newTarball(t, "main.go", sourceMain), strings.Repeat("f", 64), "doesn't match sourceChecksum " + strings.Repeat("f", 64)},
+		{"escaping entry", newTarball(t, "../main.go", sourceMain), "", "archive entry ../main.go escapes the source directory"},
+		{"missing main file", newTarball(t, "other.go", sourceMain), "", "main.go"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := newArchiveServer(t, test.archive)
+			client := newFakeLambda("hello")
+			config := "name: hello\nfileName: main.go\nsource: " + server.URL + "/source.tgz\n"
+			if test.checksum != "" {
+				config += "sourceChecksum: " + test.checksum + "\n"
+			}
+
+			_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, config)}, testOptions(client))
+
+			var buildErr *BuildError
+			if !errors.As(err, &buildErr) || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("expected a BuildError containing %q, got %v", test.message, err)
+			}
+			if count := client.count("UpdateFunctionCode"); count != 0 {
+				t.Errorf("expected no code update, got %d", count)
+			}
+		})
+	}
+}
+
+func TestDeployFailsIfSourceDownloadFails(t *testing.T) {
+	server, _ := newCodeServer(t, http.StatusNotFound, "missing")
+	client := newFakeLambda("hello")
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nsource: "+server.URL+"/source.zip\n")
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{conf}, testOptions(client))
+
+	if err == nil || !strings.Contains(err.Error(), "error while downloading "+server.URL+"/source.zip") || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected the failed download to be reported, got %v", err)
+	}
+}
+
+func TestParseValidatesSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+	}{
+		{"checksum without source", "sourceChecksum: " + strings.Repeat("a", 64) + "\n", "sourceChecksum requires source to be set"},
+		{"unknown remote archive", "source: https://example.com/source.rar\n", "source https://example.com/source.rar must be a .zip, .tar, .tar.gz or .tgz archive"},
+		{"checksum for directory", "source: app\nsourceChecksum: " + strings.Repeat("a", 64) + "\n", "sourceChecksum can only be used with a source archive"},
+		{"malformed checksum", "source: app.tgz\nsourceChecksum: abc\n", "sourceChecksum: must match"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseFunctionConfigFromReader(strings.NewReader("name: hello\nfileName: main.go\n"+test.config), t.TempDir())
+			expectConfigError(t, err, test.message)
+		})
+	}
+}
+
+func TestGetArchiveType(t *testing.T) {
+	tests := map[string]string{
+		"source.zip":    "zip",
+		"source.tar":    "tar",
+		"source.tar.gz": "tgz",
+		"https://example.com/source.tgz?ref=main": "tgz",
+		"https://example.com/source.zip#main":     "zip",
+		"app":                                     "",
+	}
+	for source, expected := range tests {
+		if archiveType := getArchiveType(source); archiveType != expected {
+			t.Errorf("expected archive type %q for %s, got %q", expected, source, archiveType)
+		}
+	}
+}
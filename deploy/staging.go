@@ -0,0 +1,106 @@
+package deploy
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strings"
+)
+
+// validateStaging checks the encryption and ACL options of staged zips.
+func (opts Options) validateStaging() error {
+	if opts.S3ServerSideEncryption != "" && !contains(s3.ServerSideEncryption_Values(), opts.S3ServerSideEncryption) {
+		return fmt.Errorf("server-side encryption %s must be one of %s", opts.S3ServerSideEncryption, strings.Join(s3.ServerSideEncryption_Values(), ", "))
+	}
+	if opts.S3SseKmsKeyId != "" && opts.S3ServerSideEncryption != "" && opts.S3ServerSideEncryption != s3.ServerSideEncryptionAwsKms {
+		return fmt.Errorf("a KMS key requires server-side encryption %s", s3.ServerSideEncryptionAwsKms)
+	}
+	if opts.S3ACL != "" && !contains(s3.ObjectCannedACL_Values(), opts.S3ACL) {
+		return fmt.Errorf("ACL %s must be one of %s", opts.S3ACL, strings.Join(s3.ObjectCannedACL_Values(), ", "))
+	}
+	return nil
+}
+
+// applyStagingEncryption sets the configured server-side encryption and ACL on the upload of a staged zip.
+func (d *deployer) applyStagingEncryption(input *s3manager.UploadInput) {
+	if d.opts.S3ServerSideEncryption != "" {
+		input.ServerSideEncryption = aws.String(d.opts.S3ServerSideEncryption)
+	}
+	if d.opts.S3SseKmsKeyId != "" {
+		input.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		input.SSEKMSKeyId = aws.String(d.opts.S3SseKmsKeyId)
+	}
+	if d.opts.S3ACL != "" {
+		input.ACL = aws.String(d.opts.S3ACL)
+	}
+}
+
+// isAccessDenied reports whether err is an S3 AccessDenied error, which is also returned
+// if a bucket policy rejects the upload because of missing encryption.
+func isAccessDenied(err error) bool {
+	var awsErr awserr.Error
+	return errors.As(err, &awsErr) && awsErr.Code() == "AccessDenied"
+}
+
+// getZipSha256 returns the hex encoded SHA-256 of the zip file for this FunctionConfig.
+func (conf *FunctionConfig) getZipSha256() (string, error) {
+	sum, err := fileSha256(conf.getZipOutputPath())
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// getStagingKey returns the S3 key the zip with the given hex encoded hash is staged at.
+// The key contains the hash, so a staged object is never overwritten with different code.
+func (conf *FunctionConfig) getStagingKey(sha256 string) string {
+	return fmt.Sprintf("%s/%s.zip", conf.getBuildName(), sha256)
+}
+
+// stageArtifact uploads the zip file for this FunctionConfig to Options.ArtifactBucket and returns its key
+// and version, the version is empty if the bucket isn't versioned.
+// Large zips are uploaded in parts of Options.S3PartSize, Options.S3Concurrency parts at a time.
+func (d *deployer) stageArtifact(ctx context.Context, conf *FunctionConfig) (string, string, error) {
+	sha256, err := conf.getZipSha256()
+	if err != nil {
+		return "", "", err
+	}
+	key := conf.getStagingKey(sha256)
+
+	file, err := os.Open(conf.getZipOutputPath())
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	uploader := s3manager.NewUploaderWithClient(d.s3, func(uploader *s3manager.Uploader) {
+		if d.opts.S3PartSize > 0 {
+			uploader.PartSize = d.opts.S3PartSize
+		}
+		if d.opts.S3Concurrency > 0 {
+			uploader.Concurrency = d.opts.S3Concurrency
+		}
+	})
+	input := &s3manager.UploadInput{
+		Bucket: &d.opts.ArtifactBucket,
+		Key:    &key,
+		Body:   file,
+	}
+	d.applyStagingEncryption(input)
+	output, err := uploader.UploadWithContext(ctx, input)
+	if err != nil {
+		if isAccessDenied(err) && input.ServerSideEncryption == nil {
+			return "", "", fmt.Errorf("%w (the bucket may require encryption, see --s3-sse and --s3-sse-kms-key-id)", err)
+		}
+		return "", "", err
+	}
+	logrus.Infof("staged lambda function %s at s3://%s/%s", conf.Name, d.opts.ArtifactBucket, key)
+	return key, aws.StringValue(output.VersionID), nil
+}
@@ -0,0 +1,147 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDeployStagesZipWithMultipartUpload(t *testing.T) {
+	assets := make([]byte, 6*1024*1024)
+	if _, err := rand.Read(assets); err != nil {
+		t.Fatal(err)
+	}
+	server, s3Client := newS3Server(t)
+	client := newFakeLambda("hello")
+	client.staged = server
+	conf := newTestFunction(t, "name: hello\nfileName: main.go\nincludeDir: assets\n", "assets/random.bin", string(assets))
+	opts := testOptions(client)
+	opts.S3 = s3Client
+	opts.ArtifactBucket = "artifacts"
+	opts.S3PartSize = 5 * 1024 * 1024
+	opts.S3Concurrency = 2
+	opts.KeepArtifacts = true
+
+	result := deployOne(t, conf, opts)
+	defer os.RemoveAll(conf.buildDir)
+
+	zip, err := ioutil.ReadFile(conf.getZipOutputPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(zip)
+	path := "artifacts/hello/" + hex.EncodeToString(sum[:]) + ".zip"
+	if object, _ := server.object(path); !bytes.Equal(object, zip) {
+		t.Errorf("expected the zip to be staged at %s, got %d bytes", path, len(object))
+	}
+	if len(server.parts) != 1 || len(server.parts["upload-1"]) != 2 {
+		t.Errorf("expected a multipart upload with 2 parts, got %v uploads", len(server.parts))
+	}
+	if result.CodeSha256 != base64.StdEncoding.EncodeToString(sum[:]) || !bytes.Equal(client.zips["hello"], zip) {
+		t.Errorf("expected the code to be updated from the staged object, got %s", result.CodeSha256)
+	}
+}
+
+func TestDeployStagesSmallZipWithSingleUpload(t *testing.T) {
+	server, s3Client := newS3Server(t)
+	client := newFakeLambda("hello")
+	client.staged = server
+	opts := testOptions(client)
+	opts.S3 = s3Client
+	opts.ArtifactBucket = "artifacts"
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+	if len(server.objects) != 1 || len(server.parts) != 0 {
+		t.Errorf("expected a single object without multipart upload, got %d objects and %d multipart uploads", len(server.objects), len(server.parts))
+	}
+}
+
+func TestDeployStagesZipWithEncryptionAndACL(t *testing.T) {
+	tests := []struct {
+		name       string
+		encryption string
+		kmsKey     string
+		expected   string
+	}{
+		{"AES256", "AES256", "", "AES256"},
+		{"KMS key implies aws:kms", "", "alias/artifacts", "aws:kms"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, s3Client := newS3Server(t)
+			client := newFakeLambda("hello")
+			client.staged = server
+			opts := testOptions(client)
+			opts.S3 = s3Client
+			opts.ArtifactBucket = "artifacts"
+			opts.S3ServerSideEncryption = test.encryption
+			opts.S3SseKmsKeyId = test.kmsKey
+			opts.S3ACL = "bucket-owner-full-control"
+
+			deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), opts)
+
+			if len(server.objects) != 1 {
+				t.Fatalf("expected a staged zip, got %d objects", len(server.objects))
+			}
+			for path := range server.objects {
+				_, headers := server.object(path)
+				if value := headers.Get("X-Amz-Server-Side-Encryption"); value != test.expected {
+					t.Errorf("expected encryption %s, got %s", test.expected, value)
+				}
+				if value := headers.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"); value != test.kmsKey {
+					t.Errorf("expected KMS key %q, got %q", test.kmsKey, value)
+				}
+				if value := headers.Get("X-Amz-Acl"); value != "bucket-owner-full-control" {
+					t.Errorf("expected ACL bucket-owner-full-control, got %s", value)
+				}
+			}
+		})
+	}
+}
+
+func TestDeployHintsAtEncryptionWhenStagingIsDenied(t *testing.T) {
+	server, s3Client := newS3Server(t)
+	server.deny = true
+	client := newFakeLambda("hello")
+	opts := testOptions(client)
+	opts.S3 = s3Client
+	opts.ArtifactBucket = "artifacts"
+
+	_, err := Deploy(context.Background(), []*FunctionConfig{newTestFunction(t, "name: hello\nfileName: main.go\n")}, opts)
+
+	if err == nil || !strings.Contains(err.Error(), "the bucket may require encryption, see --s3-sse and --s3-sse-kms-key-id") {
+		t.Errorf("expected an encryption hint, got %v", err)
+	}
+}
+
+func TestValidateStaging(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		message string
+	}{
+		{"valid", Options{S3ServerSideEncryption: "aws:kms", S3SseKmsKeyId: "alias/artifacts", S3ACL: "private"}, ""},
+		{"unknown encryption", Options{S3ServerSideEncryption: "rot13"}, "server-side encryption rot13 must be one of AES256, aws:kms, aws:kms:dsse"},
+		{"KMS key with AES256", Options{S3ServerSideEncryption: "AES256", S3SseKmsKeyId: "alias/artifacts"}, "a KMS key requires server-side encryption aws:kms"},
+		{"unknown ACL", Options{S3ACL: "everyone"}, "ACL everyone must be one of"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.opts.validateStaging()
+
+			if test.message == "" && err != nil {
+				t.Errorf("expected valid options, got %v", err)
+			} else if test.message != "" && (err == nil || !strings.Contains(err.Error(), test.message)) {
+				t.Errorf("expected an error containing %q, got %v", test.message, err)
+			}
+		})
+	}
+}
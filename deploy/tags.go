@@ -0,0 +1,64 @@
+package deploy
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+// defaultManagedTagValue is the value of the managed tag if neither the options nor the config set one.
+const defaultManagedTagValue = "lambda-ci"
+
+// getManagedTag returns the key and value of the tag marking the function as managed by lambda-ci.
+// The config takes precedence over the options, an empty key disables the tag.
+func (d *deployer) getManagedTag(conf *FunctionConfig) (string, string) {
+	key := conf.ManagedTagKey
+	if key == "" {
+		key = d.opts.ManagedTagKey
+	}
+	value := conf.ManagedTagValue
+	if value == "" {
+		value = d.opts.ManagedTagValue
+	}
+	if value == "" {
+		value = defaultManagedTagValue
+	}
+	return key, value
+}
+
+// tagManaged sets the managed tag and the CI tags of Options.StampCI on the function with the given ARN.
+func (d *deployer) tagManaged(ctx context.Context, conf *FunctionConfig, arn string) error {
+	tags := map[string]*string{}
+	for key, value := range d.ciTags {
+		tags[key] = aws.String(value)
+	}
+	if key, value := d.getManagedTag(conf); key != "" {
+		tags[key] = aws.String(value)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_, err := d.lambda.TagResourceWithContext(ctx, &lambda.TagResourceInput{
+		Resource: &arn,
+		Tags:     tags,
+	})
+	if err != nil {
+		return err
+	}
+	logrus.Debugf("tagged lambda function %s with %s", conf.Name, formatTags(tags))
+	return nil
+}
+
+// formatTags returns the tags as sorted key=value pairs.
+func formatTags(tags map[string]*string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+aws.StringValue(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,44 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeployTagsManagedFunction(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		key      string
+		value    string
+		expected map[string]string
+	}{
+		{"default value", "name: hello\nfileName: main.go\n", "managed-by", "", map[string]string{"managed-by": "lambda-ci"}},
+		{"value from options", "name: hello\nfileName: main.go\n", "managed-by", "platform", map[string]string{"managed-by": "platform"}},
+		{"override in config", "name: hello\nfileName: main.go\nmanagedTagKey: owner\nmanagedTagValue: team-a\n", "managed-by", "platform", map[string]string{"owner": "team-a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newFakeLambda("hello")
+			opts := testOptions(client)
+			opts.ManagedTagKey = test.key
+			opts.ManagedTagValue = test.value
+
+			deployOne(t, newTestFunction(t, test.config), opts)
+
+			if tags := client.tags["arn:aws:lambda:eu-central-1:"+testAccount+":function:hello"]; !reflect.DeepEqual(tags, test.expected) {
+				t.Errorf("expected tags %v, got %v", test.expected, tags)
+			}
+		})
+	}
+}
+
+func TestDeployWithoutManagedTagKeyDoesNotTag(t *testing.T) {
+	client := newFakeLambda("hello")
+
+	deployOne(t, newTestFunction(t, "name: hello\nfileName: main.go\n"), testOptions(client))
+
+	if count := client.count("TagResource"); count != 0 {
+		t.Errorf("expected no tags, got %d TagResource calls", count)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// globalConfig holds the tool-wide defaults from ~/.lambda-ci/config.yaml.
+// Every value only applies if nothing more specific, like a flag or environment variable, is set.
+type globalConfig struct {
+	Region      string `yaml:"region"`
+	Profile     string `yaml:"profile"`
+	Concurrency int    `yaml:"concurrency"`
+	LogLevel    string `yaml:"logLevel"`
+}
+
+// loadGlobalConfig reads ~/.lambda-ci/config.yaml, a missing file yields an empty config.
+func loadGlobalConfig() (*globalConfig, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return &globalConfig{}, nil
+	}
+	path := filepath.Join(home, ".lambda-ci", "config.yaml")
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &globalConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config := &globalConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("error while parsing %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// apply uses the global defaults for every setting that wasn't given explicitly.
+// Region and profile are passed to the AWS SDK through AWS_REGION and AWS_PROFILE.
+func (config *globalConfig) apply() error {
+	if config.Region != "" && os.Getenv("AWS_REGION") == "" && os.Getenv("AWS_DEFAULT_REGION") == "" {
+		os.Setenv("AWS_REGION", config.Region)
+	}
+	if config.Profile != "" && os.Getenv("AWS_PROFILE") == "" && os.Getenv("AWS_DEFAULT_PROFILE") == "" {
+		os.Setenv("AWS_PROFILE", config.Profile)
+	}
+	if config.Concurrency > 0 && !isFlagSet("concurrency") {
+		*concurrencyFlag = config.Concurrency
+	}
+	if config.LogLevel != "" && !*quietFlag {
+		level, err := logrus.ParseLevel(config.LogLevel)
+		if err != nil {
+			return err
+		}
+		logrus.SetLevel(level)
+	}
+	return nil
+}
+
+// isFlagSet reports whether the flag with the given name was passed on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
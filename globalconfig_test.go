@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadGlobalConfigWithoutFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	config, err := loadGlobalConfig()
+
+	if err != nil || *config != (globalConfig{}) {
+		t.Errorf("expected an empty config, got %v, %v", config, err)
+	}
+}
+
+func TestLoadGlobalConfigRejectsUnknownKeys(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeFile(t, home, filepath.Join(".lambda-ci", "config.yaml"), "region: eu-west-1\nregoin: us-east-1\n")
+
+	_, err := loadGlobalConfig()
+
+	if err == nil || !strings.Contains(err.Error(), "error while parsing "+filepath.Join(home, ".lambda-ci", "config.yaml")) {
+		t.Errorf("expected a parse error for the unknown key, got %v", err)
+	}
+}
+
+func TestGlobalConfigAppliesDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_DEFAULT_PROFILE", "")
+	writeFile(t, home, filepath.Join(".lambda-ci", "config.yaml"), "region: eu-west-1\nprofile: deploy\nconcurrency: 4\nlogLevel: debug\n")
+	previousConcurrency, previousLevel := *concurrencyFlag, logrus.GetLevel()
+	t.Cleanup(func() {
+		*concurrencyFlag = previousConcurrency
+		logrus.SetLevel(previousLevel)
+	})
+
+	config, err := loadGlobalConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.apply(); err != nil {
+		t.Fatalf("error while applying the config: %v", err)
+	}
+
+	if os.Getenv("AWS_REGION") != "eu-west-1" || os.Getenv("AWS_PROFILE") != "deploy" {
+		t.Errorf("expected region eu-west-1 and profile deploy, got %s and %s", os.Getenv("AWS_REGION"), os.Getenv("AWS_PROFILE"))
+	}
+	if *concurrencyFlag != 4 || logrus.GetLevel() != logrus.DebugLevel {
+		t.Errorf("expected concurrency 4 and level debug, got %d and %s", *concurrencyFlag, logrus.GetLevel())
+	}
+}
+
+func TestGlobalConfigKeepsExplicitSettings(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_DEFAULT_PROFILE", "ci")
+
+	if err := (&globalConfig{Region: "eu-west-1", Profile: "deploy"}).apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if os.Getenv("AWS_REGION") != "us-east-1" || os.Getenv("AWS_PROFILE") != "" {
+		t.Errorf("expected the environment to win, got region %s and profile %s", os.Getenv("AWS_REGION"), os.Getenv("AWS_PROFILE"))
+	}
+}
@@ -1,9 +1,14 @@
 module lambda-ci
 
-go 1.16
+go 1.19
 
 require (
-	github.com/aws/aws-sdk-go v1.37.27 // indirect
-	github.com/sirupsen/logrus v1.8.1 // indirect
-	gopkg.in/yaml.v2 v2.4.0 // indirect
+	github.com/aws/aws-sdk-go v1.55.8
+	github.com/sirupsen/logrus v1.8.1
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f // indirect
 )
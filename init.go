@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	configFileName = ".function.yaml"
+	defaultRuntime = "provided.al2023"
+)
+
+// configTemplate is the commented skeleton written by the init command.
+var configTemplate = template.Must(template.New("config").Parse(`# Name of the Function used on AWS.
+# Must be unique in your region.
+name: {{printf "%q" .Name}}
+
+# Go file which contains your function code.
+# Must be in the same directory
+fileName: {{printf "%q" .FileName}}
+
+# Expected runtime of the function, the deploy fails if the live function differs.
+# For provided.* runtimes the binary is zipped as bootstrap.
+runtime: {{printf "%q" .Runtime}}
+
+# Optional: AWS region the function is deployed to, defaults to the region of the environment.
+{{if .Region}}region: {{printf "%q" .Region}}{{else}}# region: "eu-central-1"{{end}}
+
+# Optional: expected architecture (x86_64 or arm64), also selects GOARCH for the build.
+# architecture: "arm64"
+
+# Optional: publish a new version after each update and point this alias to it.
+# alias: "live"
+
+# Optional: environment variables of the function. Replaces the live environment when set.
+# environment:
+#   STAGE: "prod"
+`))
+
+// initAnswers holds the values written into a new config.
+type initAnswers struct {
+	Name     string
+	FileName string
+	Runtime  string
+	Region   string
+}
+
+// runInit implements the init subcommand, which writes a new .function.yaml.
+// Values not given as flags are prompted for on stdin.
+func runInit(args []string) {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	nameFlag := flags.String("name", "", "name of the function on AWS")
+	fileNameFlag := flags.String("file-name", "", "Go file which contains the function code")
+	runtimeFlag := flags.String("runtime", "", "runtime of the function, defaults to "+defaultRuntime)
+	regionFlag := flags.String("region", "", "AWS region of the function, defaults to the region of the environment")
+	dirFlag := flags.String("dir", ".", "directory the config is written to")
+	forceFlag := flags.Bool("force", false, "overwrite an existing config")
+	flags.Parse(args)
+
+	path := filepath.Join(*dirFlag, configFileName)
+	if _, err := os.Stat(path); err == nil && !*forceFlag {
+		logrus.Fatalf("%s already exists, use --force to overwrite it", path)
+	}
+
+	answers := initAnswers{
+		Name:     *nameFlag,
+		FileName: *fileNameFlag,
+		Runtime:  *runtimeFlag,
+		Region:   *regionFlag,
+	}
+	if err := answers.prompt(os.Stdin, os.Stdout); err != nil {
+		logrus.WithError(err).Fatal("error while reading config values")
+	}
+
+	if err := writeConfigTemplate(path, answers); err != nil {
+		logrus.WithError(err).Fatalf("error while writing config to %s", path)
+	}
+	logrus.Infof("wrote function config to %s", path)
+}
+
+// prompt asks for every value that was not given as a flag.
+// Name and fileName are required, runtime and region fall back to their defaults when left empty.
+func (answers *initAnswers) prompt(in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	ask := func(value *string, question string, fallback string, required bool) error {
+		for *value == "" {
+			if fallback != "" {
+				fmt.Fprintf(out, "%s [%s]: ", question, fallback)
+			} else {
+				fmt.Fprintf(out, "%s: ", question)
+			}
+			line, err := reader.ReadString('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			*value = strings.TrimSpace(line)
+			if *value == "" {
+				*value = fallback
+			}
+			if !required {
+				return nil
+			}
+			if *value == "" && errors.Is(err, io.EOF) {
+				return fmt.Errorf("%s is required", strings.ToLower(question))
+			}
+		}
+		return nil
+	}
+
+	if err := ask(&answers.Name, "Function name", "", true); err != nil {
+		return err
+	}
+	if err := ask(&answers.FileName, "Go file", "main.go", true); err != nil {
+		return err
+	}
+	if err := ask(&answers.Runtime, "Runtime", defaultRuntime, true); err != nil {
+		return err
+	}
+	return ask(&answers.Region, "Region (empty for the environment default)", "", false)
+}
+
+// writeConfigTemplate renders the config template with the given answers to path.
+func writeConfigTemplate(path string, answers initAnswers) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := configTemplate.Execute(file, answers); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
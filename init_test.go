@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"lambda-ci/deploy"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptUsesAnswersAndDefaults(t *testing.T) {
+	answers := initAnswers{Region: "eu-west-1"}
+	var out bytes.Buffer
+
+	if err := answers.prompt(strings.NewReader("orders-api\n\n\n"), &out); err != nil {
+		t.Fatalf("error while prompting: %v", err)
+	}
+
+	expected := initAnswers{Name: "orders-api", FileName: "main.go", Runtime: defaultRuntime, Region: "eu-west-1"}
+	if answers != expected {
+		t.Errorf("expected %v, got %v", expected, answers)
+	}
+	if strings.Contains(out.String(), "Region") {
+		t.Errorf("expected no prompt for the region given as flag, got %s", out.String())
+	}
+}
+
+func TestPromptRequiresName(t *testing.T) {
+	answers := initAnswers{}
+
+	if err := answers.prompt(strings.NewReader(""), &bytes.Buffer{}); err == nil || err.Error() != "function name is required" {
+		t.Errorf("expected the name to be required, got %v", err)
+	}
+}
+
+func TestWriteConfigTemplateIsValidConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", testMain)
+	path := filepath.Join(dir, configFileName)
+
+	if err := writeConfigTemplate(path, initAnswers{Name: "orders-api", FileName: "main.go", Runtime: defaultRuntime, Region: "eu-west-1"}); err != nil {
+		t.Fatalf("error while writing config: %v", err)
+	}
+
+	config, err := deploy.ParseFunctionConfig(path)
+	if err != nil {
+		t.Fatalf("expected a valid config, got %v", err)
+	}
+	if config.Name != "orders-api" || config.FileName != "main.go" || config.Runtime != defaultRuntime || config.Region != "eu-west-1" {
+		t.Errorf("expected the answers in the config, got %+v", config)
+	}
+}
+
+func TestInitRefusesToOverwriteConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, configFileName, "name: existing\n")
+
+	output, err := runMain(t, dir, "init", "--name", "hello", "--file-name", "main.go")
+
+	if err == nil || !strings.Contains(output, "already exists, use --force to overwrite it") {
+		t.Errorf("expected the existing config to be kept, got %v: %s", err, output)
+	}
+}
@@ -1,209 +1,519 @@
 package main
 
 import (
-	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v2"
 	"io"
 	"io/ioutil"
+	"lambda-ci/deploy"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
-type functionConfig struct {
-	Name     string `yaml:"name"`
-	FileName string `yaml:"fileName"`
-	Path     string `yaml:"-"`
+var (
+	configFlag = flag.String("config", "", "read a single function config from the given file instead of searching, use - for stdin")
+	pathFlag   = flag.String("path", "", "directory of the function when the config is read from stdin, defaults to the current directory")
+
+	sizeWarningFlag  = flag.Int64("size-warning", 240, "uncompressed package size in MB above which a warning is logged")
+	manifestFlag     = flag.String("manifest", "", "write a JSON summary of the deployed functions to the given file")
+	resumeFlag       = flag.String("resume", "", "skip the functions the given manifest of a previous run reports as deployed, if their live code is unchanged")
+	concurrencyFlag  = flag.Int("concurrency", 1, "number of functions deployed in parallel")
+	maxRetriesFlag   = flag.Int("max-retries", -1, "number of retries for failed AWS API calls, defaults to the SDK default")
+	handlerCheckFlag = flag.String("handler-check", deploy.HandlerCheckApply, "what to do if the live handler differs from the expected handler: apply, warn or off")
+	strictFlag       = flag.Bool("strict", false, "treat all warnings as errors")
+	forceFlag        = flag.Bool("force", false, "deploy even if the live runtime or architecture differs from the config")
+	outputFlag       = flag.String("output", "", "only build and zip the functions and write the zips to the given directory")
+	dryRunFlag       = flag.Bool("dry-run", false, "only report what would change, nothing is built or deployed")
+	diffFlag         = flag.Bool("diff", false, "print the differing configuration fields of every function, requires --dry-run")
+	explainFlag      = flag.Bool("explain", false, "describe every step the deploy of each function would take, nothing is built or deployed")
+	showSecretsFlag  = flag.Bool("show-secrets", false, "print the values of environment variables in the diff, requires --diff")
+	raceFlag         = flag.Bool("race", false, "build with the race detector, requires cgo and a supported target")
+
+	followSymlinksFlag = flag.Bool("follow-symlinks", false, "follow symlinked directories while searching for configs")
+	configGlobFlag     = flag.String("config-glob", "", "also discover config files whose name matches this glob, e.g. *.lambda.yaml")
+	validateOnlyFlag   = flag.Bool("validate-only", false, "only parse and validate all configs, report every error and exit")
+	quietFlag          = flag.Bool("quiet", false, "only log errors and print the final summary")
+	colorFlag          = flag.String("color", colorAuto, "color the summary: auto (if stdout is a terminal), always or never")
+	noColorFlag        = flag.Bool("no-color", false, "never color the summary, same as --color never")
+	requireConfigsFlag = flag.Bool("require-configs", false, "exit non-zero if no function configs are found")
+	namePrefixFlag     = flag.String("name-prefix", "", "prefix added to every function name, e.g. dev-")
+	nameSuffixFlag     = flag.String("name-suffix", "", "suffix added to every function name, e.g. -prod")
+	branchGuardFlag    = flag.String("branch-guard", "", "only deploy if the checked out git branch is this branch")
+
+	sinceFlag = flag.String("since", "", "deploy only functions with files changed since the given git ref")
+	labelFlag stringsFlag
+	dirFlag   stringsFlag
+
+	allowedAccountFlag stringsFlag
+	setFlag            stringsFlag
+
+	profileBuildFlag    = flag.String("profile-build", "", "directory to write the go build -x trace and build durations of every function to")
+	metricsEndpointFlag = flag.String("metrics-endpoint", "", "statsd host:port to push step durations to")
+
+	managedTagKeyFlag   = flag.String("managed-tag-key", "", "tag every deployed function with this key to mark it as managed by lambda-ci")
+	managedTagValueFlag = flag.String("managed-tag-value", "", "value of the managed tag, defaults to lambda-ci")
+	stampCIFlag         = flag.Bool("stamp-ci", false, "tag every deployed function with the CI provider, build number and build URL")
+
+	planFileFlag = flag.String("plan-file", "lambda-ci.plan.json", "plan written by the plan and read by the apply subcommand")
+
+	runTimeoutFlag = flag.Duration("run-timeout", 0, "cancel the whole run after the given duration, functions not started yet are skipped")
+
+	onlyChangedConfigFlag  = flag.Bool("only-changed-config", false, "skip the code update of functions whose zip matches the live code, the configuration is still updated")
+	noPublishUnchangedFlag = flag.Bool("no-publish-alias-if-unchanged", false, "leave the alias as it is if neither code nor configuration changed, requires --only-changed-config")
+	backupFlag             = flag.String("backup", "", "directory to download the live code of every function to before it is updated")
+	caBundleFlag           = flag.String("ca-bundle", "", "PEM file of additional CA certificates to trust for the AWS API calls and downloads")
+	signKeyFlag            = flag.String("sign-key", "", "PEM private key to sign the checksum of every uploaded zip with")
+	signatureDirFlag       = flag.String("signature-dir", "", "directory to write the checksums and signatures of --sign-key to, defaults to the working directory")
+	artifactBucketFlag     = flag.String("artifact-bucket", "", "S3 bucket to stage the zips in, functions are updated from the staged object")
+	s3PartSizeFlag         = flag.Int64("s3-part-size", 5, "part size in MB of multipart uploads to the artifact bucket")
+	s3ConcurrencyFlag      = flag.Int("s3-concurrency", 5, "number of parts uploaded in parallel per zip to the artifact bucket")
+	s3SseFlag              = flag.String("s3-sse", "", "server-side encryption of the staged zips, AES256 or aws:kms")
+	s3SseKmsKeyIdFlag      = flag.String("s3-sse-kms-key-id", "", "KMS key to encrypt the staged zips with, implies aws:kms")
+	s3ACLFlag              = flag.String("s3-acl", "", "canned ACL of the staged zips, e.g. bucket-owner-full-control")
+
+	debugArchiveBucketFlag = flag.String("debug-archive-bucket", "", "S3 bucket to archive unstripped binaries in, the deployed binaries are stripped")
+	keepArtifactsFlag      = flag.Bool("keep-artifacts", false, "keep the built binaries and zip files and log where they were left")
+	emitBuildInfoFlag      = flag.Bool("emit-build-info", false, "add a build-info.json with build metadata to every zip archive")
+)
+
+// init registers the flags that can't be declared as package variables.
+func init() {
+	flag.Var(&labelFlag, "label", "deploy only functions with the given label, may be repeated to select functions with any of the labels")
+	flag.Var(&allowedAccountFlag, "allowed-account", "AWS account ID functions may be deployed to, may be repeated")
+	flag.Var(&setFlag, "set", "override a config field of all functions for this run as field=value, may be repeated")
+	flag.Var(&dirFlag, "dir", "directory to search for configs instead of the current directory, may be repeated")
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		runInit(os.Args[2:])
+		return
+	}
+
+	// plan and apply take the same flags as a regular deploy
+	command := ""
+	if len(os.Args) > 1 && (os.Args[1] == "plan" || os.Args[1] == "apply") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
+	flag.Parse()
+
+	if *quietFlag {
+		logrus.SetLevel(logrus.ErrorLevel)
+	}
+
+	color, err := useColor(*colorFlag, *noColorFlag)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	globalConfig, err := loadGlobalConfig()
+	if err != nil {
+		logrus.WithError(err).Fatal("error while loading global config")
+	}
+	if err := globalConfig.apply(); err != nil {
+		logrus.WithError(err).Fatal("error while applying global config")
+	}
+
+	if *diffFlag && !*dryRunFlag {
+		logrus.Fatal("--diff requires --dry-run")
+	}
+	if *noPublishUnchangedFlag && !*onlyChangedConfigFlag {
+		logrus.Fatal("--no-publish-alias-if-unchanged requires --only-changed-config")
+	}
+	if *showSecretsFlag && !*diffFlag {
+		logrus.Fatal("--show-secrets requires --diff")
+	}
+	if *outputFlag != "" && *dryRunFlag {
+		logrus.Fatal("--output and --dry-run must not be combined")
+	}
+	if command != "" && (*outputFlag != "" || *dryRunFlag) {
+		logrus.Fatalf("%s must not be combined with --output or --dry-run", command)
+	}
+	if *s3PartSizeFlag < 5 {
+		logrus.Fatal("--s3-part-size must be at least 5 MB")
+	}
+	if *s3ConcurrencyFlag < 1 {
+		logrus.Fatal("--s3-concurrency must be at least 1")
+	}
+
 	currentDir, err := os.Getwd()
 	if err != nil {
 		logrus.WithError(err).Fatal("error while reading current directory")
 	}
 
-	files, err := findFunctionConfigs(currentDir)
+	if *validateOnlyFlag {
+		validateFunctionConfigs(currentDir)
+		return
+	}
+
+	configs, err := loadFunctionConfigs(currentDir)
 	if err != nil {
-		logrus.WithError(err).Fatal("error while reading function files directory")
+		logrus.WithError(err).Fatal("error while loading function configs")
+	}
+	for _, config := range configs {
+		if err := applyOverrides(config); err != nil {
+			logrus.WithError(err).Fatalf("error while applying overrides to config at %s", config.Path)
+		}
+	}
+	if len(configs) == 0 && *requireConfigsFlag {
+		logrus.Fatalf("no function configs found below %s", strings.Join(configRoots(currentDir), ", "))
 	}
 
-	for _, file := range files {
-		func() {
-			config, err := parseFunctionConfig(file)
-			if err != nil {
-				logrus.WithError(err).Fatalf("error while reading function config at %s", file)
-			}
+	if *sinceFlag != "" {
+		configs, err = selectChangedConfigs(currentDir, configs)
+		if err != nil {
+			logrus.WithError(err).Fatalf("error while detecting changes since %s", *sinceFlag)
+		}
+	}
 
-			if err := config.build(); err != nil {
-				logrus.WithError(err).Fatalf("error while compiling for config at %s", file)
-			}
-			defer config.mustDeleteBuildFile()
+	if len(labelFlag) > 0 {
+		selected := deploy.SelectLabeled(configs, labelFlag)
+		logrus.Infof("%d of %d functions have one of the labels %s", len(selected), len(configs), labelFlag.String())
+		configs = selected
+	}
 
-			if err := config.zipBuild(); err != nil {
-				logrus.WithError(err).Fatalf("error while building function config at %s", file)
-			}
-			defer config.mustDeleteZipFile()
+	opts := deploy.Options{
+		SizeWarningThreshold:   *sizeWarningFlag * 1024 * 1024,
+		Force:                  *forceFlag,
+		Concurrency:            *concurrencyFlag,
+		HandlerCheck:           *handlerCheckFlag,
+		Strict:                 *strictFlag,
+		Race:                   *raceFlag,
+		DryRun:                 *dryRunFlag,
+		OutputDir:              *outputFlag,
+		OnlyChangedConfig:      *onlyChangedConfigFlag,
+		KeepAliasIfUnchanged:   *noPublishUnchangedFlag,
+		BackupDir:              *backupFlag,
+		SignatureDir:           *signatureDirFlag,
+		BuildProfileDir:        *profileBuildFlag,
+		ArtifactBucket:         *artifactBucketFlag,
+		S3PartSize:             *s3PartSizeFlag * 1024 * 1024,
+		S3Concurrency:          *s3ConcurrencyFlag,
+		S3ServerSideEncryption: *s3SseFlag,
+		S3SseKmsKeyId:          *s3SseKmsKeyIdFlag,
+		S3ACL:                  *s3ACLFlag,
+		DebugArchiveBucket:     *debugArchiveBucketFlag,
+		EmitBuildInfo:          *emitBuildInfoFlag,
+		KeepArtifacts:          *keepArtifactsFlag,
+		ManagedTagKey:          *managedTagKeyFlag,
+		ManagedTagValue:        *managedTagValueFlag,
+		StampCI:                *stampCIFlag,
+		AllowedAccounts:        allowedAccountFlag,
+		BranchGuard:            *branchGuardFlag,
+	}
+	if *explainFlag {
+		opts.Explain = os.Stdout
+	}
+	if *diffFlag {
+		opts.Diff = os.Stdout
+		opts.ShowSecrets = *showSecretsFlag
+	}
+	if *maxRetriesFlag >= 0 {
+		opts.MaxRetries = maxRetriesFlag
+	}
+	httpClient, err := deploy.NewHTTPClient(*caBundleFlag)
+	if err != nil {
+		logrus.WithError(err).Fatalf("error while loading CA bundle %s", *caBundleFlag)
+	}
+	opts.HTTPClient = httpClient
+	if *signKeyFlag != "" {
+		key, err := deploy.LoadSigningKey(*signKeyFlag)
+		if err != nil {
+			logrus.WithError(err).Fatalf("error while loading signing key %s", *signKeyFlag)
+		}
+		opts.SigningKey = key
+	}
+	if *resumeFlag != "" {
+		resume, err := readManifest(*resumeFlag)
+		if err != nil {
+			logrus.WithError(err).Fatalf("error while reading manifest %s", *resumeFlag)
+		}
+		opts.Resume = resume
+	}
+	if *metricsEndpointFlag != "" {
+		recorder, err := deploy.NewStatsdRecorder(*metricsEndpointFlag)
+		if err != nil {
+			logrus.WithError(err).Fatalf("error while connecting to metrics endpoint %s", *metricsEndpointFlag)
+		}
+		// The fatal errors below exit without running deferred calls, the exit handler closes the recorder then
+		logrus.RegisterExitHandler(func() { recorder.Close() })
+		defer recorder.Close()
+		opts.Metrics = recorder
+	}
 
-			if err := config.updateLambda(); err != nil {
-				logrus.WithError(err).Fatalf("error while updating Lambda-Function for config at %s", file)
-			}
-		}()
+	ctx := context.Background()
+	if *runTimeoutFlag > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *runTimeoutFlag)
+		defer cancel()
 	}
-}
 
-// getBuildOutputPath returns the path where the built function file should be written to.
-func (conf *functionConfig) getBuildOutputPath() string {
-	return fmt.Sprintf("%s/%s", conf.Path, conf.Name)
-}
+	if command == "plan" {
+		runPlan(ctx, configs, opts)
+		return
+	}
 
-// getZipOutputPath returns the path where the zipped built should be written to.
-func (conf *functionConfig) getZipOutputPath() string {
-	return fmt.Sprintf("%s/%s.zip", conf.Path, conf.Name)
-}
+	var results []deploy.Result
+	var deployErr error
+	if command == "apply" {
+		results, deployErr = runApply(ctx, configs, opts)
+	} else {
+		results, deployErr = deploy.Deploy(ctx, configs, opts)
+	}
+	if deployErr != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		deployErr = fmt.Errorf("run timed out after %s: %w", *runTimeoutFlag, deployErr)
+	}
+	printSummary(os.Stdout, results, color)
 
-// mustDeleteBuildFile deletes the built file for this functionConfig.
-func (conf *functionConfig) mustDeleteBuildFile() {
-	if err := os.Remove(conf.getBuildOutputPath()); err != nil {
-		logrus.WithError(err).Fatalf("error while deleting build at %s", conf.getBuildOutputPath())
+	if *manifestFlag != "" {
+		if err := writeManifest(*manifestFlag, results); err != nil {
+			logrus.WithError(err).Fatalf("error while writing manifest to %s", *manifestFlag)
+		}
 	}
-}
 
-func (conf *functionConfig) getFullFilePath() string {
-	return fmt.Sprintf("%s/%s", conf.Path, conf.FileName)
+	if deployErr != nil {
+		logrus.WithError(deployErr).Fatal("error while deploying functions")
+	}
 }
 
-// mustDeleteBuildFile deletes the built file for this functionConfig.
-func (conf *functionConfig) mustDeleteZipFile() {
-	if err := os.Remove(conf.getZipOutputPath()); err != nil {
-		logrus.WithError(err).Fatalf("error while deleting build at %s", conf.getBuildOutputPath())
-	}
+// stringsFlag collects the values of a flag that may be given multiple times.
+type stringsFlag []string
+
+// String returns the collected values separated by commas.
+func (values *stringsFlag) String() string {
+	return strings.Join(*values, ", ")
 }
 
-// build runs the go build command for the referenced source file.
-// Returns the path of the output file.
-func (conf *functionConfig) build() error {
-	if err := exec.Command("go", "build", "-o", conf.getBuildOutputPath(), conf.getFullFilePath()).Run(); err != nil {
-		return err
-	}
+// Set adds another value.
+func (values *stringsFlag) Set(value string) error {
+	*values = append(*values, value)
 	return nil
 }
 
-// zipBuild puts the built for this functionConfig into a zip file.
-func (conf *functionConfig) zipBuild() error {
-	zipFile, err := os.Create(conf.getZipOutputPath())
+// validateFunctionConfigs parses and validates every config below the config roots without building or deploying.
+// All invalid configs are reported before exiting non-zero.
+func validateFunctionConfigs(currentDir string) {
+	files, err := findConfigFiles(currentDir)
 	if err != nil {
-		return err
+		logrus.WithError(err).Fatal("error while reading function files directory")
 	}
 
-	writer := zip.NewWriter(zipFile)
-	defer writer.Close()
-
-	fileToZip, err := os.Open(conf.getBuildOutputPath())
-	if err != nil {
-		return err
+	var configs []*deploy.FunctionConfig
+	invalid := 0
+	for _, file := range files {
+		config, err := deploy.ParseFunctionConfig(file)
+		if err == nil {
+			err = applyOverrides(config)
+		}
+		if err != nil {
+			logrus.WithError(err).Errorf("invalid function config at %s", file)
+			invalid++
+			continue
+		}
+		configs = append(configs, config)
+	}
+	if err := deploy.CheckDuplicateNames(configs); err != nil {
+		logrus.WithError(err).Error("invalid function configs")
+		invalid++
 	}
-	defer fileToZip.Close()
 
-	fileStats, err := fileToZip.Stat()
-	if err != nil {
-		return err
+	if invalid > 0 {
+		logrus.Fatalf("found %d errors in %d function configs", invalid, len(files))
 	}
+	if len(files) == 0 && *requireConfigsFlag {
+		logrus.Fatalf("no function configs found below %s", strings.Join(configRoots(currentDir), ", "))
+	}
+	logrus.Infof("all %d function configs are valid", len(files))
+}
 
-	header, err := zip.FileInfoHeader(fileStats)
+// selectChangedConfigs returns the configs with files changed since the --since ref.
+// Outside of a git repository all configs are returned.
+func selectChangedConfigs(currentDir string, configs []*deploy.FunctionConfig) ([]*deploy.FunctionConfig, error) {
+	files, err := deploy.ChangedFiles(currentDir, *sinceFlag)
+	if errors.Is(err, deploy.ErrNotGitRepository) {
+		if *strictFlag {
+			return nil, fmt.Errorf("%s is not a git repository (warning treated as error in strict mode)", currentDir)
+		}
+		logrus.Warnf("%s is not a git repository, deploying all functions", currentDir)
+		return configs, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	header.Name = fileStats.Name()
-	header.Method = zip.Deflate
+	selected := deploy.SelectChanged(configs, files)
+	logrus.Infof("%d of %d functions changed since %s", len(selected), len(configs), *sinceFlag)
+	return selected, nil
+}
 
-	fileWriter, err := writer.CreateHeader(header)
+// printSummary writes one line per processed function and the totals per action.
+// It is written directly to out, so it is also printed in quiet mode. With color the actions are colored.
+func printSummary(out io.Writer, results []deploy.Result, color bool) {
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[result.Action]++
+		action := fmt.Sprintf("%-8s", result.Action)
+		if color {
+			action = colorize(result.Action, 8)
+		}
+		fmt.Fprintf(out, "%s %s (version %s, %dms)\n", action, result.Name, result.Version, result.DurationMs)
+	}
+	// packaged and dry-run are only listed if they occurred
+	optional := map[string]bool{deploy.ActionPackaged: true, deploy.ActionDryRun: true}
+	var totals []string
+	for _, action := range []string{deploy.ActionUpdated, deploy.ActionPackaged, deploy.ActionDryRun, deploy.ActionSkipped, deploy.ActionFailed} {
+		if counts[action] > 0 || !optional[action] {
+			totals = append(totals, fmt.Sprintf("%d %s", counts[action], action))
+		}
+	}
+	fmt.Fprintf(out, "%d functions: %s\n", len(results), strings.Join(totals, ", "))
+}
+
+// writeManifest writes the deploy results as JSON to the given path.
+// The file is written to a temporary file first and renamed so it is never left half-written.
+func writeManifest(path string, results []deploy.Result) error {
+	if results == nil {
+		results = []deploy.Result{}
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(fileWriter, fileToZip)
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmpFile.Name())
 
-	return nil
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	// TempFile creates the file with mode 0600, the manifest is read by other users like artifact uploaders
+	if err := tmpFile.Chmod(0644); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
 }
 
-// updateLambda takes the built and zipped go file and updates the corresponding Lambda function.
-// This functions also checks if the handler name is still correct.
-func (conf *functionConfig) updateLambda() error {
-	data, err := ioutil.ReadFile(conf.getZipOutputPath())
+// readManifest reads the deploy results of a previous run from the manifest at the given path.
+func readManifest(path string) ([]deploy.Result, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	var results []deploy.Result
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
-	sess := session.Must(session.NewSession())
-
-	lambdaSess := lambda.New(sess)
-
-	lambdaInfo, err := lambdaSess.UpdateFunctionCode(&lambda.UpdateFunctionCodeInput{
-		FunctionName: &conf.Name,
-		ZipFile:      data,
-	})
+// loadFunctionConfigs returns the function configs to process.
+// Depending on the --config flag a single config is read from stdin or a file,
+// otherwise all configs below the config roots are parsed.
+func loadFunctionConfigs(currentDir string) ([]*deploy.FunctionConfig, error) {
+	switch *configFlag {
+	case "":
+	case "-":
+		dir := *pathFlag
+		if dir == "" {
+			dir = currentDir
+		}
+		dir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		config, err := deploy.ParseFunctionConfigFromReader(os.Stdin, dir)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading function config from stdin: %w", err)
+		}
+		return []*deploy.FunctionConfig{config}, nil
+	default:
+		config, err := deploy.ParseFunctionConfig(*configFlag)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading function config at %s: %w", *configFlag, err)
+		}
+		return []*deploy.FunctionConfig{config}, nil
+	}
 
+	files, err := findConfigFiles(currentDir)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("error while reading function files directory: %w", err)
 	}
-	logrus.Infof("updated lambda function %s", *lambdaInfo.FunctionName)
 
-	// Check if the handler name is still correct of if it must be updated
-	if strings.Compare(*lambdaInfo.Handler, conf.Name) != 0 {
-		_, err := lambdaSess.UpdateFunctionConfiguration(&lambda.UpdateFunctionConfigurationInput{
-			Handler: &conf.Name,
-		})
+	var configs []*deploy.FunctionConfig
+	for _, file := range files {
+		config, err := deploy.ParseFunctionConfig(file)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("error while reading function config at %s: %w", file, err)
 		}
-		logrus.Infof("updated handler name for lambda %s to prevent issues", *lambdaInfo.FunctionName)
+		configs = append(configs, config)
 	}
-
-	return nil
+	return configs, nil
 }
 
-// findFunctionConfigs searches recursively starting a root directory.
-// returns a slice of found function configs.
-func findFunctionConfigs(root string) ([]string, error) {
-	var files []string
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// applyOverrides applies all --set field=value flags to the config and decorates its name with --name-prefix and --name-suffix.
+func applyOverrides(config *deploy.FunctionConfig) error {
+	if err := config.DecorateName(*namePrefixFlag, *nameSuffixFlag); err != nil {
+		return err
+	}
+	for _, override := range setFlag {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("--set %s must have the form field=value", override)
 		}
-		if strings.Compare(info.Name(), ".function.yaml") == 0 {
-			files = append(files, path)
+		if err := config.Override(parts[0], parts[1]); err != nil {
+			return err
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
-
 	}
-	return files, nil
+	return nil
 }
 
-// parseFunctionConfig parses a .function.yaml file at the given path
-func parseFunctionConfig(path string) (*functionConfig, error) {
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
+// configRoots returns the directories searched for configs, the --dir values or currentDir.
+// Relative --dir values are resolved against currentDir.
+func configRoots(currentDir string) []string {
+	if len(dirFlag) == 0 {
+		return []string{currentDir}
 	}
-
-	var function functionConfig
-	if err := yaml.Unmarshal(data, &function); err != nil {
-		return nil, err
+	roots := make([]string, len(dirFlag))
+	for i, dir := range dirFlag {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(currentDir, dir)
+		}
+		roots[i] = filepath.Clean(dir)
 	}
+	return roots
+}
 
-	function.Path = strings.Replace(path, "/.function.yaml", "", 1)
-
-	return &function, nil
+// findConfigFiles searches all config roots and returns the found configs without duplicates,
+// so nested or repeated roots don't deploy a function twice.
+func findConfigFiles(currentDir string) ([]string, error) {
+	var files []string
+	found := map[string]bool{}
+	for _, root := range configRoots(currentDir) {
+		rootFiles, err := deploy.FindFunctionConfigs(root, *followSymlinksFlag, *configGlobFlag)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range rootFiles {
+			if !found[file] {
+				found[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
 }
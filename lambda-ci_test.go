@@ -0,0 +1,460 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"lambda-ci/deploy"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runMainEnv makes the test binary run main instead of the tests, see runMain.
+const runMainEnv = "LAMBDA_CI_TEST_RUN_MAIN"
+
+// TestMain runs main with the arguments of the process if runMainEnv is set.
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs lambda-ci with the given arguments in dir and returns its combined output.
+// The process gets an empty home directory, so no global config applies, and no AWS credentials.
+func runMain(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), runMainEnv+"=1", "HOME="+t.TempDir(), "AWS_REGION=eu-central-1",
+		"AWS_ACCESS_KEY_ID=", "AWS_SECRET_ACCESS_KEY=", "AWS_PROFILE=", "AWS_CONFIG_FILE=/dev/null", "AWS_SHARED_CREDENTIALS_FILE=/dev/null")
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// testMain is the source of a function that builds without dependencies.
+const testMain = "package main\n\nfunc main() {}\n"
+
+// writeFunction writes a function directory with a main.go and the given config below dir.
+func writeFunction(t *testing.T, dir string, name string, config string) string {
+	t.Helper()
+	writeFile(t, dir, filepath.Join(name, "main.go"), testMain)
+	return writeFile(t, dir, filepath.Join(name, ".function.yaml"), config)
+}
+
+// setStringFlag sets the flag for the duration of the test.
+func setStringFlag(t *testing.T, flag *string, value string) {
+	previous := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = previous })
+}
+
+// writeFile writes a file below dir, creating the missing directories.
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setStdin replaces os.Stdin with a file of the given content for the duration of the test.
+func setStdin(t *testing.T, content string) {
+	t.Helper()
+	file, err := os.Open(writeFile(t, t.TempDir(), "stdin", content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := os.Stdin
+	os.Stdin = file
+	t.Cleanup(func() {
+		os.Stdin = previous
+		file.Close()
+	})
+}
+
+func TestLoadFunctionConfigsFromStdin(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", testMain)
+	setStdin(t, "name: hello\nfileName: main.go\n")
+	setStringFlag(t, configFlag, "-")
+	setStringFlag(t, pathFlag, dir)
+
+	configs, err := loadFunctionConfigs(t.TempDir())
+	if err != nil {
+		t.Fatalf("error while loading configs: %v", err)
+	}
+	if len(configs) != 1 || configs[0].Name != "hello" {
+		t.Fatalf("expected the config of hello, got %v", configs)
+	}
+	if configs[0].Path != dir {
+		t.Errorf("expected the function directory %s, got %s", dir, configs[0].Path)
+	}
+}
+
+func TestLoadFunctionConfigsFromStdinRejectsInvalidConfig(t *testing.T) {
+	setStdin(t, "name: hello\nfileName: missing.go\n")
+	setStringFlag(t, configFlag, "-")
+	setStringFlag(t, pathFlag, t.TempDir())
+
+	if _, err := loadFunctionConfigs(t.TempDir()); err == nil {
+		t.Error("expected an error for a config without its source file")
+	}
+}
+
+func TestWriteManifestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "manifest.json")
+	results := []deploy.Result{
+		{Name: "hello", Version: "3", CodeSha256: "abc=", Region: "eu-central-1", Action: deploy.ActionUpdated, DurationMs: 1200},
+		{Name: "world", Region: "eu-central-1", Action: deploy.ActionFailed},
+	}
+
+	if err := writeManifest(path, results); err != nil {
+		t.Fatalf("error while writing manifest: %v", err)
+	}
+	read, err := readManifest(path)
+	if err != nil {
+		t.Fatalf("error while reading manifest: %v", err)
+	}
+	if !reflect.DeepEqual(read, results) {
+		t.Errorf("expected %v, got %v", results, read)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the manifest in %s, got %d files", dir, len(files))
+	}
+	if mode := files[0].Mode().Perm(); mode != 0644 {
+		t.Errorf("expected the manifest to be readable by everyone, got mode %v", mode)
+	}
+}
+
+func TestWriteManifestWithoutResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+
+	if err := writeManifest(path, nil); err != nil {
+		t.Fatalf("error while writing manifest: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("expected an empty JSON array, got %s", data)
+	}
+}
+
+func TestReadManifestRejectsMalformedManifest(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "manifest.json", "{\"name\": \"hello\"}")
+
+	if _, err := readManifest(path); err == nil {
+		t.Error("expected a manifest without a result list to be rejected")
+	}
+	if _, err := readManifest(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected a missing manifest to be rejected")
+	}
+}
+
+func TestValidateOnlyReportsAllInvalidConfigs(t *testing.T) {
+	dir := t.TempDir()
+	writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\n")
+	writeFunction(t, dir, "broken", "name: broken\nfileName: main.go\nmemorySize: 64\n")
+	writeFunction(t, dir, "misspelled", "name: misspelled\nfilename: main.go\n")
+
+	output, err := runMain(t, dir, "--validate-only")
+
+	if err == nil {
+		t.Fatalf("expected a non-zero exit, got output %s", output)
+	}
+	for _, message := range []string{"invalid function config at " + filepath.Join(dir, "broken"), "invalid function config at " + filepath.Join(dir, "misspelled"), "found 2 errors in 3 function configs"} {
+		if !strings.Contains(output, message) {
+			t.Errorf("expected the output to contain %q, got %s", message, output)
+		}
+	}
+}
+
+func TestValidateOnlyAcceptsValidConfigs(t *testing.T) {
+	dir := t.TempDir()
+	writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\n")
+	writeFunction(t, dir, "world", "name: world\nfileName: main.go\n")
+
+	output, err := runMain(t, dir, "--validate-only")
+
+	if err != nil {
+		t.Fatalf("expected a zero exit, got %v: %s", err, output)
+	}
+	if !strings.Contains(output, "all 2 function configs are valid") {
+		t.Errorf("expected the configs to be valid, got %s", output)
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	results := []deploy.Result{
+		{Name: "hello", Version: "3", Action: deploy.ActionUpdated, DurationMs: 1200},
+		{Name: "world", Action: deploy.ActionFailed, DurationMs: 300},
+	}
+	var out strings.Builder
+
+	printSummary(&out, results, false)
+
+	expected := "updated  hello (version 3, 1200ms)\n" +
+		"failed   world (version , 300ms)\n" +
+		"2 functions: 1 updated, 0 skipped, 1 failed\n"
+	if out.String() != expected {
+		t.Errorf("expected summary\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestPrintSummaryListsOptionalActionsIfOccurred(t *testing.T) {
+	var out strings.Builder
+
+	printSummary(&out, []deploy.Result{{Name: "hello", Action: deploy.ActionDryRun}}, false)
+
+	if !strings.HasSuffix(out.String(), "1 functions: 0 updated, 1 dry-run, 0 skipped, 0 failed\n") {
+		t.Errorf("expected the dry-run total, got %s", out.String())
+	}
+}
+
+func TestQuietOnlyLogsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\n")
+
+	output, err := runMain(t, dir, "--quiet", "--validate-only")
+
+	if err != nil {
+		t.Fatalf("expected a zero exit, got %v: %s", err, output)
+	}
+	if output != "" {
+		t.Errorf("expected no output in quiet mode, got %s", output)
+	}
+}
+
+func TestStringsFlagCollectsRepeatedValues(t *testing.T) {
+	var values stringsFlag
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(&values, "label", "")
+
+	if err := flags.Parse([]string{"--label", "api", "--label", "payments"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual([]string(values), []string{"api", "payments"}) || values.String() != "api, payments" {
+		t.Errorf("expected both labels, got %v", values)
+	}
+}
+
+func TestRunTimeoutSkipsFunctions(t *testing.T) {
+	dir := t.TempDir()
+	writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\n")
+
+	output, err := runMain(t, dir, "--run-timeout", "1ns")
+
+	if err == nil {
+		t.Fatalf("expected a non-zero exit, got output %s", output)
+	}
+	for _, message := range []string{"skipped  hello", "run timed out after 1ns: context deadline exceeded"} {
+		if !strings.Contains(output, message) {
+			t.Errorf("expected the output to contain %q, got %s", message, output)
+		}
+	}
+}
+
+func TestRequireConfigsFailsWithoutConfigs(t *testing.T) {
+	for _, args := range [][]string{{"--require-configs"}, {"--require-configs", "--validate-only"}} {
+		dir := t.TempDir()
+
+		output, err := runMain(t, dir, args...)
+
+		if err == nil || !strings.Contains(output, "no function configs found below "+dir) {
+			t.Errorf("expected %v to fail without configs, got %v: %s", args, err, output)
+		}
+	}
+}
+
+func TestRequireConfigsIgnoresFilteredConfigs(t *testing.T) {
+	dir := t.TempDir()
+	writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\n")
+
+	output, err := runMain(t, dir, "--require-configs", "--label", "payments")
+
+	if err != nil {
+		t.Errorf("expected the labeled out function not to count as missing, got %v: %s", err, output)
+	}
+	if !strings.Contains(output, "0 functions: 0 updated, 0 skipped, 0 failed") {
+		t.Errorf("expected an empty summary, got %s", output)
+	}
+}
+
+func TestMissingConfigsSucceedByDefault(t *testing.T) {
+	output, err := runMain(t, t.TempDir())
+
+	if err != nil {
+		t.Errorf("expected a zero exit without configs, got %v: %s", err, output)
+	}
+}
+
+func TestConfigRootsResolvesRelativeDirs(t *testing.T) {
+	previous := dirFlag
+	dirFlag = stringsFlag{"services/api", "/srv/workers/", "../shared"}
+	t.Cleanup(func() { dirFlag = previous })
+
+	roots := configRoots("/repo")
+
+	if !reflect.DeepEqual(roots, []string{"/repo/services/api", "/srv/workers", "/shared"}) {
+		t.Errorf("expected the resolved roots, got %v", roots)
+	}
+}
+
+func TestFindConfigFilesDeduplicatesNestedRoots(t *testing.T) {
+	dir := t.TempDir()
+	api := writeFunction(t, dir, "services/api", "name: api\nfileName: main.go\n")
+	worker := writeFunction(t, dir, "workers/mail", "name: mail\nfileName: main.go\n")
+	writeFunction(t, dir, "tools/cli", "name: cli\nfileName: main.go\n")
+	previous := dirFlag
+	dirFlag = stringsFlag{"services", "workers", "services/api"}
+	t.Cleanup(func() { dirFlag = previous })
+
+	files, err := findConfigFiles(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(files, []string{api, worker}) {
+		t.Errorf("expected each config of the roots once, got %v", files)
+	}
+}
+
+func TestValidateOnlySearchesAllDirs(t *testing.T) {
+	dir := t.TempDir()
+	writeFunction(t, dir, "services/api", "name: api\nfileName: main.go\n")
+	writeFunction(t, dir, "workers/mail", "name: mail\nfileName: main.go\n")
+	writeFunction(t, dir, "tools/cli", "name: cli\nfileName: main.go\nmemorySize: 64\n")
+
+	output, err := runMain(t, dir, "--validate-only", "--dir", "services", "--dir", "workers")
+
+	if err != nil || !strings.Contains(output, "all 2 function configs are valid") {
+		t.Errorf("expected only the configs below the dirs to be validated, got %v: %s", err, output)
+	}
+}
+
+func TestApplyOverridesSetsFields(t *testing.T) {
+	previous := setFlag
+	setFlag = stringsFlag{"memorySize=512", "timeout=30"}
+	t.Cleanup(func() { setFlag = previous })
+	dir := t.TempDir()
+	config, err := deploy.ParseFunctionConfig(writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\nmemorySize: 128\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyOverrides(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.MemorySize != 512 || config.Timeout != 30 {
+		t.Errorf("expected memory size 512 and timeout 30, got %d and %d", config.MemorySize, config.Timeout)
+	}
+}
+
+func TestApplyOverridesRejectsMalformedSet(t *testing.T) {
+	previous := setFlag
+	setFlag = stringsFlag{"memorySize"}
+	t.Cleanup(func() { setFlag = previous })
+
+	if err := applyOverrides(&deploy.FunctionConfig{Name: "hello"}); err == nil || err.Error() != "--set memorySize must have the form field=value" {
+		t.Errorf("expected the malformed override to be rejected, got %v", err)
+	}
+}
+
+func TestNoPublishAliasIfUnchangedRequiresOnlyChangedConfig(t *testing.T) {
+	output, err := runMain(t, t.TempDir(), "--no-publish-alias-if-unchanged")
+
+	if err == nil || !strings.Contains(output, "--no-publish-alias-if-unchanged requires --only-changed-config") {
+		t.Errorf("expected the flag to be rejected, got %v: %s", err, output)
+	}
+}
+
+func TestApplyOverridesDecoratesName(t *testing.T) {
+	setStringFlag(t, namePrefixFlag, "dev-")
+	setStringFlag(t, nameSuffixFlag, "-eu")
+	config := &deploy.FunctionConfig{Name: "hello:live"}
+
+	if err := applyOverrides(config); err != nil || config.Name != "dev-hello-eu:live" {
+		t.Errorf("expected the decorated name dev-hello-eu:live, got %s, %v", config.Name, err)
+	}
+}
+
+func TestConfigGlobDiscoversMatchingConfigs(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "orders/main.go", testMain)
+	writeFile(t, dir, "orders/orders.lambda.yaml", "name: orders\nfileName: main.go\n")
+	writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\n")
+	writeFile(t, dir, "docs/openapi.yaml", "openapi: 3.0.0\n")
+
+	output, err := runMain(t, dir, "--validate-only", "--config-glob", "*.lambda.yaml")
+
+	if err != nil || !strings.Contains(output, "all 2 function configs are valid") {
+		t.Errorf("expected the glob and the default config to be validated, got %v: %s", err, output)
+	}
+}
+
+func TestWriteManifestIncludesDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+
+	if err := writeManifest(path, []deploy.Result{{Name: "hello", Action: deploy.ActionUpdated, DurationMs: 1200}}); err != nil {
+		t.Fatalf("error while writing manifest: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `"durationMs": 1200`) {
+		t.Errorf("expected the duration in the manifest, got %s, %v", data, err)
+	}
+}
+
+func TestCABundleFlagRejectsInvalidBundle(t *testing.T) {
+	dir := t.TempDir()
+	writeFunction(t, dir, "hello", "name: hello\nfileName: main.go\n")
+	bundle := writeFile(t, dir, "ca.pem", "not a certificate")
+
+	output, err := runMain(t, dir, "--ca-bundle", bundle)
+
+	if err == nil || !strings.Contains(output, "error while loading CA bundle "+bundle) || !strings.Contains(output, "no PEM certificates found") {
+		t.Errorf("expected the invalid CA bundle to be rejected, got %v: %s", err, output)
+	}
+}
+
+func TestMetricsAreSentForFailedRuns(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dir := t.TempDir()
+	writeFile(t, dir, "hello/main.go", "package main\n\nfunc main() { undefined() }\n")
+	writeFile(t, dir, "hello/.function.yaml", "name: hello\nfileName: main.go\nruntime: provided.al2023\n")
+
+	output, err := runMain(t, dir, "--metrics-endpoint", conn.LocalAddr().String(), "--output", filepath.Join(dir, "dist"))
+
+	if err == nil {
+		t.Fatalf("expected the failed build to exit non-zero, got output %s", output)
+	}
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buffer)
+	if err != nil || !strings.HasPrefix(string(buffer[:n]), "lambda_ci.build.hello:") {
+		t.Errorf("expected the build metric of the failed run, got %q, %v", buffer[:n], err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"lambda-ci/deploy"
+	"os"
+	"strings"
+)
+
+// runPlan implements the plan subcommand. The plan is written to --plan-file,
+// the planned zips to the directory next to it.
+func runPlan(ctx context.Context, configs []*deploy.FunctionConfig, opts deploy.Options) {
+	plan, err := deploy.CreatePlan(ctx, configs, opts, *planFileFlag+".zips")
+	if err != nil {
+		logrus.WithError(err).Fatal("error while planning functions")
+	}
+	if err := deploy.WritePlan(*planFileFlag, plan); err != nil {
+		logrus.WithError(err).Fatalf("error while writing plan to %s", *planFileFlag)
+	}
+	printPlan(os.Stdout, plan)
+	logrus.Infof("wrote plan to %s, apply it with lambda-ci apply --plan-file %s", *planFileFlag, *planFileFlag)
+}
+
+// runApply implements the apply subcommand, which deploys the plan in --plan-file.
+func runApply(ctx context.Context, configs []*deploy.FunctionConfig, opts deploy.Options) ([]deploy.Result, error) {
+	plan, err := deploy.ReadPlan(*planFileFlag)
+	if err != nil {
+		logrus.WithError(err).Fatalf("error while reading plan from %s", *planFileFlag)
+	}
+	return deploy.ApplyPlan(ctx, configs, plan, opts)
+}
+
+// printPlan writes one line per planned function with the changes that apply would make.
+func printPlan(out io.Writer, plan *deploy.Plan) {
+	changed := 0
+	for _, function := range plan.Functions {
+		var changes []string
+		if function.CodeChanged() {
+			changes = append(changes, "code")
+		}
+		changes = append(changes, function.Changes...)
+		if len(changes) == 0 {
+			fmt.Fprintf(out, "  %s (no changes)\n", function.Name)
+			continue
+		}
+		changed++
+		fmt.Fprintf(out, "~ %s (%s)\n", function.Name, strings.Join(changes, ", "))
+	}
+	fmt.Fprintf(out, "%d functions: %d to change, %d unchanged\n", len(plan.Functions), changed, len(plan.Functions)-changed)
+}